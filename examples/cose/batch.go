@@ -0,0 +1,89 @@
+package cose
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/batch"
+)
+
+// verifierCache keeps parsed public keys, so that a batch does not parse
+// the same public key for every message. A nil cache parses every time.
+type verifierCache struct {
+	mu        sync.Mutex
+	verifiers map[string]*keyVerifier
+}
+
+func (c *verifierCache) verifier(public_key []byte) (*keyVerifier, error) {
+	if c == nil {
+		return verifierFromPublicKey(public_key)
+	}
+	c.mu.Lock()
+	verifier, exists := c.verifiers[string(public_key)]
+	c.mu.Unlock()
+	if exists {
+		return verifier, nil
+	}
+	verifier, err := verifierFromPublicKey(public_key)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.verifiers == nil {
+		c.verifiers = map[string]*keyVerifier{}
+	}
+	c.verifiers[string(public_key)] = verifier
+	c.mu.Unlock()
+	return verifier, nil
+}
+
+type Sign1BatchResult struct {
+	Index        int
+	Verification Sign1Verification
+	Err          error
+}
+
+func batchVerifier(resolver KeyResolver, opts []VerifyOption) func([]byte) (Sign1Verification, error) {
+	o := newVerifyOptions(opts)
+	cache := &verifierCache{}
+	return func(signature []byte) (Sign1Verification, error) {
+		return verifySign1WithResolver(resolver, cache, signature, o)
+	}
+}
+
+// VerifySign1Batch verifies signatures with keys from the resolver on
+// workers goroutines, or GOMAXPROCS when workers is not positive. Results
+// are in the order of signatures. Signatures that were not verified before
+// ctx was done fail with the error of ctx.
+func VerifySign1Batch(ctx context.Context, resolver KeyResolver, signatures [][]byte, workers int, opts ...VerifyOption) []Sign1BatchResult {
+	results := batch.RunSlice(ctx, signatures, workers, batchVerifier(resolver, opts))
+	batch_results := make([]Sign1BatchResult, len(results))
+	for i, result := range results {
+		batch_results[i] = Sign1BatchResult{
+			Index:        result.Index,
+			Verification: result.Value,
+			Err:          result.Err,
+		}
+	}
+	return batch_results
+}
+
+// VerifySign1Channel verifies the signatures received from a channel like
+// VerifySign1Batch, sending results in the order they were received. The
+// result channel is closed once signatures is closed or ctx is done, and
+// callers must drain it.
+func VerifySign1Channel(ctx context.Context, resolver KeyResolver, signatures <-chan []byte, workers int, opts ...VerifyOption) <-chan Sign1BatchResult {
+	results := batch.Run(ctx, signatures, workers, batchVerifier(resolver, opts))
+	batch_results := make(chan Sign1BatchResult)
+	go func() {
+		defer close(batch_results)
+		for result := range results {
+			batch_results <- Sign1BatchResult{
+				Index:        result.Index,
+				Verification: result.Value,
+				Err:          result.Err,
+			}
+		}
+	}()
+	return batch_results
+}
@@ -0,0 +1,87 @@
+package cose
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/veraison/go-cose"
+)
+
+// TestVerifySign1Batch calls cose.VerifySign1Batch on messages from
+// several keys and confirms each result is in order and correct
+func TestVerifySign1Batch(t *testing.T) {
+	var public_keys [][]byte
+	var signatures [][]byte
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		public_keys = append(public_keys, public_key)
+		key, _ := DecodeKey(private_key)
+		for i := 0; i < 10; i++ {
+			signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, []byte{byte(alg), byte(i)})
+			signatures = append(signatures, signature)
+		}
+	}
+	other_private_key, _ := GenerateKey(ML_DSA_44, notary_seed)
+	other_key, _ := DecodeKey(other_private_key)
+	unknown, _ := Sign1(other_private_key, Header{Alg: other_key.Alg, Kid: other_key.Kid}, payload)
+	signatures = append(signatures, unknown, []byte{0xa0})
+
+	results := VerifySign1Batch(context.Background(), NewStaticKeyResolver(public_keys...), signatures, 4)
+	if len(results) != len(signatures) {
+		t.Fatalf("Invalid result count (%d), want %d", len(results), len(signatures))
+	}
+	for i, result := range results[:30] {
+		if result.Index != i || result.Err != nil {
+			t.Fatalf("Verifying message %d failed: %v", i, result.Err)
+		}
+		if result.Verification.Payload[1] != byte(i%10) {
+			t.Fatalf("Result %d is out of order", i)
+		}
+	}
+	if results[30].Err == nil {
+		t.Fatalf("Verified a message from an unknown key")
+	}
+	if results[31].Err == nil {
+		t.Fatalf("Verified a malformed message")
+	}
+}
+
+// TestVerifySign1Channel calls cose.VerifySign1Channel and confirms
+// results arrive in order, and cancelling stops the verification
+func TestVerifySign1Channel(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	resolver := NewStaticKeyResolver(public_key)
+
+	signatures := make(chan []byte)
+	go func() {
+		defer close(signatures)
+		for i := 0; i < 20; i++ {
+			signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, []byte{byte(i)})
+			signatures <- signature
+		}
+	}()
+	count := 0
+	for result := range VerifySign1Channel(context.Background(), resolver, signatures, 3) {
+		if result.Err != nil || result.Index != count || result.Verification.Payload[0] != byte(count) {
+			t.Fatalf("Invalid result %d: %v", count, result.Err)
+		}
+		count++
+	}
+	if count != 20 {
+		t.Fatalf("Invalid result count (%d), want 20", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	results := VerifySign1Batch(ctx, resolver, [][]byte{signature, signature}, 2)
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Fatalf("Verified a message after cancelling")
+		}
+	}
+}
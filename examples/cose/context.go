@@ -0,0 +1,38 @@
+package cose
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/veraison/go-cose"
+)
+
+// EXPERIMENTAL: the draft requires the ML-DSA context string to be empty.
+// A non-empty context string is carried in this private use header
+// parameter, so that verifiers can detect it instead of failing to verify.
+const HEADER_LABEL_EXPERIMENTAL_CONTEXT int64 = -65537
+
+func checkContext(ctx []byte) error {
+	if len(ctx) > 255 {
+		return errors.New("ML-DSA context string is longer than 255 bytes")
+	}
+	return nil
+}
+
+func contextForVerification(o verifyOptions, headers cose.ProtectedHeader) ([]byte, error) {
+	value, exists := headers[HEADER_LABEL_EXPERIMENTAL_CONTEXT]
+	if !exists {
+		if len(o.ctx) != 0 {
+			return nil, errors.New("COSE_Sign1 is missing the experimental context header")
+		}
+		return nil, nil
+	}
+	if len(o.ctx) == 0 {
+		return nil, errors.New("Experimental context header requires ExpectExperimentalContext")
+	}
+	ctx, ok := value.([]byte)
+	if !ok || !bytes.Equal(ctx, o.ctx) {
+		return nil, errors.New("Experimental context header does not match the expected context")
+	}
+	return ctx, nil
+}
@@ -0,0 +1,93 @@
+package cose
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+type COSEContextTestVector struct {
+	Ctx string `json:"experimental_ctx"`
+	COSETestVector
+}
+
+var experimental_ctx = []byte("draft-ietf-cose-dilithium experimental context")
+
+// TestSign1ExperimentalContext calls cose.Sign1 with an experimental context
+// string and confirms the result only verifies with the same context
+func TestSign1ExperimentalContext(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		name, _ := AlgorithmToSuite(alg)
+		var private_key, _ = GenerateKey(alg, seed[:])
+		var public_key, _ = PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		var header = Header{
+			Alg: key.Alg,
+			Kid: key.Kid,
+		}
+		signature, err := Sign1(private_key, header, payload, WithExperimentalContext(experimental_ctx))
+		if err != nil {
+			t.Fatalf("Signing %s with context failed: %v", name, err)
+		}
+		verified, verify_error := VerifySign1(public_key, signature, ExpectExperimentalContext(experimental_ctx))
+		if verify_error != nil {
+			t.Fatalf("Verification %s with context failed: %v", name, verify_error)
+		}
+		if string(verified.Payload) != string(payload) {
+			t.Fatalf("Invalid payload")
+		}
+		_, verify_error = VerifySign1(public_key, signature)
+		if verify_error == nil {
+			t.Fatalf("Verified %s with context without opting in", name)
+		}
+		_, verify_error = VerifySign1(public_key, signature, ExpectExperimentalContext([]byte("other context")))
+		if verify_error == nil {
+			t.Fatalf("Verified %s with a different context", name)
+		}
+		plain, _ := Sign1(private_key, header, payload)
+		_, verify_error = VerifySign1(public_key, plain, ExpectExperimentalContext(experimental_ctx))
+		if verify_error == nil {
+			t.Fatalf("Verified %s without context header while expecting a context", name)
+		}
+
+		// a context header that does not match the signed context
+		var sign1 cose.Sign1Message
+		sign1.UnmarshalCBOR(signature)
+		sign1.Headers.RawProtected = nil
+		sign1.Headers.Protected[HEADER_LABEL_EXPERIMENTAL_CONTEXT] = []byte("other context")
+		forged, _ := sign1.MarshalCBOR()
+		_, verify_error = VerifySign1(public_key, forged, ExpectExperimentalContext([]byte("other context")))
+		if verify_error == nil {
+			t.Fatalf("Verified %s with a modified context header", name)
+		}
+
+		tbs, _ := ToBeSignedFromSign1(signature)
+		sig, _ := SignatureFromSign1(signature)
+		kd, _ := cbor.Diagnose(private_key)
+		sd, _ := cbor.Diagnose(signature)
+		examples, _ := json.MarshalIndent(COSEContextTestVector{
+			Ctx: hex.EncodeToString(experimental_ctx),
+			COSETestVector: COSETestVector{
+				Priv:      hex.EncodeToString(seed[:]),
+				Key:       hex.EncodeToString(private_key),
+				KeyDiag:   kd,
+				Sign1:     hex.EncodeToString(signature),
+				Sign1Diag: sd,
+				RawTbs:    hex.EncodeToString(tbs),
+				RawSig:    hex.EncodeToString(sig),
+				RawPub:    hex.EncodeToString(key.Pub),
+			},
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(name, "-", "_")+".ctx.cose.json", examples, 0644)
+	}
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	_, err := Sign1(private_key, Header{Alg: ML_DSA_44}, payload, WithExperimentalContext(make([]byte, 256)))
+	if err == nil {
+		t.Fatalf("Signed with a context longer than 255 bytes")
+	}
+}
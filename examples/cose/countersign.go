@@ -0,0 +1,154 @@
+package cose
+
+import (
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+type CountersignatureVerification struct {
+	Header Header
+}
+
+// Countersign adds a full countersignature to a COSE_Sign1. The algorithm
+// of the header defaults to the algorithm of the private key, and must
+// match it when set.
+// see: https://datatracker.ietf.org/doc/html/rfc9338#section-3.1
+func Countersign(private_key []byte, header Header, signature []byte) ([]byte, error) {
+	signer, err := signerFromPrivateKey(private_key)
+	if err != nil {
+		return nil, err
+	}
+	sign1, tags, err := decodeSign1(signature)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != 0 && header.Alg != signer.alg {
+		return nil, errors.New("Header algorithm does not match the key algorithm")
+	}
+	countersignature := cose.NewCountersignature()
+	countersignature.Headers.Protected.SetAlgorithm(signer.alg)
+	if header.Kid != nil {
+		countersignature.Headers.Protected[cose.HeaderLabelKeyID] = header.Kid
+	}
+	err = countersignature.Sign(nil, signer, sign1, nil)
+	if err != nil {
+		return nil, err
+	}
+	if sign1.Headers.Unprotected == nil {
+		sign1.Headers.Unprotected = cose.UnprotectedHeader{}
+	}
+	switch existing := sign1.Headers.Unprotected[cose.HeaderLabelCounterSignatureV2].(type) {
+	case nil:
+		sign1.Headers.Unprotected[cose.HeaderLabelCounterSignatureV2] = countersignature
+	case *cose.Countersignature:
+		sign1.Headers.Unprotected[cose.HeaderLabelCounterSignatureV2] = []*cose.Countersignature{existing, countersignature}
+	case []*cose.Countersignature:
+		sign1.Headers.Unprotected[cose.HeaderLabelCounterSignatureV2] = append(existing, countersignature)
+	default:
+		return nil, errors.New("Malformed countersignature header")
+	}
+	sign1.Headers.RawUnprotected = nil
+	return encodeSign1(&sign1, tags)
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc9338#section-3.2
+func Countersign0(private_key []byte, signature []byte) ([]byte, error) {
+	signer, err := signerFromPrivateKey(private_key)
+	if err != nil {
+		return nil, err
+	}
+	sign1, tags, err := decodeSign1(signature)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := sign1.Headers.Unprotected[cose.HeaderLabelCounterSignature0V2]; exists {
+		return nil, errors.New("COSE_Sign1 already has an abbreviated countersignature")
+	}
+	countersignature, err := cose.Countersign0(nil, signer, sign1, nil)
+	if err != nil {
+		return nil, err
+	}
+	if sign1.Headers.Unprotected == nil {
+		sign1.Headers.Unprotected = cose.UnprotectedHeader{}
+	}
+	sign1.Headers.Unprotected[cose.HeaderLabelCounterSignature0V2] = countersignature
+	sign1.Headers.RawUnprotected = nil
+	return encodeSign1(&sign1, tags)
+}
+
+func CountersignaturesFromSign1(signature []byte) ([]*cose.Countersignature, error) {
+	sign1, _, err := decodeSign1(signature)
+	if err != nil {
+		return nil, err
+	}
+	switch countersignatures := sign1.Headers.Unprotected[cose.HeaderLabelCounterSignatureV2].(type) {
+	case nil:
+		return nil, nil
+	case *cose.Countersignature:
+		return []*cose.Countersignature{countersignatures}, nil
+	case []*cose.Countersignature:
+		return countersignatures, nil
+	default:
+		return nil, errors.New("Malformed countersignature header")
+	}
+}
+
+func VerifyCountersignature(public_key []byte, signature []byte) (CountersignatureVerification, error) {
+	var verified = CountersignatureVerification{}
+	var key AKPKey
+	err := cbor.Unmarshal(public_key, &key)
+	if err != nil {
+		return verified, errors.New("Failed to decode cose key")
+	}
+	verifier, err := verifierFromPublicKey(public_key)
+	if err != nil {
+		return verified, err
+	}
+	sign1, _, err := decodeSign1(signature)
+	if err != nil {
+		return verified, err
+	}
+	countersignatures, err := CountersignaturesFromSign1(signature)
+	if err != nil {
+		return verified, err
+	}
+	if len(countersignatures) == 0 {
+		return verified, errors.New("COSE_Sign1 has no countersignatures")
+	}
+	for _, countersignature := range countersignatures {
+		alg, err := countersignature.Headers.Protected.Algorithm()
+		if err != nil || alg != key.Alg {
+			continue
+		}
+		kid, _ := countersignature.Headers.Protected[cose.HeaderLabelKeyID].([]byte)
+		if key.Kid != nil && kid != nil && string(kid) != string(key.Kid) {
+			continue
+		}
+		if countersignature.Verify(verifier, sign1, nil) == nil {
+			verified.Header = Header{
+				Alg: alg,
+				Kid: kid,
+			}
+			return verified, nil
+		}
+	}
+	return verified, errors.New("Countersignature not from public key")
+}
+
+func VerifyCountersignature0(public_key []byte, signature []byte) error {
+	verifier, err := verifierFromPublicKey(public_key)
+	if err != nil {
+		return err
+	}
+	sign1, _, err := decodeSign1(signature)
+	if err != nil {
+		return err
+	}
+	countersignature, ok := sign1.Headers.Unprotected[cose.HeaderLabelCounterSignature0V2].([]byte)
+	if !ok {
+		return errors.New("COSE_Sign1 has no abbreviated countersignature")
+	}
+	return cose.VerifyCountersign0(verifier, sign1, nil, countersignature)
+}
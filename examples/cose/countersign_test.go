@@ -0,0 +1,152 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+type COSECountersignTestVector struct {
+	NotaryPriv      string `json:"notary_priv"`
+	NotaryKey       string `json:"notary_key"`
+	Sign1           string `json:"sign1"`
+	Countersigned   string `json:"countersigned_sign1"`
+	CountersignDiag string `json:"countersigned_sign1_diag"`
+	RawCountersig   string `json:"raw_countersignature"`
+	RawCountersig0  string `json:"raw_countersignature0"`
+}
+
+var notary_seed = bytes.Repeat([]byte{1}, 32)
+
+// TestCountersign calls cose.Countersign and cose.Countersign0 on the
+// ML-DSA example messages and confirms the countersignatures verify
+func TestCountersign(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		name, _ := AlgorithmToSuite(alg)
+		file_name := "examples/" + strings.ReplaceAll(name, "-", "_")
+		example, err := os.ReadFile(file_name + ".cose.json")
+		if err != nil {
+			t.Fatalf("Failed to read %s example", name)
+		}
+		var vector COSETestVector
+		json.Unmarshal(example, &vector)
+		signature, _ := hex.DecodeString(vector.Sign1)
+		issuer_private_key, _ := hex.DecodeString(vector.Key)
+		issuer_public_key, _ := PublicKeyFromPrivateKey(issuer_private_key)
+
+		notary_private_key, _ := GenerateKey(alg, notary_seed)
+		notary_public_key, _ := PublicKeyFromPrivateKey(notary_private_key)
+		notary_key, _ := DecodeKey(notary_private_key)
+
+		countersigned, err := Countersign(notary_private_key, Header{
+			Alg: notary_key.Alg,
+			Kid: notary_key.Kid,
+		}, signature)
+		if err != nil {
+			t.Fatalf("Countersigning %s failed: %v", name, err)
+		}
+		countersigned, err = Countersign0(notary_private_key, countersigned)
+		if err != nil {
+			t.Fatalf("Abbreviated countersigning %s failed: %v", name, err)
+		}
+		verified, err := VerifyCountersignature(notary_public_key, countersigned)
+		if err != nil {
+			t.Fatalf("Countersignature verification %s failed: %v", name, err)
+		}
+		if verified.Header.Alg != alg || !bytes.Equal(verified.Header.Kid, notary_key.Kid) {
+			t.Fatalf("Invalid countersignature header")
+		}
+		err = VerifyCountersignature0(notary_public_key, countersigned)
+		if err != nil {
+			t.Fatalf("Abbreviated countersignature verification %s failed: %v", name, err)
+		}
+		_, err = VerifySign1(issuer_public_key, countersigned)
+		if err != nil {
+			t.Fatalf("Countersigned message no longer verifies")
+		}
+		_, err = VerifyCountersignature(issuer_public_key, countersigned)
+		if err == nil {
+			t.Fatalf("Countersignature verified with the wrong key")
+		}
+		err = VerifyCountersignature0(issuer_public_key, countersigned)
+		if err == nil {
+			t.Fatalf("Abbreviated countersignature verified with the wrong key")
+		}
+		_, err = VerifyCountersignature(notary_public_key, signature)
+		if err == nil {
+			t.Fatalf("Verified a message without countersignatures")
+		}
+
+		var sign1 cose.Sign1Message
+		sign1.UnmarshalCBOR(countersigned)
+		countersignature := sign1.Headers.Unprotected[cose.HeaderLabelCounterSignatureV2].(*cose.Countersignature)
+		countersignature0 := sign1.Headers.Unprotected[cose.HeaderLabelCounterSignature0V2].([]byte)
+		cd, _ := cbor.Diagnose(countersigned)
+		examples, _ := json.MarshalIndent(COSECountersignTestVector{
+			NotaryPriv:      hex.EncodeToString(notary_seed),
+			NotaryKey:       hex.EncodeToString(notary_private_key),
+			Sign1:           vector.Sign1,
+			Countersigned:   hex.EncodeToString(countersigned),
+			CountersignDiag: cd,
+			RawCountersig:   hex.EncodeToString(countersignature.Signature),
+			RawCountersig0:  hex.EncodeToString(countersignature0),
+		}, "", "  ")
+		_ = os.WriteFile(file_name+".countersign.cose.json", examples, 0644)
+	}
+}
+
+// TestCountersignMultiple confirms several full countersignatures can be
+// added to the same message and each verifies with its own key
+func TestCountersignMultiple(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	key, _ := DecodeKey(private_key)
+	signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+
+	notary_1, _ := GenerateKey(ML_DSA_65, notary_seed)
+	notary_2, _ := GenerateKey(ML_DSA_87, notary_seed)
+	for _, notary := range [][]byte{notary_1, notary_2} {
+		notary_key, _ := DecodeKey(notary)
+		var err error
+		signature, err = Countersign(notary, Header{Alg: notary_key.Alg, Kid: notary_key.Kid}, signature)
+		if err != nil {
+			t.Fatalf("Countersigning failed: %v", err)
+		}
+	}
+	countersignatures, _ := CountersignaturesFromSign1(signature)
+	if len(countersignatures) != 2 {
+		t.Fatalf("Invalid countersignature count (%d), want 2", len(countersignatures))
+	}
+	for _, notary := range [][]byte{notary_1, notary_2} {
+		public_key, _ := PublicKeyFromPrivateKey(notary)
+		_, err := VerifyCountersignature(public_key, signature)
+		if err != nil {
+			t.Fatalf("Countersignature verification failed: %v", err)
+		}
+	}
+	_, err := Countersign(notary_1, Header{Alg: ML_DSA_87}, signature)
+	if err == nil {
+		t.Fatalf("Countersigned with a header algorithm that does not match the key")
+	}
+	without_alg, err := Countersign(notary_1, Header{}, signature)
+	if err != nil {
+		t.Fatalf("Countersigning without a header algorithm failed: %v", err)
+	}
+	countersignatures, _ = CountersignaturesFromSign1(without_alg)
+	if alg, _ := countersignatures[2].Headers.Protected.Algorithm(); alg != ML_DSA_65 {
+		t.Fatalf("Invalid countersignature algorithm %d, want the key algorithm", alg)
+	}
+	signature, err = Countersign0(notary_1, signature)
+	if err != nil {
+		t.Fatalf("Abbreviated countersigning failed: %v", err)
+	}
+	_, err = Countersign0(notary_2, signature)
+	if err == nil {
+		t.Fatalf("Added a second abbreviated countersignature")
+	}
+}
@@ -0,0 +1,247 @@
+package cose
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc8392#section-3.1
+const (
+	CWT_CLAIM_ISS = 1
+	CWT_CLAIM_SUB = 2
+	CWT_CLAIM_AUD = 3
+	CWT_CLAIM_EXP = 4
+	CWT_CLAIM_NBF = 5
+	CWT_CLAIM_IAT = 6
+	CWT_CLAIM_CTI = 7
+)
+
+// Claims is a CWT Claims Set. Zero values are omitted, custom claims are
+// keyed by integer or text string claim keys.
+type Claims struct {
+	Issuer     string
+	Subject    string
+	Audience   string
+	Expiration time.Time
+	NotBefore  time.Time
+	IssuedAt   time.Time
+	CWTID      []byte
+	Custom     map[any]any
+}
+
+type CWTValidation struct {
+	// Now defaults to time.Now
+	Now    func() time.Time
+	Leeway time.Duration
+	// Issuer and Audience are required to match when set. A CWT with an
+	// audience is rejected when no Audience is expected.
+	Issuer   string
+	Audience string
+}
+
+func (c Claims) MarshalCBOR() ([]byte, error) {
+	claims := map[any]any{}
+	for key, value := range c.Custom {
+		switch key := key.(type) {
+		case int:
+			claims[int64(key)] = value
+		case int64, string:
+			claims[key] = value
+		default:
+			return nil, errors.New("CWT claim keys must be integers or text strings")
+		}
+	}
+	for key := range claims {
+		if label, ok := key.(int64); ok && label >= CWT_CLAIM_ISS && label <= CWT_CLAIM_CTI {
+			return nil, fmt.Errorf("Custom CWT claims must not contain the registered claim %d", label)
+		}
+	}
+	if c.Issuer != "" {
+		claims[int64(CWT_CLAIM_ISS)] = c.Issuer
+	}
+	if c.Subject != "" {
+		claims[int64(CWT_CLAIM_SUB)] = c.Subject
+	}
+	if c.Audience != "" {
+		claims[int64(CWT_CLAIM_AUD)] = c.Audience
+	}
+	if !c.Expiration.IsZero() {
+		claims[int64(CWT_CLAIM_EXP)] = c.Expiration.Unix()
+	}
+	if !c.NotBefore.IsZero() {
+		claims[int64(CWT_CLAIM_NBF)] = c.NotBefore.Unix()
+	}
+	if !c.IssuedAt.IsZero() {
+		claims[int64(CWT_CLAIM_IAT)] = c.IssuedAt.Unix()
+	}
+	if c.CWTID != nil {
+		claims[int64(CWT_CLAIM_CTI)] = c.CWTID
+	}
+	em, _ := cbor.CoreDetEncOptions().EncMode()
+	return em.Marshal(claims)
+}
+
+func (c *Claims) UnmarshalCBOR(data []byte) error {
+	decOpts := cbor.DecOptions{
+		DupMapKey: cbor.DupMapKeyEnforcedAPF, // duplicated key not allowed
+		IntDec:    cbor.IntDecConvertSigned,  // decode CBOR uint/int to Go int64
+	}
+	decMode, _ := decOpts.DecMode()
+	var claims map[any]cbor.RawMessage
+	err := decMode.Unmarshal(data, &claims)
+	if err != nil {
+		return errors.New("Failed to decode CWT claims")
+	}
+	*c = Claims{}
+	for key, value := range claims {
+		var claim_error error
+		switch key {
+		case int64(CWT_CLAIM_ISS):
+			claim_error = decMode.Unmarshal(value, &c.Issuer)
+		case int64(CWT_CLAIM_SUB):
+			claim_error = decMode.Unmarshal(value, &c.Subject)
+		case int64(CWT_CLAIM_AUD):
+			claim_error = decMode.Unmarshal(value, &c.Audience)
+		case int64(CWT_CLAIM_EXP):
+			c.Expiration, claim_error = decodeNumericDate(decMode, value)
+		case int64(CWT_CLAIM_NBF):
+			c.NotBefore, claim_error = decodeNumericDate(decMode, value)
+		case int64(CWT_CLAIM_IAT):
+			c.IssuedAt, claim_error = decodeNumericDate(decMode, value)
+		case int64(CWT_CLAIM_CTI):
+			claim_error = decMode.Unmarshal(value, &c.CWTID)
+		default:
+			switch key.(type) {
+			case int64, string:
+			default:
+				return errors.New("CWT claim keys must be integers or text strings")
+			}
+			var custom any
+			claim_error = decMode.Unmarshal(value, &custom)
+			if c.Custom == nil {
+				c.Custom = map[any]any{}
+			}
+			c.Custom[key] = custom
+		}
+		if claim_error != nil {
+			return errors.New("Failed to decode CWT claim")
+		}
+	}
+	return nil
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc8392#section-2
+// max_numeric_date bounds NumericDates to integers a float64 represents
+// exactly, so converting them to time.Time cannot overflow.
+const max_numeric_date = 1 << 53
+
+func decodeNumericDate(decMode cbor.DecMode, value cbor.RawMessage) (time.Time, error) {
+	var date any
+	err := decMode.Unmarshal(value, &date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch date := date.(type) {
+	case int64:
+		if date > max_numeric_date || date < -max_numeric_date {
+			return time.Time{}, errors.New("NumericDate is out of range")
+		}
+		return time.Unix(date, 0), nil
+	case float64:
+		// NaN and infinities are rejected too
+		if !(math.Abs(date) <= max_numeric_date) {
+			return time.Time{}, errors.New("NumericDate is out of range")
+		}
+		seconds, fraction := math.Modf(date)
+		return time.Unix(int64(seconds), int64(fraction*float64(time.Second))), nil
+	default:
+		return time.Time{}, errors.New("NumericDate must be a number")
+	}
+}
+
+func validateClaimTimes(claims Claims, clock func() time.Time, leeway time.Duration) error {
+	now := time.Now()
+	if clock != nil {
+		now = clock()
+	}
+	if !claims.Expiration.IsZero() && !now.Before(claims.Expiration.Add(leeway)) {
+		return errors.New("CWT has expired")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(leeway).Before(claims.NotBefore) {
+		return errors.New("CWT is not yet valid")
+	}
+	return nil
+}
+
+func ValidateClaims(claims Claims, validation CWTValidation) error {
+	err := validateClaimTimes(claims, validation.Now, validation.Leeway)
+	if err != nil {
+		return err
+	}
+	if validation.Issuer != "" && claims.Issuer != validation.Issuer {
+		return errors.New("CWT issuer is not the expected issuer")
+	}
+	if claims.Audience != "" && claims.Audience != validation.Audience {
+		return errors.New("CWT audience is not the expected audience")
+	}
+	if validation.Audience != "" && claims.Audience == "" {
+		return errors.New("CWT is missing the audience")
+	}
+	return nil
+}
+
+// IssueCWT signs a CWT Claims Set into a tagged COSE_Sign1.
+func IssueCWT(private_key []byte, header Header, claims Claims, opts ...SignOption) ([]byte, error) {
+	o := newSignOptions(opts)
+	if o.untagged {
+		return nil, errors.New("CWT must be a tagged COSE_Sign1")
+	}
+	payload, err := claims.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	return Sign1(private_key, header, payload, opts...)
+}
+
+// VerifyCWT verifies a CWT and validates its claims.
+func VerifyCWT(public_key []byte, cwt []byte, validation CWTValidation, opts ...VerifyOption) (Claims, error) {
+	var claims Claims
+	verified, err := VerifySign1(public_key, cwt, slices.Concat(opts, []VerifyOption{RequireTag(TAG_COSE_SIGN1)})...)
+	if err != nil {
+		return claims, err
+	}
+	err = claims.UnmarshalCBOR(verified.Payload)
+	if err != nil {
+		return claims, err
+	}
+	err = ValidateClaims(claims, validation)
+	if err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc9597#section-2
+func headerForVerification(o verifyOptions, sign1 *cose.Sign1Message) (Header, error) {
+	header := headerFromSign1(sign1)
+	if _, exists := sign1.Headers.Unprotected[cose.HeaderLabelCWTClaims]; exists {
+		return header, errors.New("CWT Claims header must be protected")
+	}
+	if _, exists := sign1.Headers.Protected[cose.HeaderLabelCWTClaims]; !exists {
+		return header, nil
+	}
+	if header.CWTClaims == nil {
+		return header, errors.New("Malformed CWT Claims header")
+	}
+	err := validateClaimTimes(*header.CWTClaims, o.now, o.leeway)
+	if err != nil {
+		return header, err
+	}
+	return header, nil
+}
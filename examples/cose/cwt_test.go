@@ -0,0 +1,262 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+type COSECWTTestVector struct {
+	Priv       string `json:"priv"`
+	Key        string `json:"key"`
+	Claims     string `json:"claims"`
+	ClaimsDiag string `json:"claims_diag"`
+	CWT        string `json:"cwt"`
+	CWTDiag    string `json:"cwt_diag"`
+}
+
+var cwt_claims = Claims{
+	Issuer:     "coap://as.example.com",
+	Subject:    "erikw",
+	Audience:   "coap://light.example.com",
+	Expiration: time.Unix(1444064944, 0),
+	NotBefore:  time.Unix(1443944944, 0),
+	IssuedAt:   time.Unix(1443944944, 0),
+	CWTID:      []byte{0x0b, 0x71},
+	Custom: map[any]any{
+		-65537: "ML-DSA",
+	},
+}
+
+var cwt_validation = CWTValidation{
+	Now:      func() time.Time { return time.Unix(1444000000, 0) },
+	Issuer:   "coap://as.example.com",
+	Audience: "coap://light.example.com",
+}
+
+// TestCWT calls cose.IssueCWT and cose.VerifyCWT for each ML-DSA level
+// and confirms the claims round trip
+func TestCWT(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		name, _ := AlgorithmToSuite(alg)
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		cwt, err := IssueCWT(private_key, Header{Alg: key.Alg, Kid: key.Kid}, cwt_claims, WithCWTTag())
+		if err != nil {
+			t.Fatalf("Issuing %s CWT failed: %v", name, err)
+		}
+		tags, _, _ := TagsFromMessage(cwt)
+		if len(tags) != 2 || tags[0] != TAG_CWT || tags[1] != TAG_COSE_SIGN1 {
+			t.Fatalf("Invalid CWT tags %v", tags)
+		}
+		claims, err := VerifyCWT(public_key, cwt, cwt_validation)
+		if err != nil {
+			t.Fatalf("Verifying %s CWT failed: %v", name, err)
+		}
+		if claims.Issuer != cwt_claims.Issuer || claims.Subject != cwt_claims.Subject || claims.Audience != cwt_claims.Audience {
+			t.Fatalf("Invalid CWT string claims")
+		}
+		if !claims.Expiration.Equal(cwt_claims.Expiration) || !claims.NotBefore.Equal(cwt_claims.NotBefore) || !claims.IssuedAt.Equal(cwt_claims.IssuedAt) {
+			t.Fatalf("Invalid CWT date claims")
+		}
+		if !bytes.Equal(claims.CWTID, cwt_claims.CWTID) || claims.Custom[int64(-65537)] != "ML-DSA" {
+			t.Fatalf("Invalid CWT claims")
+		}
+
+		encoded_claims, _ := cwt_claims.MarshalCBOR()
+		claims_diag, _ := cbor.Diagnose(encoded_claims)
+		cwt_diag, _ := cbor.Diagnose(cwt)
+		examples, _ := json.MarshalIndent(COSECWTTestVector{
+			Priv:       hex.EncodeToString(seed[:]),
+			Key:        hex.EncodeToString(private_key),
+			Claims:     hex.EncodeToString(encoded_claims),
+			ClaimsDiag: claims_diag,
+			CWT:        hex.EncodeToString(cwt),
+			CWTDiag:    cwt_diag,
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(name, "-", "_")+".cwt.cose.json", examples, 0644)
+	}
+}
+
+// TestCWTValidation calls cose.ValidateClaims and confirms time, issuer
+// and audience checks honor the clock and leeway
+func TestCWTValidation(t *testing.T) {
+	at := func(seconds int64) func() time.Time {
+		return func() time.Time { return time.Unix(seconds, 0) }
+	}
+	tests := []struct {
+		name       string
+		validation CWTValidation
+		valid      bool
+	}{
+		{"valid", cwt_validation, true},
+		{"expired", CWTValidation{Now: at(1444064944), Issuer: cwt_validation.Issuer, Audience: cwt_validation.Audience}, false},
+		{"expired within leeway", CWTValidation{Now: at(1444064944), Leeway: time.Minute, Issuer: cwt_validation.Issuer, Audience: cwt_validation.Audience}, true},
+		{"not yet valid", CWTValidation{Now: at(1443944943), Issuer: cwt_validation.Issuer, Audience: cwt_validation.Audience}, false},
+		{"not yet valid within leeway", CWTValidation{Now: at(1443944943), Leeway: time.Second, Issuer: cwt_validation.Issuer, Audience: cwt_validation.Audience}, true},
+		{"wrong issuer", CWTValidation{Now: cwt_validation.Now, Issuer: "coap://other.example.com", Audience: cwt_validation.Audience}, false},
+		{"wrong audience", CWTValidation{Now: cwt_validation.Now, Issuer: cwt_validation.Issuer, Audience: "coap://other.example.com"}, false},
+		{"unexpected audience", CWTValidation{Now: cwt_validation.Now, Issuer: cwt_validation.Issuer}, false},
+	}
+	for _, test := range tests {
+		err := ValidateClaims(cwt_claims, test.validation)
+		if (err == nil) != test.valid {
+			t.Fatalf("%s: unexpected validation result %v", test.name, err)
+		}
+	}
+	err := ValidateClaims(Claims{}, CWTValidation{Audience: cwt_validation.Audience})
+	if err == nil {
+		t.Fatalf("Accepted a CWT without an audience")
+	}
+}
+
+// TestCWTRejected confirms CWTs are not accepted untagged, with the wrong
+// key or with malformed claims
+func TestCWTRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	other_private_key, _ := GenerateKey(ML_DSA_44, notary_seed)
+	other_public_key, _ := PublicKeyFromPrivateKey(other_private_key)
+	key, _ := DecodeKey(private_key)
+	header := Header{Alg: key.Alg, Kid: key.Kid}
+
+	_, err := IssueCWT(private_key, header, cwt_claims, Untagged())
+	if err == nil {
+		t.Fatalf("Issued an untagged CWT")
+	}
+	cwt, _ := IssueCWT(private_key, header, cwt_claims)
+	_, err = VerifyCWT(other_public_key, cwt, cwt_validation)
+	if err == nil {
+		t.Fatalf("Verified a CWT with the wrong key")
+	}
+	untagged, _ := Sign1(private_key, header, []byte{0xa0}, Untagged())
+	_, err = VerifyCWT(public_key, untagged, CWTValidation{})
+	if err == nil {
+		t.Fatalf("Verified an untagged CWT")
+	}
+	not_claims, _ := Sign1(private_key, header, payload)
+	_, err = VerifyCWT(public_key, not_claims, CWTValidation{})
+	if err == nil {
+		t.Fatalf("Verified a CWT with malformed claims")
+	}
+	for _, key := range []any{CWT_CLAIM_ISS, int64(CWT_CLAIM_EXP), CWT_CLAIM_CTI} {
+		_, err = IssueCWT(private_key, header, Claims{Custom: map[any]any{key: "tomorrow"}})
+		if err == nil {
+			t.Fatalf("Issued a CWT with the registered claim %v in custom claims", key)
+		}
+	}
+	var claims Claims
+	err = claims.UnmarshalCBOR([]byte{0xa1, 0x04, 0x61, 0x78})
+	if err == nil {
+		t.Fatalf("Decoded a text string NumericDate")
+	}
+	for name, date := range map[string][]byte{
+		"NaN":       {0xf9, 0x7e, 0x00},
+		"Infinity":  {0xf9, 0x7c, 0x00},
+		"-Infinity": {0xf9, 0xfc, 0x00},
+		"1e300":     {0xfb, 0x7e, 0x37, 0xe4, 0x3c, 0x88, 0x00, 0x75, 0x9c},
+		"2^63-1":    {0x1b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	} {
+		nbf := append([]byte{0xa1, 0x05}, date...)
+		err = claims.UnmarshalCBOR(nbf)
+		if err == nil {
+			t.Fatalf("Decoded a %s NumericDate", name)
+		}
+		huge_nbf, _ := Sign1(private_key, header, nbf)
+		_, err = VerifyCWT(public_key, huge_nbf, CWTValidation{Now: cwt_validation.Now})
+		if err == nil {
+			t.Fatalf("Verified a CWT with a %s nbf", name)
+		}
+	}
+}
+
+// TestSign1CWTClaims calls cose.Sign1 with a CWT Claims header and confirms
+// cose.VerifySign1 returns the claims and validates exp and nbf
+func TestSign1CWTClaims(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_65, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	header := Header{
+		Alg: key.Alg,
+		Kid: key.Kid,
+		CWTClaims: &Claims{
+			Issuer:     "https://issuer.example",
+			Subject:    "urn:example:statement",
+			Expiration: time.Unix(1444064944, 0),
+			NotBefore:  time.Unix(1443944944, 0),
+		},
+	}
+	signature, err := Sign1(private_key, header, payload)
+	if err != nil {
+		t.Fatalf("Signing with CWT Claims failed: %v", err)
+	}
+	var sign1 cose.Sign1Message
+	sign1.UnmarshalCBOR(signature)
+	if _, exists := sign1.Headers.Protected[cose.HeaderLabelCWTClaims]; !exists {
+		t.Fatalf("CWT Claims are not in the protected header")
+	}
+	verified, err := VerifySign1(public_key, signature, WithClock(cwt_validation.Now))
+	if err != nil {
+		t.Fatalf("Verifying with CWT Claims failed: %v", err)
+	}
+	claims := verified.Header.CWTClaims
+	if claims == nil || claims.Issuer != "https://issuer.example" || claims.Subject != "urn:example:statement" {
+		t.Fatalf("Invalid CWT Claims header")
+	}
+	_, err = VerifySign1(public_key, signature)
+	if err == nil {
+		t.Fatalf("Verified an expired CWT Claims header")
+	}
+	after_exp := func() time.Time { return time.Unix(1444064950, 0) }
+	_, err = VerifySign1(public_key, signature, WithClock(after_exp))
+	if err == nil {
+		t.Fatalf("Verified an expired CWT Claims header")
+	}
+	_, err = VerifySign1(public_key, signature, WithClock(after_exp), WithLeeway(time.Minute))
+	if err != nil {
+		t.Fatalf("Leeway was not applied: %v", err)
+	}
+	before_nbf := func() time.Time { return time.Unix(1443944900, 0) }
+	_, err = VerifySign1(public_key, signature, WithClock(before_nbf))
+	if err == nil {
+		t.Fatalf("Verified a CWT Claims header before nbf")
+	}
+
+	detached, _ := Sign1Stream(private_key, header, bytes.NewReader(payload), int64(len(payload)))
+	verified, err = VerifySign1Stream(public_key, detached, bytes.NewReader(payload), int64(len(payload)), WithClock(cwt_validation.Now))
+	if err != nil || verified.Header.CWTClaims == nil {
+		t.Fatalf("Verifying a detached payload with CWT Claims failed: %v", err)
+	}
+	_, err = VerifySign1Stream(public_key, detached, bytes.NewReader(payload), int64(len(payload)), WithClock(after_exp))
+	if err == nil {
+		t.Fatalf("Verified an expired CWT Claims header on a detached payload")
+	}
+}
+
+// TestSign1CWTClaimsUnprotected confirms a CWT Claims header in the
+// unprotected bucket is rejected
+func TestSign1CWTClaimsUnprotected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	var sign1 cose.Sign1Message
+	sign1.UnmarshalCBOR(signature)
+	sign1.Headers.Unprotected = cose.UnprotectedHeader{
+		cose.HeaderLabelCWTClaims: map[any]any{int64(CWT_CLAIM_ISS): "https://issuer.example"},
+	}
+	sign1.Headers.RawUnprotected = nil
+	signature, _ = sign1.MarshalCBOR()
+	_, err := VerifySign1(public_key, signature)
+	if err == nil {
+		t.Fatalf("Verified an unprotected CWT Claims header")
+	}
+}
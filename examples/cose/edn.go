@@ -0,0 +1,671 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// cborItem is a decoded CBOR data item that keeps the order of map entries,
+// so that diagnostic notation matches the encoding.
+type cborItem struct {
+	major byte
+	// unsigned integer, negative integer argument, tag number or simple value
+	arg   uint64
+	float float64
+	// byte or text string content
+	bytes []byte
+	// array elements, map keys and values interleaved, or the tag content
+	items []cborItem
+}
+
+const (
+	cbor_uint   = 0
+	cbor_nint   = 1
+	cbor_bstr   = 2
+	cbor_tstr   = 3
+	cbor_array  = 4
+	cbor_map    = 5
+	cbor_tag    = 6
+	cbor_simple = 7
+	// floats are major type 7, they are kept apart from simple values
+	cbor_float = 8
+
+	cbor_max_depth = 64
+)
+
+func decodeCBORItem(data []byte) (cborItem, error) {
+	item, size, err := decodeItem(data, 0)
+	if err != nil {
+		return item, err
+	}
+	if size != len(data) {
+		return item, errors.New("Unexpected data after cbor item")
+	}
+	return item, nil
+}
+
+func decodeItem(data []byte, depth int) (cborItem, int, error) {
+	var item cborItem
+	if depth > cbor_max_depth {
+		return item, 0, errors.New("Cbor item is nested too deeply")
+	}
+	if len(data) == 0 {
+		return item, 0, errors.New("Truncated cbor item")
+	}
+	item.major = data[0] >> 5
+	ai := data[0] & 0x1f
+	size := 1
+	switch {
+	case ai < 24:
+		item.arg = uint64(ai)
+	case ai <= 27:
+		n := 1 << (ai - 24)
+		if len(data) < 1+n {
+			return item, 0, errors.New("Truncated cbor item")
+		}
+		for _, b := range data[1 : 1+n] {
+			item.arg = item.arg<<8 | uint64(b)
+		}
+		size += n
+	case ai == 31:
+		return item, 0, errors.New("Indefinite length cbor items are not supported")
+	default:
+		return item, 0, errors.New("Malformed cbor item")
+	}
+	remaining := uint64(len(data) - size)
+	switch item.major {
+	case cbor_bstr, cbor_tstr:
+		if item.arg > remaining {
+			return item, 0, errors.New("Truncated cbor item")
+		}
+		item.bytes = data[size : size+int(item.arg)]
+		size += int(item.arg)
+	case cbor_array, cbor_map:
+		count := item.arg
+		if item.major == cbor_map {
+			if count > remaining/2 {
+				return item, 0, errors.New("Truncated cbor item")
+			}
+			count *= 2
+		}
+		if count > remaining {
+			return item, 0, errors.New("Truncated cbor item")
+		}
+		item.items = make([]cborItem, count)
+		for i := range item.items {
+			child, child_size, err := decodeItem(data[size:], depth+1)
+			if err != nil {
+				return item, 0, err
+			}
+			item.items[i] = child
+			size += child_size
+		}
+	case cbor_tag:
+		child, child_size, err := decodeItem(data[size:], depth+1)
+		if err != nil {
+			return item, 0, err
+		}
+		item.items = []cborItem{child}
+		size += child_size
+	case cbor_simple:
+		switch ai {
+		case 25:
+			item.major, item.float = cbor_float, float16ToFloat64(uint16(item.arg))
+		case 26:
+			item.major, item.float = cbor_float, float64(math.Float32frombits(uint32(item.arg)))
+		case 27:
+			item.major, item.float = cbor_float, math.Float64frombits(item.arg)
+		}
+	}
+	return item, size, nil
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(mant+1024, exp-25)
+	}
+}
+
+// integer returns the value of an unsigned or negative integer item.
+func (item cborItem) integer() (int64, bool) {
+	switch {
+	case item.major == cbor_uint && item.arg <= math.MaxInt64:
+		return int64(item.arg), true
+	case item.major == cbor_nint && item.arg <= math.MaxInt64:
+		return -1 - int64(item.arg), true
+	default:
+		return 0, false
+	}
+}
+
+type ednOptions struct {
+	truncate int
+}
+
+type EDNOption func(*ednOptions)
+
+// WithTruncation shows only the first and last n bytes of byte strings that
+// are longer than 2n bytes, as in the figures of the draft.
+func WithTruncation(n int) EDNOption {
+	return func(o *ednOptions) {
+		o.truncate = n
+	}
+}
+
+// ednSchema names the labels of a map, and the values of some labels.
+type ednSchema struct {
+	labels map[int64]string
+	values map[int64]map[int64]string
+	nested map[int64]func(p *ednPrinter, value cborItem, indent int) string
+}
+
+var algorithm_names = map[int64]string{
+	ML_DSA_44:             "ML-DSA-44",
+	ML_DSA_65:             "ML-DSA-65",
+	ML_DSA_87:             "ML-DSA-87",
+	HASH_ML_DSA_44_SHA512: "HashML-DSA-44-SHA512",
+	HASH_ML_DSA_65_SHA512: "HashML-DSA-65-SHA512",
+	HASH_ML_DSA_87_SHA512: "HashML-DSA-87-SHA512",
+	-7:                    "ES256",
+	-35:                   "ES384",
+	-36:                   "ES512",
+	-8:                    "EdDSA",
+	int64(SHA_256):        "SHA-256",
+	int64(SHA_384):        "SHA-384",
+	int64(SHA_512):        "SHA-512",
+}
+
+// see: https://www.iana.org/assignments/cose/cose.xhtml#key-common-parameters
+var key_schema = ednSchema{
+	labels: map[int64]string{
+		1: "kty",
+		2: "kid",
+		3: "alg",
+		4: "key_ops",
+		5: "Base IV",
+	},
+	values: map[int64]map[int64]string{
+		1: {1: "OKP", 2: "EC2", 4: "Symmetric", AKP: "AKP"},
+		3: algorithm_names,
+	},
+}
+
+var curve_names = map[int64]string{
+	1: "P-256",
+	2: "P-384",
+	3: "P-521",
+	4: "X25519",
+	5: "X448",
+	6: "Ed25519",
+	7: "Ed448",
+}
+
+// key_type_schemas names the key type parameters, which reuse the same
+// negative labels with a different meaning for each kty.
+//
+// see: https://www.iana.org/assignments/cose/cose.xhtml#key-type-parameters
+var key_type_schemas = map[int64]ednSchema{
+	1: {
+		labels: map[int64]string{-1: "crv", -2: "x", -4: "d"},
+		values: map[int64]map[int64]string{-1: curve_names},
+	},
+	2: {
+		labels: map[int64]string{-1: "crv", -2: "x", -3: "y", -4: "d"},
+		values: map[int64]map[int64]string{-1: curve_names},
+	},
+	4: {
+		labels: map[int64]string{-1: "k"},
+	},
+	AKP: {
+		labels: map[int64]string{-1: "pub", -2: "priv"},
+	},
+}
+
+// keySchema returns the key schema with the parameters of the key type of
+// a COSE_Key, or only the common parameters if the key type is unknown.
+func keySchema(item cborItem) ednSchema {
+	var kty int64
+	for i := 0; i+1 < len(item.items); i += 2 {
+		if label, ok := item.items[i].integer(); ok && label == 1 {
+			kty, _ = item.items[i+1].integer()
+		}
+	}
+	key_type, exists := key_type_schemas[kty]
+	if !exists {
+		return key_schema
+	}
+	schema := ednSchema{
+		labels: map[int64]string{},
+		values: map[int64]map[int64]string{},
+	}
+	for _, from := range []ednSchema{key_schema, key_type} {
+		for label, name := range from.labels {
+			schema.labels[label] = name
+		}
+		for label, names := range from.values {
+			schema.values[label] = names
+		}
+	}
+	return schema
+}
+
+// see: https://www.iana.org/assignments/cose/cose.xhtml#header-parameters
+var header_schema = ednSchema{
+	labels: map[int64]string{
+		1:                                  "alg",
+		2:                                  "crit",
+		3:                                  "content type",
+		4:                                  "kid",
+		5:                                  "IV",
+		6:                                  "Partial IV",
+		11:                                 "countersignature",
+		12:                                 "countersignature0",
+		15:                                 "CWT Claims",
+		16:                                 "typ",
+		HEADER_LABEL_PAYLOAD_HASH_ALG:      "payload_hash_alg",
+		HEADER_LABEL_PREIMAGE_CONTENT_TYPE: "preimage content type",
+		HEADER_LABEL_PAYLOAD_LOCATION:      "payload location",
+		HEADER_LABEL_RECEIPTS:              "receipts",
+		HEADER_LABEL_VDS:                   "vds",
+		HEADER_LABEL_VDP:                   "vdp",
+		HEADER_LABEL_EXPERIMENTAL_CONTEXT:  "experimental ctx",
+	},
+	values: map[int64]map[int64]string{
+		1:                             algorithm_names,
+		HEADER_LABEL_PAYLOAD_HASH_ALG: algorithm_names,
+		HEADER_LABEL_VDS:              {VDS_RFC9162_SHA256: "RFC9162_SHA256"},
+	},
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc8392#section-3.1
+var claims_schema = ednSchema{
+	labels: map[int64]string{
+		CWT_CLAIM_ISS: "iss",
+		CWT_CLAIM_SUB: "sub",
+		CWT_CLAIM_AUD: "aud",
+		CWT_CLAIM_EXP: "exp",
+		CWT_CLAIM_NBF: "nbf",
+		CWT_CLAIM_IAT: "iat",
+		CWT_CLAIM_CTI: "cti",
+	},
+}
+
+// see: https://datatracker.ietf.org/doc/html/draft-ietf-cose-merkle-tree-proofs#section-5.2
+var vdp_schema = ednSchema{
+	labels: map[int64]string{
+		VDP_INCLUSION_PROOFS: "inclusion proofs",
+	},
+	nested: map[int64]func(p *ednPrinter, value cborItem, indent int) string{
+		VDP_INCLUSION_PROOFS: func(p *ednPrinter, value cborItem, indent int) string {
+			if value.major != cbor_array {
+				return p.item(value, indent)
+			}
+			var proofs []string
+			for _, proof := range value.items {
+				proofs = append(proofs, p.embedded(proof, indent+1, p.item))
+			}
+			return p.list("[", proofs, "]", indent)
+		},
+	},
+}
+
+func init() {
+	// the nested printers refer back to the header schema
+	header_schema.nested = map[int64]func(p *ednPrinter, value cborItem, indent int) string{
+		15: func(p *ednPrinter, value cborItem, indent int) string {
+			return p.schemaMap(value, claims_schema, indent)
+		},
+		HEADER_LABEL_VDP: func(p *ednPrinter, value cborItem, indent int) string {
+			return p.schemaMap(value, vdp_schema, indent)
+		},
+		HEADER_LABEL_RECEIPTS: func(p *ednPrinter, value cborItem, indent int) string {
+			if value.major != cbor_array {
+				return p.item(value, indent)
+			}
+			var receipts []string
+			for _, receipt := range value.items {
+				receipts = append(receipts, p.embedded(receipt, indent+1, p.sign1))
+			}
+			return p.list("[", receipts, "]", indent)
+		},
+	}
+}
+
+type ednPrinter struct {
+	o ednOptions
+}
+
+const edn_indent = "   "
+
+func (p *ednPrinter) list(open string, entries []string, end string, indent int) string {
+	if len(entries) == 0 {
+		return open + end
+	}
+	var b strings.Builder
+	b.WriteString(open + "\n")
+	for i, entry := range entries {
+		b.WriteString(strings.Repeat(edn_indent, indent+1) + entry)
+		if i != len(entries)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Repeat(edn_indent, indent) + end)
+	return b.String()
+}
+
+func (p *ednPrinter) byteString(data []byte) string {
+	n := p.o.truncate
+	if n > 0 && len(data) > 2*n {
+		return "h'" + hex.EncodeToString(data[:n]) + "..." + hex.EncodeToString(data[len(data)-n:]) + "'"
+	}
+	return "h'" + hex.EncodeToString(data) + "'"
+}
+
+func textString(text []byte) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(string(text))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (p *ednPrinter) item(item cborItem, indent int) string {
+	switch item.major {
+	case cbor_uint:
+		return strconv.FormatUint(item.arg, 10)
+	case cbor_nint:
+		if item.arg == math.MaxUint64 {
+			return "-18446744073709551616"
+		}
+		return "-" + strconv.FormatUint(item.arg+1, 10)
+	case cbor_bstr:
+		return p.byteString(item.bytes)
+	case cbor_tstr:
+		return textString(item.bytes)
+	case cbor_array:
+		var entries []string
+		for _, child := range item.items {
+			entries = append(entries, p.item(child, indent+1))
+		}
+		return p.list("[", entries, "]", indent)
+	case cbor_map:
+		return p.schemaMap(item, ednSchema{}, indent)
+	case cbor_tag:
+		return strconv.FormatUint(item.arg, 10) + "(" + p.item(item.items[0], indent) + ")"
+	case cbor_float:
+		return ednFloat(item.float)
+	default:
+		switch item.arg {
+		case 20:
+			return "false"
+		case 21:
+			return "true"
+		case 22:
+			return "null"
+		case 23:
+			return "undefined"
+		default:
+			return fmt.Sprintf("simple(%d)", item.arg)
+		}
+	}
+}
+
+func ednFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// schemaMap prints a map with one entry per line, annotating the labels
+// and values known to the schema:
+//
+//	/ kty / 1: 7, / AKP /
+func (p *ednPrinter) schemaMap(item cborItem, schema ednSchema, indent int) string {
+	if item.major != cbor_map {
+		return p.item(item, indent)
+	}
+	count := len(item.items) / 2
+	comments := make([]string, count)
+	labels := make([]string, count)
+	values := make([]string, count)
+	value_comments := make([]string, count)
+	comment_width, label_width := 0, 0
+	for i := 0; i < count; i++ {
+		key, value := item.items[2*i], item.items[2*i+1]
+		labels[i] = p.item(key, indent+1)
+		values[i] = p.item(value, indent+1)
+		if label, ok := key.integer(); ok {
+			if name, exists := schema.labels[label]; exists {
+				comments[i] = "/ " + name + " / "
+			}
+			if nested, exists := schema.nested[label]; exists {
+				values[i] = nested(p, value, indent+1)
+			}
+			if number, ok := value.integer(); ok {
+				if name, exists := schema.values[label][number]; exists {
+					value_comments[i] = " / " + name + " /"
+				}
+			}
+		}
+		comment_width = max(comment_width, len(comments[i]))
+		label_width = max(label_width, len(labels[i]))
+	}
+	entries := make([]string, count)
+	for i := range entries {
+		entries[i] = fmt.Sprintf("%-*s%*s: %s", comment_width, comments[i], label_width, labels[i], values[i])
+	}
+	if count == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, entry := range entries {
+		b.WriteString(strings.Repeat(edn_indent, indent+1) + entry)
+		if i != len(entries)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString(value_comments[i] + "\n")
+	}
+	b.WriteString(strings.Repeat(edn_indent, indent) + "}")
+	return b.String()
+}
+
+// embedded prints a byte string containing an encoded CBOR data item as
+// << item >>, falling back to the byte string if it does not decode.
+func (p *ednPrinter) embedded(item cborItem, indent int, print func(item cborItem, indent int) string) string {
+	if item.major != cbor_bstr {
+		return p.item(item, indent)
+	}
+	if len(item.bytes) == 0 {
+		return "h''"
+	}
+	content, err := decodeCBORItem(item.bytes)
+	if err != nil {
+		return p.item(item, indent)
+	}
+	return "<<" + print(content, indent) + ">>"
+}
+
+func (p *ednPrinter) headerMap(item cborItem, indent int) string {
+	return p.schemaMap(item, header_schema, indent)
+}
+
+func (p *ednPrinter) claimsMap(item cborItem, indent int) string {
+	return p.schemaMap(item, claims_schema, indent)
+}
+
+// structure prints a COSE array with a comment naming each element.
+func (p *ednPrinter) structure(names []string, entries []string, indent int) string {
+	for i := range entries {
+		entries[i] = "/ " + names[i] + " / " + entries[i]
+	}
+	return p.list("[", entries, "]", indent)
+}
+
+// tagged prints the tags enclosing a COSE message, naming the innermost.
+func (p *ednPrinter) tagged(item cborItem, name string, tag uint64, indent int, print func(item cborItem, tags []uint64, indent int) (string, error)) (string, error) {
+	var tags []uint64
+	for item.major == cbor_tag {
+		tags = append(tags, item.arg)
+		item = item.items[0]
+	}
+	if len(tags) > 0 && tags[len(tags)-1] != tag {
+		return "", fmt.Errorf("Unexpected tag %d on %s", tags[len(tags)-1], name)
+	}
+	content, err := print(item, tags, indent)
+	if err != nil {
+		return "", err
+	}
+	var prefix, suffix string
+	for i, t := range tags {
+		if i == len(tags)-1 {
+			prefix += "/ " + name + " / "
+		}
+		prefix += strconv.FormatUint(t, 10) + "("
+		suffix += ")"
+	}
+	return prefix + content + suffix, nil
+}
+
+func (p *ednPrinter) sign1(item cborItem, indent int) string {
+	edn, err := p.tagged(item, "COSE_Sign1", TAG_COSE_SIGN1, indent, p.sign1Content)
+	if err != nil {
+		return p.item(item, indent)
+	}
+	return edn
+}
+
+func (p *ednPrinter) sign1Content(item cborItem, tags []uint64, indent int) (string, error) {
+	if item.major != cbor_array || len(item.items) != 4 {
+		return "", errors.New("COSE_Sign1 must be an array of 4 items")
+	}
+	payload := p.item(item.items[2], indent+1)
+	for _, tag := range tags {
+		if tag == TAG_CWT {
+			payload = p.embedded(item.items[2], indent+1, p.claimsMap)
+		}
+	}
+	return p.structure([]string{"protected", "unprotected", "payload", "signature"}, []string{
+		p.embedded(item.items[0], indent+1, p.headerMap),
+		p.headerMap(item.items[1], indent+1),
+		payload,
+		p.item(item.items[3], indent+1),
+	}, indent), nil
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc9052#section-4.1
+func (p *ednPrinter) signContent(item cborItem, tags []uint64, indent int) (string, error) {
+	if item.major != cbor_array || len(item.items) != 4 || item.items[3].major != cbor_array {
+		return "", errors.New("COSE_Sign must be an array of 4 items")
+	}
+	var signatures []string
+	for _, signature := range item.items[3].items {
+		if signature.major != cbor_array || len(signature.items) != 3 {
+			return "", errors.New("COSE_Signature must be an array of 3 items")
+		}
+		signatures = append(signatures, p.structure([]string{"protected", "unprotected", "signature"}, []string{
+			p.embedded(signature.items[0], indent+3, p.headerMap),
+			p.headerMap(signature.items[1], indent+3),
+			p.item(signature.items[2], indent+3),
+		}, indent+2))
+	}
+	return p.structure([]string{"protected", "unprotected", "payload", "signatures"}, []string{
+		p.embedded(item.items[0], indent+1, p.headerMap),
+		p.headerMap(item.items[1], indent+1),
+		p.item(item.items[2], indent+1),
+		p.list("[", signatures, "]", indent+1),
+	}, indent), nil
+}
+
+func newEDNPrinter(opts []EDNOption) *ednPrinter {
+	var p ednPrinter
+	for _, opt := range opts {
+		opt(&p.o)
+	}
+	return &p
+}
+
+// DiagnoseKey prints a COSE_Key in annotated extended diagnostic notation.
+func DiagnoseKey(cose_key []byte, opts ...EDNOption) (string, error) {
+	item, err := decodeCBORItem(cose_key)
+	if err != nil {
+		return "", err
+	}
+	if item.major != cbor_map {
+		return "", errors.New("COSE_Key must be a cbor map")
+	}
+	return newEDNPrinter(opts).schemaMap(item, keySchema(item), 0), nil
+}
+
+// DiagnoseKeySet prints a COSE_KeySet in annotated extended diagnostic
+// notation.
+func DiagnoseKeySet(cose_key_set []byte, opts ...EDNOption) (string, error) {
+	item, err := decodeCBORItem(cose_key_set)
+	if err != nil {
+		return "", err
+	}
+	if item.major != cbor_array {
+		return "", errors.New("COSE_KeySet must be a cbor array")
+	}
+	p := newEDNPrinter(opts)
+	var keys []string
+	for _, key := range item.items {
+		if key.major != cbor_map {
+			return "", errors.New("COSE_Key must be a cbor map")
+		}
+		keys = append(keys, p.schemaMap(key, keySchema(key), 1))
+	}
+	return p.list("[", keys, "]", 0), nil
+}
+
+// DiagnoseSign1 prints a COSE_Sign1 in annotated extended diagnostic
+// notation, with its protected header as an embedded << >> map.
+func DiagnoseSign1(signature []byte, opts ...EDNOption) (string, error) {
+	item, err := decodeCBORItem(signature)
+	if err != nil {
+		return "", err
+	}
+	p := newEDNPrinter(opts)
+	return p.tagged(item, "COSE_Sign1", TAG_COSE_SIGN1, 0, p.sign1Content)
+}
+
+// DiagnoseSign prints a COSE_Sign in annotated extended diagnostic notation.
+func DiagnoseSign(signature []byte, opts ...EDNOption) (string, error) {
+	item, err := decodeCBORItem(signature)
+	if err != nil {
+		return "", err
+	}
+	p := newEDNPrinter(opts)
+	return p.tagged(item, "COSE_Sign", TAG_COSE_SIGN, 0, p.signContent)
+}
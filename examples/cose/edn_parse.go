@@ -0,0 +1,400 @@
+package cose
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// encodeHead encodes the head of a CBOR data item in its shortest form.
+func encodeHead(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= math.MaxUint8:
+		return []byte{major | 24, byte(arg)}
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+	}
+}
+
+type ednParser struct {
+	edn string
+	pos int
+}
+
+// ParseEDN encodes a data item written in extended diagnostic notation,
+// as in the figures of the draft, to CBOR. Comments, h'...' and b64'...'
+// byte strings, tags and embedded << >> CBOR are supported. Truncated byte
+// strings cannot be parsed.
+//
+// see: https://datatracker.ietf.org/doc/draft-ietf-cbor-edn-literals/
+func ParseEDN(edn string) ([]byte, error) {
+	p := ednParser{edn: edn}
+	item, err := p.item(0)
+	if err != nil {
+		return nil, err
+	}
+	err = p.skip()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.edn) {
+		return nil, p.errorf("Unexpected %q after data item", p.edn[p.pos:p.pos+1])
+	}
+	return item, nil
+}
+
+func (p *ednParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("EDN offset %d: "+format, append([]any{p.pos}, args...)...)
+}
+
+// skip moves past whitespace and comments.
+func (p *ednParser) skip() error {
+	for p.pos < len(p.edn) {
+		switch c := p.edn[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '/':
+			end := strings.IndexByte(p.edn[p.pos+1:], '/')
+			if end < 0 {
+				return p.errorf("Unterminated comment")
+			}
+			p.pos += end + 2
+		case c == '#':
+			end := strings.IndexByte(p.edn[p.pos:], '\n')
+			if end < 0 {
+				end = len(p.edn) - p.pos
+			}
+			p.pos += end
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *ednParser) peek() byte {
+	if p.pos < len(p.edn) {
+		return p.edn[p.pos]
+	}
+	return 0
+}
+
+func (p *ednParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.edn[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *ednParser) item(depth int) ([]byte, error) {
+	if depth > cbor_max_depth {
+		return nil, p.errorf("Data item is nested too deeply")
+	}
+	err := p.skip()
+	if err != nil {
+		return nil, err
+	}
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, p.errorf("Expected a data item")
+	case c == '[':
+		return p.array(depth)
+	case c == '{':
+		return p.mapItem(depth)
+	case c == '"':
+		return p.textString()
+	case c == '\'':
+		return p.quotedByteString()
+	case p.consume("<<"):
+		return p.embedded(depth)
+	case p.consume("h'"):
+		return p.encodedByteString(func(s string) ([]byte, error) {
+			return hex.DecodeString(strings.Join(strings.Fields(s), ""))
+		})
+	case p.consume("b64'"):
+		return p.encodedByteString(func(s string) ([]byte, error) {
+			s = strings.TrimRight(strings.Join(strings.Fields(s), ""), "=")
+			s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+			return base64.RawURLEncoding.DecodeString(s)
+		})
+	case p.consume("false"):
+		return []byte{0xf4}, nil
+	case p.consume("true"):
+		return []byte{0xf5}, nil
+	case p.consume("null"):
+		return []byte{0xf6}, nil
+	case p.consume("undefined"):
+		return []byte{0xf7}, nil
+	case p.consume("simple("):
+		return p.simple()
+	case p.consume("NaN"):
+		return []byte{0xf9, 0x7e, 0x00}, nil
+	case p.consume("Infinity"):
+		return []byte{0xf9, 0x7c, 0x00}, nil
+	case p.consume("-Infinity"):
+		return []byte{0xf9, 0xfc, 0x00}, nil
+	case c == '-' || unicode.IsDigit(rune(c)):
+		return p.number(depth)
+	default:
+		return nil, p.errorf("Unexpected %q", string(c))
+	}
+}
+
+// sequence parses items separated by commas up to the end delimiter.
+func (p *ednParser) sequence(end string, each func() error) (int, error) {
+	count := 0
+	for {
+		err := p.skip()
+		if err != nil {
+			return 0, err
+		}
+		if p.consume(end) {
+			return count, nil
+		}
+		if count > 0 {
+			if !p.consume(",") {
+				return 0, p.errorf("Expected \",\" or %q", end)
+			}
+		}
+		err = each()
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+func (p *ednParser) array(depth int) ([]byte, error) {
+	p.pos++
+	var content []byte
+	count, err := p.sequence("]", func() error {
+		item, err := p.item(depth + 1)
+		content = append(content, item...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(encodeHead(cbor_array, uint64(count)), content...), nil
+}
+
+func (p *ednParser) mapItem(depth int) ([]byte, error) {
+	p.pos++
+	var content []byte
+	count, err := p.sequence("}", func() error {
+		key, err := p.item(depth + 1)
+		if err != nil {
+			return err
+		}
+		err = p.skip()
+		if err != nil {
+			return err
+		}
+		if !p.consume(":") {
+			return p.errorf("Expected \":\"")
+		}
+		value, err := p.item(depth + 1)
+		if err != nil {
+			return err
+		}
+		content = append(append(content, key...), value...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(encodeHead(cbor_map, uint64(count)), content...), nil
+}
+
+// embedded parses << item, ... >>, a byte string containing the encoded
+// CBOR sequence.
+func (p *ednParser) embedded(depth int) ([]byte, error) {
+	var content []byte
+	_, err := p.sequence(">>", func() error {
+		item, err := p.item(depth + 1)
+		content = append(content, item...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(encodeHead(cbor_bstr, uint64(len(content))), content...), nil
+}
+
+func (p *ednParser) textString() ([]byte, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.edn) && p.edn[p.pos] != '"' {
+		if p.edn[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.edn) {
+		return nil, p.errorf("Unterminated text string")
+	}
+	p.pos++
+	var text string
+	err := json.Unmarshal([]byte(p.edn[start:p.pos]), &text)
+	if err != nil {
+		return nil, p.errorf("Malformed text string")
+	}
+	return append(encodeHead(cbor_tstr, uint64(len(text))), text...), nil
+}
+
+func (p *ednParser) quotedByteString() ([]byte, error) {
+	p.pos++
+	end := strings.IndexByte(p.edn[p.pos:], '\'')
+	if end < 0 {
+		return nil, p.errorf("Unterminated byte string")
+	}
+	content := p.edn[p.pos : p.pos+end]
+	p.pos += end + 1
+	return append(encodeHead(cbor_bstr, uint64(len(content))), content...), nil
+}
+
+func (p *ednParser) encodedByteString(decode func(string) ([]byte, error)) ([]byte, error) {
+	end := strings.IndexByte(p.edn[p.pos:], '\'')
+	if end < 0 {
+		return nil, p.errorf("Unterminated byte string")
+	}
+	encoded := p.edn[p.pos : p.pos+end]
+	if strings.Contains(encoded, "...") {
+		return nil, p.errorf("Truncated byte strings cannot be parsed")
+	}
+	content, err := decode(encoded)
+	if err != nil {
+		return nil, p.errorf("Malformed byte string")
+	}
+	p.pos += end + 1
+	return append(encodeHead(cbor_bstr, uint64(len(content))), content...), nil
+}
+
+func (p *ednParser) simple() ([]byte, error) {
+	end := strings.IndexByte(p.edn[p.pos:], ')')
+	if end < 0 {
+		return nil, p.errorf("Unterminated simple value")
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(p.edn[p.pos:p.pos+end]), 10, 8)
+	if err != nil || (value >= 24 && value < 32) {
+		return nil, p.errorf("Malformed simple value")
+	}
+	p.pos += end + 1
+	return encodeHead(cbor_simple, value), nil
+}
+
+// integerBase returns the base of an integer literal and its digits without
+// the 0x, 0o or 0b prefix. Unlike Go literals, a leading zero does not make
+// an integer octal and digits cannot be separated by underscores.
+func integerBase(magnitude string) (int, string) {
+	if len(magnitude) > 2 && magnitude[0] == '0' {
+		switch magnitude[1] {
+		case 'x', 'X':
+			return 16, magnitude[2:]
+		case 'o', 'O':
+			return 8, magnitude[2:]
+		case 'b', 'B':
+			return 2, magnitude[2:]
+		}
+	}
+	return 10, magnitude
+}
+
+// number parses integers, floats and tags, which start with a number.
+func (p *ednParser) number(depth int) ([]byte, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.edn) && strings.IndexByte("0123456789abcdefABCDEFxXoO.+-", p.edn[p.pos]) >= 0 {
+		if (p.edn[p.pos] == '+' || p.edn[p.pos] == '-') && !strings.ContainsAny(p.edn[p.pos-1:p.pos], "eE") {
+			break
+		}
+		p.pos++
+	}
+	literal := p.edn[start:p.pos]
+	if p.peek() == '(' {
+		tag, err := strconv.ParseUint(literal, 10, 64)
+		if err != nil {
+			return nil, p.errorf("Malformed tag number %q", literal)
+		}
+		p.pos++
+		content, err := p.item(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		err = p.skip()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("Expected \")\"")
+		}
+		return append(encodeHead(cbor_tag, tag), content...), nil
+	}
+	negative := strings.HasPrefix(literal, "-")
+	magnitude := strings.TrimPrefix(literal, "-")
+	base, digits := integerBase(magnitude)
+	if value, err := strconv.ParseUint(digits, base, 64); err == nil {
+		if !negative {
+			return encodeHead(cbor_uint, value), nil
+		}
+		if value == 0 {
+			return nil, p.errorf("Negative zero must be written as a float")
+		}
+		return encodeHead(cbor_nint, value-1), nil
+	}
+	if magnitude == "18446744073709551616" && negative {
+		return encodeHead(cbor_nint, math.MaxUint64), nil
+	}
+	if base != 10 {
+		return nil, p.errorf("Malformed number %q", literal)
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return nil, p.errorf("Malformed number %q", literal)
+	}
+	if h, exact := float64ToFloat16(f); exact {
+		return binary.BigEndian.AppendUint16([]byte{0xf9}, h), nil
+	}
+	if float64(float32(f)) == f {
+		return binary.BigEndian.AppendUint32([]byte{0xfa}, math.Float32bits(float32(f))), nil
+	}
+	return binary.BigEndian.AppendUint64([]byte{0xfb}, math.Float64bits(f)), nil
+}
+
+// float64ToFloat16 returns the half-precision encoding of a finite float,
+// and whether it represents the float exactly, for the preferred
+// serialization of floats.
+// see: https://www.rfc-editor.org/rfc/rfc8949#section-4.2.2
+func float64ToFloat16(f float64) (uint16, bool) {
+	var h uint16
+	if math.Signbit(f) {
+		h = 0x8000
+	}
+	frac, exp := math.Frexp(math.Abs(f))
+	switch e := exp + 14; {
+	case frac == 0:
+	case e >= 1 && e <= 30:
+		h |= uint16(e)<<10 | uint16(frac*2048-1024)
+	case e < 1 && exp >= -24:
+		h |= uint16(math.Ldexp(frac, exp+24))
+	default:
+		return 0, false
+	}
+	return h, float16ToFloat64(h) == f
+}
@@ -0,0 +1,180 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/veraison/go-cose"
+)
+
+// TestParseEDN calls cose.ParseEDN on diagnostic notation and confirms the
+// expected cbor encoding
+func TestParseEDN(t *testing.T) {
+	tests := []struct {
+		edn  string
+		cbor string
+	}{
+		{`0`, "00"},
+		{`23`, "17"},
+		{`24`, "1818"},
+		{`-1`, "20"},
+		{`-65537`, "3a00010000"},
+		{`0x10`, "10"},
+		{`0o17`, "0f"},
+		{`0b101`, "05"},
+		{`-0x10`, "2f"},
+		{`010`, "0a"},
+		{`18446744073709551615`, "1bffffffffffffffff"},
+		{`-18446744073709551616`, "3bffffffffffffffff"},
+		{`1.5`, "f93e00"},
+		{`-0.0`, "f98000"},
+		{`65504.0`, "f97bff"},
+		{`5.960464477539063e-8`, "f90001"},
+		{`100000.0`, "fa47c35000"},
+		{`1.0009765625`, "f93c01"},
+		{`1.00048828125`, "fa3f801000"},
+		{`1.1`, "fb3ff199999999999a"},
+		{`-Infinity`, "f9fc00"},
+		{`true`, "f5"},
+		{`null`, "f6"},
+		{`simple(16)`, "f0"},
+		{`"aü"`, "6361c3bc"},
+		{`'abc'`, "43616263"},
+		{`h'0102 0304'`, "4401020304"},
+		{`b64'AQID'`, "43010203"},
+		{`h''`, "40"},
+		{`<<>>`, "40"},
+		{`<<1, 2>>`, "420102"},
+		{`[]`, "80"},
+		{`[1, [2, 3]]`, "8201820203"},
+		{`{}`, "a0"},
+		{`{ / kty / 1: 7, / AKP / # comment
+		    / alg / 3: -48 / ML-DSA-44 / }`, "a2010703382f"},
+		{`18([<<{1: -48}>>, {}, null, h'00'])`, "d28444a101382fa0f64100"},
+	}
+	for _, test := range tests {
+		encoded, err := ParseEDN(test.edn)
+		if err != nil {
+			t.Fatalf("Parsing %q failed: %v", test.edn, err)
+		}
+		if hex.EncodeToString(encoded) != test.cbor {
+			t.Fatalf("Parsing %q gave %x, want %s", test.edn, encoded, test.cbor)
+		}
+	}
+}
+
+// TestParseEDNMalformed confirms malformed and truncated diagnostic
+// notation is rejected
+func TestParseEDNMalformed(t *testing.T) {
+	for _, edn := range []string{
+		``,
+		`[1, 2`,
+		`[1 2]`,
+		`{1 2}`,
+		`{1: }`,
+		`"abc`,
+		`h'0'`,
+		`h'00...00'`,
+		`/ comment`,
+		`-0`,
+		`18(1`,
+		`simple(24)`,
+		`1 2`,
+		`0xfg`,
+		`0x`,
+		`0b12`,
+		`1_000`,
+		`0x1_0`,
+		`kty`,
+		strings.Repeat("[", 100) + strings.Repeat("]", 100),
+	} {
+		_, err := ParseEDN(edn)
+		if err == nil {
+			t.Fatalf("Parsed malformed diagnostic notation %q", edn)
+		}
+	}
+}
+
+// TestParseEDNRoundTrip confirms the diagnostic notation of the example
+// keys and messages parses back to the same cbor
+func TestParseEDNRoundTrip(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		name, _ := AlgorithmToSuite(alg)
+		example, err := os.ReadFile("examples/" + strings.ReplaceAll(name, "-", "_") + ".cose.json")
+		if err != nil {
+			t.Fatalf("Failed to read %s example", name)
+		}
+		var vector COSETestVector
+		json.Unmarshal(example, &vector)
+		key, _ := hex.DecodeString(vector.Key)
+		signature, _ := hex.DecodeString(vector.Sign1)
+
+		edn, _ := DiagnoseKey(key)
+		encoded, err := ParseEDN(edn)
+		if err != nil || !bytes.Equal(encoded, key) {
+			t.Fatalf("%s key does not round trip: %v", name, err)
+		}
+		decoded, err := DecodeKey(encoded)
+		if err != nil || decoded.Alg != alg {
+			t.Fatalf("Decoding %s key from diagnostic notation failed: %v", name, err)
+		}
+		edn, _ = DiagnoseSign1(signature)
+		encoded, err = ParseEDN(edn)
+		if err != nil || !bytes.Equal(encoded, signature) {
+			t.Fatalf("%s COSE_Sign1 does not round trip: %v", name, err)
+		}
+		public_key, _ := PublicKeyFromPrivateKey(key)
+		_, err = VerifySign1(public_key, encoded)
+		if err != nil {
+			t.Fatalf("Verifying %s COSE_Sign1 from diagnostic notation failed: %v", name, err)
+		}
+	}
+}
+
+// TestVerifySign1EDN calls cose.VerifySign1 on edge cases written in
+// diagnostic notation, and confirms they are rejected
+func TestVerifySign1EDN(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	raw_signature, _ := SignatureFromSign1(signature)
+	sig := "h'" + hex.EncodeToString(raw_signature) + "'"
+	kid := "h'" + hex.EncodeToString(key.Kid) + "'"
+	pl := "h'" + hex.EncodeToString(payload) + "'"
+
+	valid, _ := ParseEDN(`/ COSE_Sign1 / 18([
+		/ protected / <<{ / alg / 1: -48, / kid / 4: ` + kid + ` }>>,
+		/ unprotected / {},
+		/ payload / ` + pl + `,
+		/ signature / ` + sig + `
+	])`)
+	_, err := VerifySign1(public_key, valid)
+	if err != nil {
+		t.Fatalf("Verifying COSE_Sign1 from diagnostic notation failed: %v", err)
+	}
+	for name, edn := range map[string]string{
+		"unprotected alg":     `18([h'', { 1: -48 }, ` + pl + `, ` + sig + `])`,
+		"wrong alg":           `18([<<{ 1: -49, 4: ` + kid + ` }>>, {}, ` + pl + `, ` + sig + `])`,
+		"text alg":            `18([<<{ 1: "ML-DSA-44", 4: ` + kid + ` }>>, {}, ` + pl + `, ` + sig + `])`,
+		"wrong tag":           `19([<<{ 1: -48, 4: ` + kid + ` }>>, {}, ` + pl + `, ` + sig + `])`,
+		"tag not innermost":   `18(61([<<{ 1: -48, 4: ` + kid + ` }>>, {}, ` + pl + `, ` + sig + `]))`,
+		"three items":         `18([<<{ 1: -48, 4: ` + kid + ` }>>, {}, ` + pl + `])`,
+		"text payload":        `18([<<{ 1: -48, 4: ` + kid + ` }>>, {}, "payload", ` + sig + `])`,
+		"truncated signature": `18([<<{ 1: -48, 4: ` + kid + ` }>>, {}, ` + pl + `, h'` + hex.EncodeToString(raw_signature[:100]) + `'])`,
+		"unknown critical":    `18([<<{ 1: -48, 2: [-65599], -65599: 1 }>>, {}, ` + pl + `, ` + sig + `])`,
+	} {
+		message, err := ParseEDN(edn)
+		if err != nil {
+			t.Fatalf("%s: parsing failed: %v", name, err)
+		}
+		_, err = VerifySign1(public_key, message)
+		if err == nil {
+			t.Fatalf("%s: verified an invalid COSE_Sign1", name)
+		}
+	}
+}
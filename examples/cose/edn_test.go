@@ -0,0 +1,157 @@
+package cose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/veraison/go-cose"
+)
+
+// see: https://datatracker.ietf.org/doc/draft-ietf-cose-dilithium/
+// The all-zeros ML-DSA-44 COSE Key, truncated to 8 bytes
+const ml_dsa_44_key_edn = `{
+   / kid /   2: h'b8969ab4b37da9f0...583bf5b8d3a8059a',
+   / kty /   1: 7, / AKP /
+   / alg /   3: -48, / ML-DSA-44 /
+   / pub /  -1: h'ba71f9f64e11baeb...3830546b9dd8db0d',
+   / priv / -2: h'0000000000000000...0000000000000000'
+}`
+
+// TestDiagnoseKey calls cose.DiagnoseKey and cose.DiagnoseKeySet and
+// confirms the output matches the figure of the draft
+func TestDiagnoseKey(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	edn, err := DiagnoseKey(private_key, WithTruncation(8))
+	if err != nil {
+		t.Fatalf("Diagnosing key failed: %v", err)
+	}
+	if edn != ml_dsa_44_key_edn {
+		t.Fatalf("Invalid key diagnostic notation:\n%s", edn)
+	}
+	edn, _ = DiagnoseKey(private_key)
+	if strings.Contains(edn, "...") {
+		t.Fatalf("Truncated without WithTruncation")
+	}
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key_set, _ := EncodeKeySet(public_key, public_key)
+	edn, err = DiagnoseKeySet(key_set, WithTruncation(8))
+	if err != nil {
+		t.Fatalf("Diagnosing key set failed: %v", err)
+	}
+	if strings.Count(edn, "/ kty /  1: 7, / AKP /") != 2 || !strings.HasPrefix(edn, "[\n   {\n      / kid /") {
+		t.Fatalf("Invalid key set diagnostic notation:\n%s", edn)
+	}
+	_, err = DiagnoseKeySet(private_key)
+	if err == nil {
+		t.Fatalf("Diagnosed a key as a key set")
+	}
+	for key, labels := range map[string][]string{
+		`{1: 2, -1: 1, -2: h'01', -3: h'02', -4: h'03'}`: {"/ kty /  1: 2, / EC2 /", "/ crv / -1: 1, / P-256 /", "/ x /   -2: h'01'", "/ y /   -3: h'02'", "/ d /   -4: h'03'"},
+		`{1: 1, -1: 6, -2: h'01', -4: h'03'}`:            {"/ kty /  1: 1, / OKP /", "/ crv / -1: 6, / Ed25519 /", "/ x /   -2: h'01'", "/ d /   -4: h'03'"},
+		`{1: 4, -1: h'01'}`:                              {"/ kty /  1: 4, / Symmetric /", "/ k /   -1: h'01'"},
+	} {
+		encoded, _ := ParseEDN(key)
+		edn, _ = DiagnoseKey(encoded)
+		for _, label := range labels {
+			if !strings.Contains(edn, label) {
+				t.Fatalf("Key diagnostic notation has no %q:\n%s", label, edn)
+			}
+		}
+		if strings.Contains(edn, "pub") || strings.Contains(edn, "priv") {
+			t.Fatalf("Key parameters annotated as AKP:\n%s", edn)
+		}
+	}
+}
+
+// TestDiagnoseSign1 calls cose.DiagnoseSign1 and confirms headers are
+// annotated and the protected header is shown as an embedded map
+func TestDiagnoseSign1(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_65, seed[:])
+	key, _ := DecodeKey(private_key)
+	signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	edn, err := DiagnoseSign1(signature, WithTruncation(4))
+	if err != nil {
+		t.Fatalf("Diagnosing COSE_Sign1 failed: %v", err)
+	}
+	for _, line := range []string{
+		"/ COSE_Sign1 / 18([",
+		"   / protected / <<{",
+		"      / alg / 1: -49, / ML-DSA-65 /",
+		"      / kid / 4: h'",
+		"   / unprotected / {},",
+		"   / payload / h'4974e280...6f6f722e',",
+		"])",
+	} {
+		if !strings.Contains(edn, line) {
+			t.Fatalf("Missing %q in COSE_Sign1 diagnostic notation:\n%s", line, edn)
+		}
+	}
+
+	cwt, _ := IssueCWT(private_key, Header{Alg: key.Alg}, cwt_claims, WithCWTTag())
+	edn, _ = DiagnoseSign1(cwt)
+	if !strings.HasPrefix(edn, "61(/ COSE_Sign1 / 18([") || !strings.Contains(edn, `/ iss /      1: "coap://as.example.com",`) {
+		t.Fatalf("Invalid CWT diagnostic notation:\n%s", edn)
+	}
+	untagged, _ := Sign1(private_key, Header{Alg: key.Alg}, payload, Untagged())
+	edn, _ = DiagnoseSign1(untagged)
+	if !strings.HasPrefix(edn, "[\n   / protected / <<{") {
+		t.Fatalf("Invalid untagged COSE_Sign1 diagnostic notation:\n%s", edn)
+	}
+}
+
+// TestDiagnoseSign calls cose.DiagnoseSign on a COSE_Sign with two
+// signatures and confirms each signature is annotated
+func TestDiagnoseSign(t *testing.T) {
+	message := cose.NewSignMessage()
+	message.Payload = payload
+	var signers []cose.Signer
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		signer, _ := signerFromPrivateKey(private_key)
+		signature := cose.NewSignature()
+		signature.Headers.Protected.SetAlgorithm(alg)
+		message.Signatures = append(message.Signatures, signature)
+		signers = append(signers, signer)
+	}
+	err := message.Sign(nil, nil, signers...)
+	if err != nil {
+		t.Fatalf("Signing COSE_Sign failed: %v", err)
+	}
+	encoded, _ := message.MarshalCBOR()
+	edn, err := DiagnoseSign(encoded, WithTruncation(4))
+	if err != nil {
+		t.Fatalf("Diagnosing COSE_Sign failed: %v", err)
+	}
+	for _, line := range []string{
+		"/ COSE_Sign / 98([",
+		"   / protected / h'',",
+		"   / signatures / [",
+		"            / alg / 1: -48 / ML-DSA-44 /",
+		"            / alg / 1: -50 / ML-DSA-87 /",
+	} {
+		if !strings.Contains(edn, line) {
+			t.Fatalf("Missing %q in COSE_Sign diagnostic notation:\n%s", line, edn)
+		}
+	}
+	_, err = DiagnoseSign1(encoded)
+	if err == nil {
+		t.Fatalf("Diagnosed a COSE_Sign as a COSE_Sign1")
+	}
+}
+
+// TestDiagnoseMalformed confirms malformed cbor is rejected
+func TestDiagnoseMalformed(t *testing.T) {
+	for _, malformed := range [][]byte{
+		{},
+		{0xa1, 0x01},
+		{0x5a, 0xff, 0xff, 0xff, 0xff},
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0xbf, 0x01, 0x02, 0xff},
+		{0xa0, 0xa0},
+	} {
+		_, err := DiagnoseKey(malformed)
+		if err == nil {
+			t.Fatalf("Diagnosed malformed cbor %x", malformed)
+		}
+	}
+}
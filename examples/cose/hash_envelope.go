@@ -0,0 +1,193 @@
+package cose
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// see: https://datatracker.ietf.org/doc/draft-ietf-cose-hash-envelope/
+const (
+	HEADER_LABEL_PAYLOAD_HASH_ALG      int64 = 258
+	HEADER_LABEL_PREIMAGE_CONTENT_TYPE int64 = 259
+	HEADER_LABEL_PAYLOAD_LOCATION      int64 = 260
+)
+
+// see: https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	SHA_256 cose.Algorithm = -16
+	SHA_384 cose.Algorithm = -43
+	SHA_512 cose.Algorithm = -44
+)
+
+type HashEnvelopeHeader struct {
+	Header
+	PayloadHashAlg      cose.Algorithm `cbor:"258,keyasint,omitempty"`
+	PreimageContentType *ContentType   `cbor:"259,keyasint,omitempty"`
+	PayloadLocation     string         `cbor:"260,keyasint,omitempty"`
+}
+
+// ContentType is a CoAP Content-Format when MediaType is empty, and a media
+// type otherwise.
+// see: https://datatracker.ietf.org/doc/html/rfc9052#section-3.1
+type ContentType struct {
+	Format    uint64
+	MediaType string
+}
+
+func (c ContentType) value() any {
+	if c.MediaType != "" {
+		return c.MediaType
+	}
+	return c.Format
+}
+
+func (c ContentType) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(c.value())
+}
+
+func (c *ContentType) UnmarshalCBOR(data []byte) error {
+	var content_type any
+	err := cbor.Unmarshal(data, &content_type)
+	if err != nil {
+		return err
+	}
+	switch content_type := content_type.(type) {
+	case uint64:
+		*c = ContentType{Format: content_type}
+	case string:
+		if content_type == "" {
+			return errors.New("Content type must not be an empty text string")
+		}
+		*c = ContentType{MediaType: content_type}
+	default:
+		return errors.New("Content type must be a uint or a text string")
+	}
+	return nil
+}
+
+type HashEnvelopeVerification struct {
+	Header    HashEnvelopeHeader
+	Payload   []byte
+	Tags      []uint64
+	PublicKey []byte
+}
+
+func hashFromAlgorithm(alg cose.Algorithm) (crypto.Hash, error) {
+	switch alg {
+	case SHA_256:
+		return crypto.SHA256, nil
+	case SHA_384:
+		return crypto.SHA384, nil
+	case SHA_512:
+		return crypto.SHA512, nil
+	default:
+		return 0, errors.New("Unsupported payload hash algorithm")
+	}
+}
+
+func hashArtifact(alg cose.Algorithm, artifact io.Reader) ([]byte, error) {
+	hash, err := hashFromAlgorithm(alg)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	_, err = io.Copy(h, artifact)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// SignHashEnvelope hashes the artifact with the payload hash algorithm and
+// signs the digest as a COSE Hash Envelope.
+func SignHashEnvelope(private_key []byte, header HashEnvelopeHeader, artifact io.Reader, opts ...SignOption) ([]byte, error) {
+	digest, err := hashArtifact(header.PayloadHashAlg, artifact)
+	if err != nil {
+		return nil, err
+	}
+	return SignHashEnvelopeDigest(private_key, header, digest, opts...)
+}
+
+// SignHashEnvelopeDigest signs a precomputed digest of the artifact as a
+// COSE Hash Envelope.
+func SignHashEnvelopeDigest(private_key []byte, header HashEnvelopeHeader, digest []byte, opts ...SignOption) ([]byte, error) {
+	hash, err := hashFromAlgorithm(header.PayloadHashAlg)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != hash.Size() {
+		return nil, errors.New("Digest length does not match the payload hash algorithm")
+	}
+	o := newSignOptions(opts)
+	headers, err := headersForSigning(header.Header, o)
+	if err != nil {
+		return nil, err
+	}
+	headers.Protected[HEADER_LABEL_PAYLOAD_HASH_ALG] = header.PayloadHashAlg
+	if header.PreimageContentType != nil {
+		headers.Protected[HEADER_LABEL_PREIMAGE_CONTENT_TYPE] = header.PreimageContentType.value()
+	}
+	if header.PayloadLocation != "" {
+		headers.Protected[HEADER_LABEL_PAYLOAD_LOCATION] = header.PayloadLocation
+	}
+	return sign1WithHeaders(private_key, headers, digest, o)
+}
+
+// VerifyHashEnvelope verifies a COSE Hash Envelope and confirms its payload
+// is the digest of the artifact.
+func VerifyHashEnvelope(public_key []byte, envelope []byte, artifact io.Reader, opts ...VerifyOption) (HashEnvelopeVerification, error) {
+	var verified = HashEnvelopeVerification{}
+	sign1, _, err := decodeSign1(envelope)
+	if err != nil {
+		return verified, err
+	}
+	if _, exists := sign1.Headers.Protected[cose.HeaderLabelContentType]; exists {
+		return verified, errors.New("Hash envelope must not have a content type header")
+	}
+	if _, exists := sign1.Headers.Unprotected[cose.HeaderLabelContentType]; exists {
+		return verified, errors.New("Hash envelope must not have a content type header")
+	}
+	for _, label := range []int64{HEADER_LABEL_PAYLOAD_HASH_ALG, HEADER_LABEL_PREIMAGE_CONTENT_TYPE, HEADER_LABEL_PAYLOAD_LOCATION} {
+		if _, exists := sign1.Headers.Unprotected[label]; exists {
+			return verified, errors.New("Hash envelope header parameters must be protected")
+		}
+	}
+	var h []byte
+	var header HashEnvelopeHeader
+	cbor.Unmarshal(sign1.Headers.RawProtected, &h)
+	err = cbor.Unmarshal(h, &header)
+	if err != nil {
+		return verified, errors.New("Malformed hash envelope header")
+	}
+	hash, err := hashFromAlgorithm(header.PayloadHashAlg)
+	if err != nil {
+		return verified, err
+	}
+	if len(sign1.Payload) != hash.Size() {
+		return verified, errors.New("Payload length does not match the payload hash algorithm")
+	}
+	signed, err := VerifySign1(public_key, envelope, opts...)
+	if err != nil {
+		return verified, err
+	}
+	digest, err := hashArtifact(header.PayloadHashAlg, artifact)
+	if err != nil {
+		return verified, err
+	}
+	if subtle.ConstantTimeCompare(digest, signed.Payload) != 1 {
+		return verified, errors.New("Artifact does not match the hash envelope")
+	}
+	header.Header = signed.Header
+	verified.Header = header
+	verified.Payload = signed.Payload
+	verified.Tags = signed.Tags
+	verified.PublicKey = public_key
+	return verified, nil
+}
@@ -0,0 +1,174 @@
+package cose
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+type COSEHashEnvelopeTestVector struct {
+	Priv         string `json:"priv"`
+	Key          string `json:"key"`
+	Artifact     string `json:"artifact"`
+	Envelope     string `json:"hash_envelope"`
+	EnvelopeDiag string `json:"hash_envelope_diag"`
+}
+
+// TestHashEnvelope calls cose.SignHashEnvelope for each ML-DSA level and
+// confirms cose.VerifyHashEnvelope accepts the original artifact only
+func TestHashEnvelope(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		name, _ := AlgorithmToSuite(alg)
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		header := HashEnvelopeHeader{
+			Header:              Header{Alg: key.Alg, Kid: key.Kid},
+			PayloadHashAlg:      SHA_256,
+			PreimageContentType: &ContentType{MediaType: "text/plain"},
+			PayloadLocation:     "https://example.com/lord-of-the-rings.txt",
+		}
+		envelope, err := SignHashEnvelope(private_key, header, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Signing %s hash envelope failed: %v", name, err)
+		}
+		verified, err := VerifyHashEnvelope(public_key, envelope, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Verifying %s hash envelope failed: %v", name, err)
+		}
+		digest := sha256.Sum256(payload)
+		if !bytes.Equal(verified.Payload, digest[:]) {
+			t.Fatalf("Hash envelope payload is not the artifact digest")
+		}
+		if verified.Header.PayloadHashAlg != SHA_256 || *verified.Header.PreimageContentType != *header.PreimageContentType || verified.Header.PayloadLocation != header.PayloadLocation || verified.Header.Alg != alg {
+			t.Fatalf("Invalid hash envelope header")
+		}
+		_, err = VerifyHashEnvelope(public_key, envelope, bytes.NewReader(large_payload))
+		if err == nil {
+			t.Fatalf("Verified a hash envelope with the wrong artifact")
+		}
+		from_digest, _ := SignHashEnvelopeDigest(private_key, header, digest[:])
+		if !bytes.Equal(from_digest, envelope) {
+			t.Fatalf("Signing the digest and the artifact differ")
+		}
+
+		ed, _ := cbor.Diagnose(envelope)
+		examples, _ := json.MarshalIndent(COSEHashEnvelopeTestVector{
+			Priv:         hex.EncodeToString(seed[:]),
+			Key:          hex.EncodeToString(private_key),
+			Artifact:     hex.EncodeToString(payload),
+			Envelope:     hex.EncodeToString(envelope),
+			EnvelopeDiag: ed,
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(name, "-", "_")+".hash_envelope.cose.json", examples, 0644)
+	}
+}
+
+// TestHashEnvelopeRejected confirms unsupported hash algorithms, digests of
+// the wrong length and content type headers are rejected
+func TestHashEnvelopeRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	header := HashEnvelopeHeader{
+		Header:         Header{Alg: key.Alg, Kid: key.Kid},
+		PayloadHashAlg: SHA_384,
+	}
+	_, err := SignHashEnvelopeDigest(private_key, header, make([]byte, 32))
+	if err == nil {
+		t.Fatalf("Signed a digest of the wrong length")
+	}
+	header.PayloadHashAlg = -1
+	_, err = SignHashEnvelope(private_key, header, bytes.NewReader(payload))
+	if err == nil {
+		t.Fatalf("Signed with an unsupported payload hash algorithm")
+	}
+	signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	_, err = VerifyHashEnvelope(public_key, signature, bytes.NewReader(payload))
+	if err == nil {
+		t.Fatalf("Verified a COSE_Sign1 without a payload hash algorithm")
+	}
+
+	digest := sha256.Sum256(payload)
+	signer, _ := signerFromPrivateKey(private_key)
+	sign1 := cose.Sign1Message{
+		Headers: cose.Headers{
+			Protected: cose.ProtectedHeader{
+				cose.HeaderLabelAlgorithm:     key.Alg,
+				cose.HeaderLabelContentType:   "text/plain",
+				HEADER_LABEL_PAYLOAD_HASH_ALG: SHA_256,
+			},
+		},
+		Payload: digest[:],
+	}
+	sign1.Sign(nil, nil, signer)
+	envelope, _ := sign1.MarshalCBOR()
+	_, err = VerifyHashEnvelope(public_key, envelope, bytes.NewReader(payload))
+	if err == nil {
+		t.Fatalf("Verified a hash envelope with a content type header")
+	}
+}
+
+// TestHashEnvelopeContentType confirms the preimage content type round
+// trips as a CoAP Content-Format uint and as a media type text string, and
+// other types are rejected
+func TestHashEnvelopeContentType(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	for _, test := range []struct {
+		content_type ContentType
+		encoded      any
+	}{
+		{ContentType{Format: 0}, int64(0)},
+		{ContentType{Format: 50}, int64(50)},
+		{ContentType{MediaType: "application/json"}, "application/json"},
+	} {
+		header := HashEnvelopeHeader{
+			Header:              Header{Alg: key.Alg, Kid: key.Kid},
+			PayloadHashAlg:      SHA_256,
+			PreimageContentType: &test.content_type,
+		}
+		envelope, _ := SignHashEnvelope(private_key, header, bytes.NewReader(payload))
+		var sign1 cose.Sign1Message
+		_ = sign1.UnmarshalCBOR(envelope)
+		if encoded := sign1.Headers.Protected[HEADER_LABEL_PREIMAGE_CONTENT_TYPE]; encoded != test.encoded {
+			t.Fatalf("Preimage content type %+v encoded as %v", test.content_type, encoded)
+		}
+		verified, err := VerifyHashEnvelope(public_key, envelope, bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Verifying hash envelope with preimage content type %+v failed: %v", test.content_type, err)
+		}
+		if verified.Header.PreimageContentType == nil || *verified.Header.PreimageContentType != test.content_type {
+			t.Fatalf("Invalid preimage content type %+v", verified.Header.PreimageContentType)
+		}
+	}
+
+	digest := sha256.Sum256(payload)
+	signer, _ := signerFromPrivateKey(private_key)
+	for _, invalid := range []any{int64(-1), []byte("text/plain"), ""} {
+		sign1 := cose.Sign1Message{
+			Headers: cose.Headers{
+				Protected: cose.ProtectedHeader{
+					cose.HeaderLabelAlgorithm:          key.Alg,
+					HEADER_LABEL_PAYLOAD_HASH_ALG:      SHA_256,
+					HEADER_LABEL_PREIMAGE_CONTENT_TYPE: invalid,
+				},
+			},
+			Payload: digest[:],
+		}
+		sign1.Sign(nil, nil, signer)
+		envelope, _ := sign1.MarshalCBOR()
+		_, err := VerifyHashEnvelope(public_key, envelope, bytes.NewReader(payload))
+		if err == nil {
+			t.Fatalf("Verified a hash envelope with preimage content type %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package cose
+
+import "github.com/veraison/go-cose"
+
+// EXPERIMENTAL: the draft does not register HashML-DSA. These private use
+// algorithms only exist to verify signatures from devices that only support
+// HashML-DSA with SHA-512. Their AKP keys are distinct from ML-DSA keys, and
+// signatures never verify across the two.
+const (
+	HASH_ML_DSA_44_SHA512 = -65548
+	HASH_ML_DSA_65_SHA512 = -65549
+	HASH_ML_DSA_87_SHA512 = -65550
+)
+
+func IsHashMLDSA(alg cose.Algorithm) bool {
+	switch alg {
+	case HASH_ML_DSA_44_SHA512, HASH_ML_DSA_65_SHA512, HASH_ML_DSA_87_SHA512:
+		return true
+	default:
+		return false
+	}
+}
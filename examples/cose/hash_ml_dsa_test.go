@@ -0,0 +1,121 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+var hash_ml_dsa_algorithms = []struct {
+	alg  cose.Algorithm
+	pure cose.Algorithm
+	name string
+}{
+	{HASH_ML_DSA_44_SHA512, ML_DSA_44, "HashML_DSA_44_SHA512"},
+	{HASH_ML_DSA_65_SHA512, ML_DSA_65, "HashML_DSA_65_SHA512"},
+	{HASH_ML_DSA_87_SHA512, ML_DSA_87, "HashML_DSA_87_SHA512"},
+}
+
+// TestSign1HashMLDSA calls cose.Sign1 with experimental HashML-DSA keys
+// and confirms the result verifies with cose.VerifySign1
+func TestSign1HashMLDSA(t *testing.T) {
+	for _, test := range hash_ml_dsa_algorithms {
+		var private_key, _ = GenerateKey(test.alg, seed[:])
+		var public_key, _ = PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		if key.Alg != test.alg {
+			t.Fatalf("COSE Key did not contain expected alg (%d)", test.alg)
+		}
+		var header = Header{
+			Alg: key.Alg,
+			Kid: key.Kid,
+		}
+		signature, err := Sign1(private_key, header, payload)
+		if err != nil {
+			t.Fatalf("Signing %s failed: %v", test.name, err)
+		}
+		verified, verify_error := VerifySign1(public_key, signature)
+		if verify_error != nil {
+			t.Fatalf("Verification %s failed: %v", test.name, verify_error)
+		}
+		if verified.Header.Alg != test.alg {
+			t.Fatalf("Invalid header alg")
+		}
+		streamed, _ := Sign1Stream(private_key, header, bytes.NewReader(payload), int64(len(payload)))
+		sig, _ := SignatureFromSign1(signature)
+		streamed_sig, _ := SignatureFromSign1(streamed)
+		if !bytes.Equal(sig, streamed_sig) {
+			t.Fatalf("Streamed %s signature differs from in memory signature", test.name)
+		}
+		_, verify_error = VerifySign1Stream(public_key, streamed, bytes.NewReader(payload), int64(len(payload)))
+		if verify_error != nil {
+			t.Fatalf("Streaming verification %s failed: %v", test.name, verify_error)
+		}
+
+		tbs, _ := ToBeSignedFromSign1(signature)
+		kd, _ := cbor.Diagnose(private_key)
+		sd, _ := cbor.Diagnose(signature)
+		examples, _ := json.MarshalIndent(COSETestVector{
+			Priv:      hex.EncodeToString(seed[:]),
+			Key:       hex.EncodeToString(private_key),
+			KeyDiag:   kd,
+			Sign1:     hex.EncodeToString(signature),
+			Sign1Diag: sd,
+			RawTbs:    hex.EncodeToString(tbs),
+			RawSig:    hex.EncodeToString(sig),
+			RawPub:    hex.EncodeToString(key.Pub),
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+test.name+".cose.json", examples, 0644)
+	}
+}
+
+// TestHashMLDSACrossVerification confirms HashML-DSA and ML-DSA signatures
+// made with the same seed never verify as each other
+func TestHashMLDSACrossVerification(t *testing.T) {
+	for _, test := range hash_ml_dsa_algorithms {
+		hash_private_key, _ := GenerateKey(test.alg, seed[:])
+		hash_public_key, _ := PublicKeyFromPrivateKey(hash_private_key)
+		hash_key, _ := DecodeKey(hash_private_key)
+		pure_private_key, _ := GenerateKey(test.pure, seed[:])
+		pure_public_key, _ := PublicKeyFromPrivateKey(pure_private_key)
+		pure_key, _ := DecodeKey(pure_private_key)
+		if bytes.Equal(hash_key.Kid, pure_key.Kid) {
+			t.Fatalf("HashML-DSA and ML-DSA keys have the same thumbprint")
+		}
+
+		hash_signature, _ := Sign1(hash_private_key, Header{Alg: hash_key.Alg, Kid: hash_key.Kid}, payload)
+		pure_signature, _ := Sign1(pure_private_key, Header{Alg: pure_key.Alg, Kid: pure_key.Kid}, payload)
+		_, err := VerifySign1(pure_public_key, hash_signature)
+		if err == nil {
+			t.Fatalf("%s signature verified with ML-DSA key", test.name)
+		}
+		_, err = VerifySign1(hash_public_key, pure_signature)
+		if err == nil {
+			t.Fatalf("ML-DSA signature verified with %s key", test.name)
+		}
+
+		// the same key material and to be signed bytes, with only the
+		// algorithm changed
+		hash_signer, _ := signerFromPrivateKey(hash_private_key)
+		pure_signer, _ := signerFromPrivateKey(pure_private_key)
+		hash_verifier, _ := verifierFromPublicKey(hash_public_key)
+		pure_verifier, _ := verifierFromPublicKey(pure_public_key)
+		tbs, _ := ToBeSignedFromSign1(pure_signature)
+		hash_sig, _ := hash_signer.Sign(nil, tbs)
+		pure_sig, _ := pure_signer.Sign(nil, tbs)
+		if pure_verifier.Verify(tbs, hash_sig) == nil {
+			t.Fatalf("%s signature verified as ML-DSA", test.name)
+		}
+		if hash_verifier.Verify(tbs, pure_sig) == nil {
+			t.Fatalf("ML-DSA signature verified as %s", test.name)
+		}
+		if hash_verifier.Verify(tbs, hash_sig) != nil || pure_verifier.Verify(tbs, pure_sig) != nil {
+			t.Fatalf("Signatures do not verify with their own algorithm")
+		}
+	}
+}
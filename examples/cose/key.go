@@ -2,7 +2,9 @@ package cose
 
 import (
 	"crypto/sha256"
+	"encoding/base64"
 	"errors"
+	"strings"
 
 	"github.com/cloudflare/circl/sign/schemes"
 	"github.com/fxamacker/cbor/v2"
@@ -118,3 +120,50 @@ func CalculateCoseKeyThumbprint(cose_key []byte) ([]byte, error) {
 	thumbprint = h.Sum(nil)
 	return thumbprint, nil
 }
+
+// see: https://datatracker.ietf.org/doc/html/rfc9679#section-6
+const COSE_KEY_THUMBPRINT_URI_PREFIX = "urn:ietf:params:oauth:ckt:sha-256:"
+
+func CalculateCoseKeyThumbprintURI(cose_key []byte) (string, error) {
+	thumbprint, err := CalculateCoseKeyThumbprint(cose_key)
+	if err != nil {
+		return "", err
+	}
+	return COSE_KEY_THUMBPRINT_URI_PREFIX + base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+func ParseCoseKeyThumbprintURI(uri string) ([]byte, error) {
+	if !strings.HasPrefix(uri, COSE_KEY_THUMBPRINT_URI_PREFIX) {
+		return nil, errors.New(`Unsupported COSE Key thumbprint URI`)
+	}
+	thumbprint, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(uri, COSE_KEY_THUMBPRINT_URI_PREFIX))
+	if err != nil || len(thumbprint) != sha256.Size {
+		return nil, errors.New(`Malformed COSE Key thumbprint URI`)
+	}
+	return thumbprint, nil
+}
+
+func EncodeKeySet(cose_keys ...[]byte) ([]byte, error) {
+	key_set := make([]cbor.RawMessage, len(cose_keys))
+	for i, cose_key := range cose_keys {
+		key_set[i] = cose_key
+	}
+	encoded_key_set, err := cbor.Marshal(key_set)
+	if err != nil {
+		return nil, errors.New(`Failed to cbor encode cose key set`)
+	}
+	return encoded_key_set, nil
+}
+
+func DecodeKeySet(cose_key_set []byte) ([][]byte, error) {
+	var key_set []cbor.RawMessage
+	err := cbor.Unmarshal(cose_key_set, &key_set)
+	if err != nil {
+		return nil, errors.New(`Failed to decode cose key set`)
+	}
+	cose_keys := make([][]byte, len(key_set))
+	for i, cose_key := range key_set {
+		cose_keys[i] = cose_key
+	}
+	return cose_keys, nil
+}
@@ -48,3 +48,49 @@ func TestCalculateCoseKeyThumbprint(t *testing.T) {
 	}
 
 }
+
+// TestCalculateCoseKeyThumbprintURI calls cose.CalculateCoseKeyThumbprintURI
+// with a cose key and confirms the URI round trips through
+// cose.ParseCoseKeyThumbprintURI
+func TestCalculateCoseKeyThumbprintURI(t *testing.T) {
+	var encoded_key = "A50102200121582065EDA5A12577C2BAE829437FE338701A10AAA375E1BB5B5DE108DE439C08551D2258201E52ED75701163F7F9E40DDF9F341B3DC9BA860AF7E0CA7CA7E9EECD0084D19C0258246D65726961646F632E6272616E64796275636B406275636B6C616E642E6578616D706C65"
+	var cose_key, _ = hex.DecodeString(encoded_key)
+	var uri, _ = CalculateCoseKeyThumbprintURI(cose_key)
+	if uri != "urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB-WwjGSwQhv53AFSijRKQ72oj63RZp2iU-w" {
+		t.Fatalf(`COSE Key thumbprint URI calculated incorrectly (%s), want urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB-WwjGSwQhv53AFSijRKQ72oj63RZp2iU-w`, uri)
+	}
+	var thumbprint, _ = ParseCoseKeyThumbprintURI(uri)
+	if hex.EncodeToString(thumbprint) != "496bd8afadf307e5b08c64b0421bf9dc01528a344a43bda88fadd1669da253ec" {
+		t.Fatalf(`COSE Key thumbprint URI parsed incorrectly (%x)`, thumbprint)
+	}
+	for _, malformed := range []string{
+		"urn:ietf:params:oauth:jwk-thumbprint:sha-256:SWvYr63zB-WwjGSwQhv53AFSijRKQ72oj63RZp2iU-w",
+		"urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB-WwjGSwQhv53AFSijRKQ72oj63RZp2iU",
+		"urn:ietf:params:oauth:ckt:sha-256:SWvYr63zB+WwjGSwQhv53AFSijRKQ72oj63RZp2iU-w",
+	} {
+		_, err := ParseCoseKeyThumbprintURI(malformed)
+		if err == nil {
+			t.Fatalf(`Parsed malformed COSE Key thumbprint URI (%s)`, malformed)
+		}
+	}
+}
+
+// TestEncodeKeySet calls cose.EncodeKeySet with cose keys and confirms
+// cose.DecodeKeySet returns the same keys
+func TestEncodeKeySet(t *testing.T) {
+	var seed [32]byte // zero seed
+	var k1, _ = GenerateKey(ML_DSA_44, seed[:])
+	var k2, _ = GenerateKey(ML_DSA_65, seed[:])
+	var key_set, err = EncodeKeySet(k1, k2)
+	if err != nil {
+		t.Fatalf(`Failed to encode COSE Key Set`)
+	}
+	var keys, _ = DecodeKeySet(key_set)
+	if len(keys) != 2 || hex.EncodeToString(keys[0]) != hex.EncodeToString(k1) || hex.EncodeToString(keys[1]) != hex.EncodeToString(k2) {
+		t.Fatalf(`COSE Key Set did not round trip`)
+	}
+	_, err = DecodeKeySet(k1)
+	if err == nil {
+		t.Fatalf(`Decoded a COSE Key as a COSE Key Set`)
+	}
+}
@@ -0,0 +1,130 @@
+package cose
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+	"sync"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc9162#section-2.1.1
+func hashLeaf(entry []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(entry)
+	return h.Sum(nil)
+}
+
+func hashChildren(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// splitPoint is the largest power of two smaller than n.
+func splitPoint(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+func merkleTreeHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(uint64(len(leaves)))
+	return hashChildren(merkleTreeHash(leaves[:k]), merkleTreeHash(leaves[k:]))
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc9162#section-2.1.3.1
+func inclusionPath(index uint64, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+	k := splitPoint(uint64(len(leaves)))
+	if index < k {
+		return append(inclusionPath(index, leaves[:k]), merkleTreeHash(leaves[k:]))
+	}
+	return append(inclusionPath(index-k, leaves[k:]), merkleTreeHash(leaves[:k]))
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc9162#section-2.1.3.2
+func rootFromInclusionPath(index uint64, size uint64, leaf_hash []byte, path [][]byte) ([]byte, error) {
+	if index >= size {
+		return nil, errors.New("Leaf index is outside of the tree")
+	}
+	fn, sn := index, size-1
+	r := leaf_hash
+	for _, p := range path {
+		if len(p) != sha256.Size {
+			return nil, errors.New("Malformed inclusion path")
+		}
+		if sn == 0 {
+			return nil, errors.New("Inclusion path is too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = hashChildren(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = hashChildren(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return nil, errors.New("Inclusion path is too short")
+	}
+	return r, nil
+}
+
+// MerkleLog is an in-process append-only log using RFC 9162 tree hashing
+// with SHA-256.
+type MerkleLog struct {
+	mu     sync.Mutex
+	leaves [][]byte
+}
+
+// Append adds an entry to the log and returns its leaf index.
+func (l *MerkleLog) Append(entry []byte) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.leaves = append(l.leaves, hashLeaf(entry))
+	return uint64(len(l.leaves) - 1)
+}
+
+func (l *MerkleLog) Size() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.leaves))
+}
+
+// Root returns the tree head for the first size leaves.
+func (l *MerkleLog) Root(size uint64) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size > uint64(len(l.leaves)) {
+		return nil, errors.New("Tree size is larger than the log")
+	}
+	return merkleTreeHash(l.leaves[:size]), nil
+}
+
+// InclusionProof returns the inclusion path of the leaf at index in the
+// tree of the first size leaves.
+func (l *MerkleLog) InclusionProof(index uint64, size uint64) ([][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size > uint64(len(l.leaves)) {
+		return nil, errors.New("Tree size is larger than the log")
+	}
+	if index >= size {
+		return nil, errors.New("Leaf index is outside of the tree")
+	}
+	return inclusionPath(index, l.leaves[:size]), nil
+}
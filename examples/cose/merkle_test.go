@@ -0,0 +1,73 @@
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// see: https://github.com/google/certificate-transparency/blob/master/python/ct/crypto/merkle_test.py
+var merkle_leaves = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+
+// TestMerkleTreeHash calls cose.MerkleLog.Root and confirms the tree heads
+// match the certificate transparency test vectors
+func TestMerkleTreeHash(t *testing.T) {
+	var log MerkleLog
+	root, _ := log.Root(0)
+	if hex.EncodeToString(root) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("Invalid empty tree head")
+	}
+	for _, leaf := range merkle_leaves {
+		entry, _ := hex.DecodeString(leaf)
+		log.Append(entry)
+	}
+	root, _ = log.Root(1)
+	if hex.EncodeToString(root) != "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d" {
+		t.Fatalf("Invalid tree head of size 1")
+	}
+	root, _ = log.Root(8)
+	if hex.EncodeToString(root) != "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328" {
+		t.Fatalf("Invalid tree head of size 8")
+	}
+	_, err := log.Root(9)
+	if err == nil {
+		t.Fatalf("Computed a tree head larger than the log")
+	}
+}
+
+// TestMerkleInclusionProof calls cose.MerkleLog.InclusionProof for every
+// leaf of trees up to size 33 and confirms each leads to the tree head
+func TestMerkleInclusionProof(t *testing.T) {
+	var log MerkleLog
+	for i := 0; i < 33; i++ {
+		log.Append([]byte{byte(i)})
+	}
+	for size := uint64(1); size <= log.Size(); size++ {
+		root, _ := log.Root(size)
+		for index := uint64(0); index < size; index++ {
+			path, err := log.InclusionProof(index, size)
+			if err != nil {
+				t.Fatalf("Inclusion proof %d in %d failed: %v", index, size, err)
+			}
+			leaf_hash := hashLeaf([]byte{byte(index)})
+			proof_root, err := rootFromInclusionPath(index, size, leaf_hash, path)
+			if err != nil || !bytes.Equal(proof_root, root) {
+				t.Fatalf("Inclusion proof %d in %d does not lead to the tree head", index, size)
+			}
+			if size > 1 {
+				proof_root, err = rootFromInclusionPath((index+1)%size, size, leaf_hash, path)
+				if err == nil && bytes.Equal(proof_root, root) {
+					t.Fatalf("Inclusion proof %d in %d verified at the wrong index", index, size)
+				}
+				_, err = rootFromInclusionPath(index, size, leaf_hash, path[:len(path)-1])
+				if err == nil {
+					t.Fatalf("Truncated inclusion proof %d in %d was accepted", index, size)
+				}
+			}
+		}
+	}
+	_, err := log.InclusionProof(5, 5)
+	if err == nil {
+		t.Fatalf("Proved inclusion of a leaf outside of the tree")
+	}
+}
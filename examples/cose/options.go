@@ -0,0 +1,112 @@
+package cose
+
+import (
+	"io"
+	"time"
+)
+
+type signOptions struct {
+	untagged bool
+	cwt_tag  bool
+	hedged   bool
+	rand     io.Reader
+	ctx      []byte
+}
+
+type SignOption func(*signOptions)
+
+// Untagged produces a COSE_Sign1 without the COSE_Sign1_Tagged (18) tag.
+func Untagged() SignOption {
+	return func(o *signOptions) {
+		o.untagged = true
+	}
+}
+
+// WithCWTTag wraps the COSE_Sign1_Tagged message in the CWT (61) tag.
+func WithCWTTag() SignOption {
+	return func(o *signOptions) {
+		o.cwt_tag = true
+	}
+}
+
+// Hedged signs with the hedged variant of ML-DSA, reading the per-signature
+// randomness from rand, or from crypto/rand when rand is nil.
+func Hedged(rand io.Reader) SignOption {
+	return func(o *signOptions) {
+		o.hedged = true
+		o.rand = rand
+	}
+}
+
+// WithExperimentalContext signs with a non-empty ML-DSA context string,
+// carried in the HEADER_LABEL_EXPERIMENTAL_CONTEXT header parameter.
+func WithExperimentalContext(ctx []byte) SignOption {
+	return func(o *signOptions) {
+		o.ctx = ctx
+	}
+}
+
+func newSignOptions(opts []SignOption) signOptions {
+	var o signOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type verifyOptions struct {
+	required_tags  []uint64
+	forbidden_tags []uint64
+	ctx            []byte
+	now            func() time.Time
+	leeway         time.Duration
+}
+
+type VerifyOption func(*verifyOptions)
+
+// RequireTag rejects messages that are not enclosed in the given tag.
+func RequireTag(tag uint64) VerifyOption {
+	return func(o *verifyOptions) {
+		o.required_tags = append(o.required_tags, tag)
+	}
+}
+
+// ForbidTag rejects messages that are enclosed in the given tag.
+func ForbidTag(tag uint64) VerifyOption {
+	return func(o *verifyOptions) {
+		o.forbidden_tags = append(o.forbidden_tags, tag)
+	}
+}
+
+// ExpectExperimentalContext verifies with a non-empty ML-DSA context
+// string, which the experimental context header parameter must match.
+// Without it, messages with the experimental context header are rejected.
+func ExpectExperimentalContext(ctx []byte) VerifyOption {
+	return func(o *verifyOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithClock sets the clock used to validate exp and nbf in the CWT Claims
+// header, defaulting to time.Now.
+func WithClock(now func() time.Time) VerifyOption {
+	return func(o *verifyOptions) {
+		o.now = now
+	}
+}
+
+// WithLeeway allows for clock skew when validating exp and nbf in the CWT
+// Claims header.
+func WithLeeway(leeway time.Duration) VerifyOption {
+	return func(o *verifyOptions) {
+		o.leeway = leeway
+	}
+}
+
+func newVerifyOptions(opts []VerifyOption) verifyOptions {
+	var o verifyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,259 @@
+package cose
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// see: https://datatracker.ietf.org/doc/draft-ietf-cose-merkle-tree-proofs/
+const (
+	HEADER_LABEL_RECEIPTS int64 = 394
+	HEADER_LABEL_VDS      int64 = 395
+	HEADER_LABEL_VDP      int64 = 396
+
+	VDS_RFC9162_SHA256         = 1
+	VDP_INCLUSION_PROOFS int64 = -1
+)
+
+// see: https://datatracker.ietf.org/doc/html/draft-ietf-cose-merkle-tree-proofs#section-5.2
+type inclusionProof struct {
+	_         struct{} `cbor:",toarray"`
+	TreeSize  uint64
+	LeafIndex uint64
+	Path      [][]byte
+}
+
+// TransparencyService registers signed statements in a MerkleLog and issues
+// receipts for them, signed with the private key of the service.
+type TransparencyService struct {
+	log         MerkleLog
+	private_key []byte
+	header      Header
+}
+
+func NewTransparencyService(private_key []byte) (*TransparencyService, error) {
+	key, err := DecodeKey(private_key)
+	if err != nil {
+		return nil, err
+	}
+	if key.Priv == nil {
+		return nil, errors.New("COSE Key is not a private key")
+	}
+	return &TransparencyService{
+		private_key: private_key,
+		header: Header{
+			Alg: key.Alg,
+			Kid: key.Kid,
+		},
+	}, nil
+}
+
+// statementEntry is the signed statement as it is stored in the log,
+// without any receipts in its unprotected header.
+func statementEntry(statement []byte) ([]byte, error) {
+	sign1, tags, err := decodeSign1(statement)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := sign1.Headers.Unprotected[HEADER_LABEL_RECEIPTS]; exists {
+		delete(sign1.Headers.Unprotected, HEADER_LABEL_RECEIPTS)
+		sign1.Headers.RawUnprotected = nil
+	}
+	return encodeSign1(&sign1, tags)
+}
+
+// Register appends a signed statement to the log, and returns a receipt
+// for its inclusion in the current tree.
+func (s *TransparencyService) Register(statement []byte) ([]byte, error) {
+	entry, err := statementEntry(statement)
+	if err != nil {
+		return nil, err
+	}
+	index := s.log.Append(entry)
+	return s.Receipt(index, index+1)
+}
+
+// Receipt issues a receipt for the leaf at index in the tree of the first
+// size leaves.
+func (s *TransparencyService) Receipt(index uint64, size uint64) ([]byte, error) {
+	path, err := s.log.InclusionProof(index, size)
+	if err != nil {
+		return nil, err
+	}
+	root, err := s.log.Root(size)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := cbor.Marshal(inclusionProof{
+		TreeSize:  size,
+		LeafIndex: index,
+		Path:      path,
+	})
+	if err != nil {
+		return nil, err
+	}
+	headers, err := headersForSigning(s.header, signOptions{})
+	if err != nil {
+		return nil, err
+	}
+	headers.Protected[HEADER_LABEL_VDS] = VDS_RFC9162_SHA256
+	headers.Unprotected = cose.UnprotectedHeader{
+		HEADER_LABEL_VDP: map[int64][][]byte{
+			VDP_INCLUSION_PROOFS: {proof},
+		},
+	}
+	signer, err := signerFromPrivateKey(s.private_key)
+	if err != nil {
+		return nil, err
+	}
+	receipt := cose.Sign1Message{
+		Headers: headers,
+		Payload: root,
+	}
+	err = receipt.Sign(nil, nil, signer)
+	if err != nil {
+		return nil, err
+	}
+	// the tree head is detached, verifiers recompute it from the proof
+	receipt.Payload = nil
+	return encodeSign1(&receipt, []uint64{TAG_COSE_SIGN1})
+}
+
+// AttachReceipt adds a receipt to the unprotected header of a signed
+// statement, producing a transparent statement.
+func AttachReceipt(statement []byte, receipt []byte) ([]byte, error) {
+	sign1, tags, err := decodeSign1(statement)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := ReceiptsFromStatement(statement)
+	if err != nil {
+		return nil, err
+	}
+	if sign1.Headers.Unprotected == nil {
+		sign1.Headers.Unprotected = cose.UnprotectedHeader{}
+	}
+	sign1.Headers.Unprotected[HEADER_LABEL_RECEIPTS] = append(receipts, receipt)
+	sign1.Headers.RawUnprotected = nil
+	return encodeSign1(&sign1, tags)
+}
+
+func ReceiptsFromStatement(statement []byte) ([][]byte, error) {
+	sign1, _, err := decodeSign1(statement)
+	if err != nil {
+		return nil, err
+	}
+	value, exists := sign1.Headers.Unprotected[HEADER_LABEL_RECEIPTS]
+	if !exists {
+		return nil, nil
+	}
+	values, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("Malformed receipts header")
+	}
+	var receipts [][]byte
+	for _, value := range values {
+		receipt, ok := value.([]byte)
+		if !ok {
+			return nil, errors.New("Malformed receipts header")
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+func inclusionProofsFromReceipt(receipt *cose.Sign1Message) ([]inclusionProof, error) {
+	var vdp map[int64]cbor.RawMessage
+	err := remarshal(receipt.Headers.Unprotected[HEADER_LABEL_VDP], &vdp)
+	if err != nil {
+		return nil, errors.New("Malformed verifiable data proof header")
+	}
+	var encoded_proofs [][]byte
+	err = cbor.Unmarshal(vdp[VDP_INCLUSION_PROOFS], &encoded_proofs)
+	if err != nil || len(encoded_proofs) == 0 {
+		return nil, errors.New("Receipt has no inclusion proofs")
+	}
+	var proofs []inclusionProof
+	for _, encoded_proof := range encoded_proofs {
+		var proof inclusionProof
+		err = cbor.Unmarshal(encoded_proof, &proof)
+		if err != nil {
+			return nil, errors.New("Malformed inclusion proof")
+		}
+		proofs = append(proofs, proof)
+	}
+	return proofs, nil
+}
+
+func remarshal(value any, v any) error {
+	data, err := cbor.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return cbor.Unmarshal(data, v)
+}
+
+// VerifyReceipt confirms a receipt from the public key of a transparency
+// service proves the inclusion of the signed statement in its log.
+func VerifyReceipt(public_key []byte, receipt []byte, statement []byte) error {
+	verifier, err := verifierFromPublicKey(public_key)
+	if err != nil {
+		return err
+	}
+	sign1, _, err := decodeSign1(receipt)
+	if err != nil {
+		return err
+	}
+	if sign1.Payload != nil {
+		return errors.New("Receipt payload is not detached")
+	}
+	vds, ok := sign1.Headers.Protected[HEADER_LABEL_VDS].(int64)
+	if !ok || vds != VDS_RFC9162_SHA256 {
+		return errors.New("Unsupported verifiable data structure")
+	}
+	proofs, err := inclusionProofsFromReceipt(&sign1)
+	if err != nil {
+		return err
+	}
+	entry, err := statementEntry(statement)
+	if err != nil {
+		return err
+	}
+	leaf_hash := hashLeaf(entry)
+	var root []byte
+	for _, proof := range proofs {
+		proof_root, err := rootFromInclusionPath(proof.LeafIndex, proof.TreeSize, leaf_hash, proof.Path)
+		if err != nil {
+			return err
+		}
+		if root != nil && !bytes.Equal(root, proof_root) {
+			return errors.New("Inclusion proofs do not lead to the same root")
+		}
+		root = proof_root
+	}
+	sign1.Payload = root
+	return sign1.Verify(nil, verifier)
+}
+
+// VerifyTransparentStatement verifies a signed statement with the public key
+// of its issuer, and confirms it carries a receipt that verifies with the
+// public key of the transparency service.
+func VerifyTransparentStatement(issuer_public_key []byte, service_public_key []byte, statement []byte, opts ...VerifyOption) (Sign1Verification, error) {
+	verified, err := VerifySign1(issuer_public_key, statement, opts...)
+	if err != nil {
+		return verified, err
+	}
+	receipts, err := ReceiptsFromStatement(statement)
+	if err != nil {
+		return verified, err
+	}
+	for _, receipt := range receipts {
+		if VerifyReceipt(service_public_key, receipt, statement) == nil {
+			return verified, nil
+		}
+	}
+	return verified, errors.New("Statement has no receipt from the transparency service")
+}
@@ -0,0 +1,112 @@
+package cose
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+type COSEReceiptTestVector struct {
+	ServiceKey      string `json:"service_key"`
+	Statement       string `json:"signed_statement"`
+	Receipt         string `json:"receipt"`
+	ReceiptDiag     string `json:"receipt_diag"`
+	Transparent     string `json:"transparent_statement"`
+	TransparentDiag string `json:"transparent_statement_diag"`
+}
+
+// TestReceipt calls cose.TransparencyService.Register for each ML-DSA level
+// and confirms the transparent statement verifies with its receipt
+func TestReceipt(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		name, _ := AlgorithmToSuite(alg)
+		issuer_private_key, _ := GenerateKey(alg, seed[:])
+		issuer_public_key, _ := PublicKeyFromPrivateKey(issuer_private_key)
+		issuer_key, _ := DecodeKey(issuer_private_key)
+		service_private_key, _ := GenerateKey(alg, notary_seed)
+		service_public_key, _ := PublicKeyFromPrivateKey(service_private_key)
+		service, err := NewTransparencyService(service_private_key)
+		if err != nil {
+			t.Fatalf("Creating %s transparency service failed: %v", name, err)
+		}
+
+		var statements [][]byte
+		for _, subject := range []string{"a", "b", "c", "d", "e"} {
+			statement, _ := Sign1(issuer_private_key, Header{
+				Alg:       issuer_key.Alg,
+				Kid:       issuer_key.Kid,
+				CWTClaims: &Claims{Issuer: "https://issuer.example", Subject: subject},
+			}, payload)
+			receipt, err := service.Register(statement)
+			if err != nil {
+				t.Fatalf("Registering %s statement failed: %v", name, err)
+			}
+			statement, _ = AttachReceipt(statement, receipt)
+			statements = append(statements, statement)
+		}
+		for _, statement := range statements {
+			_, err = VerifyTransparentStatement(issuer_public_key, service_public_key, statement)
+			if err != nil {
+				t.Fatalf("Verifying %s transparent statement failed: %v", name, err)
+			}
+			_, err = VerifyTransparentStatement(issuer_public_key, issuer_public_key, statement)
+			if err == nil {
+				t.Fatalf("Verified a receipt with the wrong key")
+			}
+		}
+		receipts, _ := ReceiptsFromStatement(statements[0])
+		err = VerifyReceipt(service_public_key, receipts[0], statements[1])
+		if err == nil {
+			t.Fatalf("Verified a receipt for another statement")
+		}
+		latest, _ := service.Receipt(0, service.log.Size())
+		statement, _ := AttachReceipt(statements[0], latest)
+		receipts, _ = ReceiptsFromStatement(statement)
+		if len(receipts) != 2 {
+			t.Fatalf("Invalid receipt count (%d), want 2", len(receipts))
+		}
+		err = VerifyReceipt(service_public_key, latest, statement)
+		if err != nil {
+			t.Fatalf("Verifying a receipt for a larger tree failed: %v", err)
+		}
+
+		signed_statement, _ := statementEntry(statements[0])
+		rd, _ := cbor.Diagnose(receipts[0])
+		td, _ := cbor.Diagnose(statements[0])
+		examples, _ := json.MarshalIndent(COSEReceiptTestVector{
+			ServiceKey:      hex.EncodeToString(service_public_key),
+			Statement:       hex.EncodeToString(signed_statement),
+			Receipt:         hex.EncodeToString(receipts[0]),
+			ReceiptDiag:     rd,
+			Transparent:     hex.EncodeToString(statements[0]),
+			TransparentDiag: td,
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(name, "-", "_")+".receipt.cose.json", examples, 0644)
+	}
+}
+
+// TestReceiptRejected confirms statements without receipts, and receipts
+// without a detached payload, are rejected
+func TestReceiptRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	statement, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	_, err := VerifyTransparentStatement(public_key, public_key, statement)
+	if err == nil {
+		t.Fatalf("Verified a statement without receipts")
+	}
+	err = VerifyReceipt(public_key, statement, statement)
+	if err == nil {
+		t.Fatalf("Verified a signed statement as a receipt")
+	}
+	_, err = NewTransparencyService(public_key)
+	if err == nil {
+		t.Fatalf("Created a transparency service with a public key")
+	}
+}
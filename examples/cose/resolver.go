@@ -0,0 +1,136 @@
+package cose
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/veraison/go-cose"
+)
+
+// KeyResolver maps the kid and alg of a protected header to candidate AKP
+// public keys. The kid is either the key identifier of the key, its COSE
+// Key thumbprint, or its COSE Key thumbprint URI.
+type KeyResolver interface {
+	Resolve(kid []byte, alg cose.Algorithm) ([][]byte, error)
+}
+
+func keyMatches(cose_key []byte, kid []byte, alg cose.Algorithm) bool {
+	key, err := DecodeKey(cose_key)
+	if err != nil || key.Alg != alg {
+		return false
+	}
+	if kid == nil || (key.Kid != nil && bytes.Equal(kid, key.Kid)) {
+		return true
+	}
+	thumbprint, err := CalculateCoseKeyThumbprint(cose_key)
+	if err != nil {
+		return false
+	}
+	if bytes.Equal(kid, thumbprint) {
+		return true
+	}
+	uri_thumbprint, err := ParseCoseKeyThumbprintURI(string(kid))
+	return err == nil && bytes.Equal(uri_thumbprint, thumbprint)
+}
+
+func resolveFromKeys(cose_keys [][]byte, kid []byte, alg cose.Algorithm) ([][]byte, error) {
+	var candidates [][]byte
+	for _, cose_key := range cose_keys {
+		if !keyMatches(cose_key, kid, alg) {
+			continue
+		}
+		public_key, err := PublicKeyFromPrivateKey(cose_key)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, public_key)
+	}
+	return candidates, nil
+}
+
+type staticKeyResolver struct {
+	cose_keys [][]byte
+}
+
+func (r *staticKeyResolver) Resolve(kid []byte, alg cose.Algorithm) ([][]byte, error) {
+	return resolveFromKeys(r.cose_keys, kid, alg)
+}
+
+func NewStaticKeyResolver(cose_keys ...[]byte) KeyResolver {
+	return &staticKeyResolver{cose_keys: cose_keys}
+}
+
+func NewKeySetResolver(cose_key_set []byte) (KeyResolver, error) {
+	cose_keys, err := DecodeKeySet(cose_key_set)
+	if err != nil {
+		return nil, err
+	}
+	return &staticKeyResolver{cose_keys: cose_keys}, nil
+}
+
+type directoryKeyResolver struct {
+	dir string
+}
+
+// Resolve reads the directory on every call, so that keys added to or
+// removed from the directory are picked up.
+func (r *directoryKeyResolver) Resolve(kid []byte, alg cose.Algorithm) ([][]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.cose"))
+	if err != nil {
+		return nil, err
+	}
+	var cose_keys [][]byte
+	for _, path := range paths {
+		cose_key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		cose_keys = append(cose_keys, cose_key)
+	}
+	return resolveFromKeys(cose_keys, kid, alg)
+}
+
+// NewDirectoryResolver resolves keys from the COSE Key files with the .cose
+// extension in dir.
+func NewDirectoryResolver(dir string) KeyResolver {
+	return &directoryKeyResolver{dir: dir}
+}
+
+func VerifySign1WithResolver(resolver KeyResolver, signature []byte, opts ...VerifyOption) (Sign1Verification, error) {
+	return verifySign1WithResolver(resolver, nil, signature, newVerifyOptions(opts))
+}
+
+func verifySign1WithResolver(resolver KeyResolver, cache *verifierCache, signature []byte, o verifyOptions) (Sign1Verification, error) {
+	var verified = Sign1Verification{}
+	sign1, _, err := decodeSign1(signature)
+	if err != nil {
+		return verified, err
+	}
+	alg, err := sign1.Headers.Protected.Algorithm()
+	if err != nil {
+		return verified, errors.New("COSE_Sign1 is missing the protected header algorithm")
+	}
+	kid, _ := sign1.Headers.Protected[cose.HeaderLabelKeyID].([]byte)
+	candidates, err := resolver.Resolve(kid, alg)
+	if err != nil {
+		return verified, err
+	}
+	if len(candidates) == 0 {
+		return verified, errors.New("No public key found for COSE_Sign1")
+	}
+	var verify_error error
+	for _, public_key := range candidates {
+		verifier, err := cache.verifier(public_key)
+		if err != nil {
+			verify_error = err
+			continue
+		}
+		verified, verify_error = verifySign1(*verifier, public_key, signature, o)
+		if verify_error == nil {
+			return verified, nil
+		}
+	}
+	return Sign1Verification{}, verify_error
+}
@@ -0,0 +1,122 @@
+package cose
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/veraison/go-cose"
+)
+
+func resolverTestKeys(t *testing.T) ([][]byte, [][]byte) {
+	var private_keys, public_keys [][]byte
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		for _, key_seed := range [][]byte{seed[:], notary_seed} {
+			private_key, err := GenerateKey(alg, key_seed)
+			if err != nil {
+				t.Fatalf("Failed to generate key")
+			}
+			public_key, _ := PublicKeyFromPrivateKey(private_key)
+			private_keys = append(private_keys, private_key)
+			public_keys = append(public_keys, public_key)
+		}
+	}
+	return private_keys, public_keys
+}
+
+func checkResolver(t *testing.T, resolver KeyResolver, private_keys [][]byte, public_keys [][]byte) {
+	for i, private_key := range private_keys {
+		key, _ := DecodeKey(private_key)
+		uri, _ := CalculateCoseKeyThumbprintURI(private_key)
+		for _, kid := range [][]byte{key.Kid, []byte(uri)} {
+			signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: kid}, payload)
+			verified, err := VerifySign1WithResolver(resolver, signature)
+			if err != nil {
+				t.Fatalf("Verification with resolver failed: %v", err)
+			}
+			if !bytes.Equal(verified.PublicKey, public_keys[i]) {
+				t.Fatalf("Verified with unexpected public key")
+			}
+			if !bytes.Equal(verified.Header.Kid, kid) || string(verified.Payload) != string(payload) {
+				t.Fatalf("Invalid verification result")
+			}
+		}
+		// without a kid, every key of the algorithm is a candidate
+		signature, _ := Sign1(private_key, Header{Alg: key.Alg}, payload)
+		verified, err := VerifySign1WithResolver(resolver, signature)
+		if err != nil {
+			t.Fatalf("Verification with resolver without kid failed: %v", err)
+		}
+		if !bytes.Equal(verified.PublicKey, public_keys[i]) {
+			t.Fatalf("Verified with unexpected public key")
+		}
+	}
+	unknown_key, _ := GenerateKey(ML_DSA_44, bytes.Repeat([]byte{2}, 32))
+	key, _ := DecodeKey(unknown_key)
+	signature, _ := Sign1(unknown_key, Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	_, err := VerifySign1WithResolver(resolver, signature)
+	if err == nil {
+		t.Fatalf("Verified with a key missing from the resolver")
+	}
+	// a known kid, signed by a different key
+	known, _ := DecodeKey(private_keys[0])
+	signature, _ = Sign1(unknown_key, Header{Alg: key.Alg, Kid: known.Kid}, payload)
+	_, err = VerifySign1WithResolver(resolver, signature)
+	if err == nil {
+		t.Fatalf("Verified a signature from a key with a reused kid")
+	}
+}
+
+// TestStaticKeyResolver confirms cose.VerifySign1WithResolver finds keys by
+// kid, thumbprint URI and alg in a static list of keys
+func TestStaticKeyResolver(t *testing.T) {
+	private_keys, public_keys := resolverTestKeys(t)
+	checkResolver(t, NewStaticKeyResolver(public_keys...), private_keys, public_keys)
+	// private keys are reduced to public keys
+	checkResolver(t, NewStaticKeyResolver(private_keys...), private_keys, public_keys)
+}
+
+// TestKeySetResolver confirms cose.VerifySign1WithResolver finds keys in a
+// COSE_KeySet
+func TestKeySetResolver(t *testing.T) {
+	private_keys, public_keys := resolverTestKeys(t)
+	key_set, _ := EncodeKeySet(public_keys...)
+	resolver, err := NewKeySetResolver(key_set)
+	if err != nil {
+		t.Fatalf("Failed to create key set resolver: %v", err)
+	}
+	checkResolver(t, resolver, private_keys, public_keys)
+	_, err = NewKeySetResolver(public_keys[0])
+	if err == nil {
+		t.Fatalf("Created key set resolver from a COSE Key")
+	}
+}
+
+// TestDirectoryResolver confirms cose.VerifySign1WithResolver finds keys in
+// a directory of .cose files, and picks up keys added later
+func TestDirectoryResolver(t *testing.T) {
+	private_keys, public_keys := resolverTestKeys(t)
+	dir := t.TempDir()
+	resolver := NewDirectoryResolver(dir)
+	key, _ := DecodeKey(private_keys[0])
+	signature, _ := Sign1(private_keys[0], Header{Alg: key.Alg, Kid: key.Kid}, payload)
+	_, err := VerifySign1WithResolver(resolver, signature)
+	if err == nil {
+		t.Fatalf("Verified with an empty directory")
+	}
+	for i, public_key := range public_keys {
+		uri, _ := CalculateCoseKeyThumbprintURI(public_key)
+		name := filepath.Join(dir, filepath.Base(uri[len(COSE_KEY_THUMBPRINT_URI_PREFIX):])+".cose")
+		if i == 0 {
+			// files without the .cose extension are ignored
+			os.WriteFile(filepath.Join(dir, "key.cbor"), public_key, 0644)
+			_, err = VerifySign1WithResolver(resolver, signature)
+			if err == nil {
+				t.Fatalf("Verified with a key without the .cose extension")
+			}
+		}
+		os.WriteFile(name, public_key, 0644)
+	}
+	checkResolver(t, resolver, private_keys, public_keys)
+}
@@ -1,12 +1,15 @@
 package cose
 
 import (
+	crypto_rand "crypto/rand"
+	"crypto/sha512"
 	"errors"
 	"io"
 
 	"github.com/cloudflare/circl/sign"
 	"github.com/cloudflare/circl/sign/schemes"
 
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/mldsa"
 	"github.com/fxamacker/cbor/v2"
 	"github.com/veraison/go-cose"
 )
@@ -14,16 +17,22 @@ import (
 type Header struct {
 	Alg cose.Algorithm `cbor:"1,keyasint,omitempty"`
 	Kid []byte         `cbor:"4,keyasint,omitempty"`
+	// see: https://datatracker.ietf.org/doc/html/rfc9597
+	CWTClaims *Claims `cbor:"15,keyasint,omitempty"`
 }
 
 type Sign1Verification struct {
-	Header  Header
-	Payload []byte
+	Header    Header
+	Payload   []byte
+	Tags      []uint64
+	PublicKey []byte
 }
 
 type keySigner struct {
-	alg cose.Algorithm
-	key sign.PrivateKey
+	alg    cose.Algorithm
+	key    sign.PrivateKey
+	hedged bool
+	ctx    []byte
 }
 
 func (ks *keySigner) Algorithm() cose.Algorithm {
@@ -31,14 +40,33 @@ func (ks *keySigner) Algorithm() cose.Algorithm {
 }
 
 func (ks *keySigner) Sign(rand io.Reader, content []byte) ([]byte, error) {
+	var rnd [32]byte
+	if ks.hedged {
+		if rand == nil {
+			rand = crypto_rand.Reader
+		}
+		var err error
+		rnd, err = mldsa.Randomness(rand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if IsHashMLDSA(ks.alg) {
+		digest := sha512.Sum512(content)
+		return mldsa.Sign(ks.key, mldsa.PreHashSHA512(ks.ctx, digest[:]), rnd)
+	}
+	if ks.hedged {
+		return mldsa.Sign(ks.key, mldsa.Pure(ks.ctx, content), rnd)
+	}
 	name, _ := AlgorithmToSuite(ks.alg)
 	suite := schemes.ByName(name)
-	return suite.Sign(ks.key, content, nil), nil
+	return suite.Sign(ks.key, content, &sign.SignatureOpts{Context: string(ks.ctx)}), nil
 }
 
 type keyVerifier struct {
 	alg cose.Algorithm
 	key sign.PublicKey
+	ctx []byte
 }
 
 func (ks *keyVerifier) Algorithm() cose.Algorithm {
@@ -46,9 +74,15 @@ func (ks *keyVerifier) Algorithm() cose.Algorithm {
 }
 
 func (ks *keyVerifier) Verify(content []byte, signature []byte) error {
-	name, _ := AlgorithmToSuite(ks.alg)
-	suite := schemes.ByName(name)
-	valid := suite.Verify(ks.key, content, signature, nil)
+	var valid bool
+	if IsHashMLDSA(ks.alg) {
+		digest := sha512.Sum512(content)
+		valid, _ = mldsa.Verify(ks.key, mldsa.PreHashSHA512(ks.ctx, digest[:]), signature)
+	} else {
+		name, _ := AlgorithmToSuite(ks.alg)
+		suite := schemes.ByName(name)
+		valid = suite.Verify(ks.key, content, signature, &sign.SignatureOpts{Context: string(ks.ctx)})
+	}
 	if !valid {
 		return errors.New("Signature not from public key")
 	}
@@ -63,6 +97,13 @@ func AlgorithmToSuite(alg cose.Algorithm) (string, error) {
 		return "ML-DSA-65", nil
 	case ML_DSA_87:
 		return "ML-DSA-87", nil
+	// HashML-DSA keys use the parameter set of the corresponding ML-DSA
+	case HASH_ML_DSA_44_SHA512:
+		return "ML-DSA-44", nil
+	case HASH_ML_DSA_65_SHA512:
+		return "ML-DSA-65", nil
+	case HASH_ML_DSA_87_SHA512:
+		return "ML-DSA-87", nil
 	default:
 		return "", errors.New(("Unknown algorithm"))
 	}
@@ -131,11 +172,13 @@ func deterministicBinaryString(data cbor.RawMessage) (cbor.RawMessage, error) {
 }
 
 func ToBeSignedFromSign1(signature []byte) ([]byte, error) {
-	var sign1 cose.Sign1Message
-	sign1.UnmarshalCBOR(signature)
+	sign1, _, err := decodeSign1(signature)
+	if err != nil {
+		return nil, err
+	}
 	var external []byte = nil
 	var protected cbor.RawMessage
-	protected, err := sign1.Headers.MarshalProtected()
+	protected, err = sign1.Headers.MarshalProtected()
 	if err != nil {
 		return nil, err
 	}
@@ -158,53 +201,157 @@ func ToBeSignedFromSign1(signature []byte) ([]byte, error) {
 }
 
 func SignatureFromSign1(signature []byte) ([]byte, error) {
-	var sign1 cose.Sign1Message
-	sign1.UnmarshalCBOR(signature)
+	sign1, _, err := decodeSign1(signature)
+	if err != nil {
+		return nil, err
+	}
 	return sign1.Signature, nil
 }
 
-func Sign1(private_key []byte, header Header, payload []byte) ([]byte, error) {
+func signerFromPrivateKey(private_key []byte) (*keySigner, error) {
 	var key AKPKey
-	cbor.Unmarshal(private_key, &key)
-	var suite sign.Scheme
-	name, _ := AlgorithmToSuite(key.Alg)
-	suite = schemes.ByName(name)
+	err := cbor.Unmarshal(private_key, &key)
+	if err != nil {
+		return nil, errors.New("Failed to decode cose key")
+	}
+	name, err := AlgorithmToSuite(key.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if len(key.Priv) == 0 {
+		return nil, errors.New("COSE Key is not a private key")
+	}
+	suite := schemes.ByName(name)
+	if len(key.Priv) != suite.SeedSize() {
+		return nil, errors.New("COSE Key has malformed priv")
+	}
 	_, priv := suite.DeriveKey(key.Priv)
-	var signer cose.Signer = &keySigner{
+	return &keySigner{
 		alg: key.Alg,
 		key: priv,
+	}, nil
+}
+
+func verifierFromPublicKey(public_key []byte) (*keyVerifier, error) {
+	var key AKPKey
+	err := cbor.Unmarshal(public_key, &key)
+	if err != nil {
+		return nil, errors.New("Failed to decode cose key")
 	}
+	name, err := AlgorithmToSuite(key.Alg)
+	if err != nil {
+		return nil, err
+	}
+	suite := schemes.ByName(name)
+	pub, err := suite.UnmarshalBinaryPublicKey(key.Pub)
+	if err != nil {
+		return nil, errors.New("COSE Key has malformed pub")
+	}
+	return &keyVerifier{
+		alg: key.Alg,
+		key: pub,
+	}, nil
+}
+
+func headersForSigning(header Header, o signOptions) (cose.Headers, error) {
 	headers := cose.Headers{
 		Protected: cose.ProtectedHeader{
 			cose.HeaderLabelAlgorithm: header.Alg,
-			cose.HeaderLabelKeyID:     header.Kid,
 		},
 	}
-	sign1, _ := cose.Sign1(nil, signer, headers, payload, nil)
-	return sign1, nil
+	if header.Kid != nil {
+		headers.Protected[cose.HeaderLabelKeyID] = header.Kid
+	}
+	if header.CWTClaims != nil {
+		claims, err := header.CWTClaims.MarshalCBOR()
+		if err != nil {
+			return headers, err
+		}
+		headers.Protected[cose.HeaderLabelCWTClaims] = cbor.RawMessage(claims)
+	}
+	if len(o.ctx) != 0 {
+		err := checkContext(o.ctx)
+		if err != nil {
+			return headers, err
+		}
+		headers.Protected[HEADER_LABEL_EXPERIMENTAL_CONTEXT] = o.ctx
+	}
+	return headers, nil
 }
 
-func VerifySign1(public_key []byte, signature []byte) (Sign1Verification, error) {
-	var key AKPKey
+func Sign1(private_key []byte, header Header, payload []byte, opts ...SignOption) ([]byte, error) {
+	o := newSignOptions(opts)
+	headers, err := headersForSigning(header, o)
+	if err != nil {
+		return nil, err
+	}
+	return sign1WithHeaders(private_key, headers, payload, o)
+}
+
+func sign1WithHeaders(private_key []byte, headers cose.Headers, payload []byte, o signOptions) ([]byte, error) {
+	tags, err := tagsForSigning(o)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := signerFromPrivateKey(private_key)
+	if err != nil {
+		return nil, err
+	}
+	signer.hedged = o.hedged
+	signer.ctx = o.ctx
+	sign1 := cose.Sign1Message{
+		Headers: headers,
+		Payload: payload,
+	}
+	err = sign1.Sign(o.rand, nil, signer)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSign1(&sign1, tags)
+}
+
+func VerifySign1(public_key []byte, signature []byte, opts ...VerifyOption) (Sign1Verification, error) {
+	verifier, err := verifierFromPublicKey(public_key)
+	if err != nil {
+		return Sign1Verification{}, err
+	}
+	return verifySign1(*verifier, public_key, signature, newVerifyOptions(opts))
+}
+
+// verifySign1 takes the verifier by value, so that a parsed public key can
+// be shared between concurrent verifications.
+func verifySign1(verifier keyVerifier, public_key []byte, signature []byte, o verifyOptions) (Sign1Verification, error) {
 	var verified = Sign1Verification{}
-	cbor.Unmarshal(public_key, &key)
-	name, _ := AlgorithmToSuite(key.Alg)
-	suite := schemes.ByName(name)
-	pub, _ := suite.UnmarshalBinaryPublicKey(key.Pub)
-	var sign1 cose.Sign1Message
-	sign1.UnmarshalCBOR(signature)
-	var kv = keyVerifier{
-		alg: key.Alg,
-		key: pub,
+	sign1, tags, err := decodeSign1(signature)
+	if err != nil {
+		return verified, err
+	}
+	err = checkTags(o, tags)
+	if err != nil {
+		return verified, err
 	}
-	var verifier cose.Verifier = &kv
-	verify_error := sign1.Verify(nil, verifier)
+	verifier.ctx, err = contextForVerification(o, sign1.Headers.Protected)
+	if err != nil {
+		return verified, err
+	}
+	verify_error := sign1.Verify(nil, &verifier)
 	if verify_error != nil {
 		return verified, verify_error
 	}
-	var h []byte
-	cbor.Unmarshal(sign1.Headers.RawProtected, &h)
-	cbor.Unmarshal(h, &verified.Header)
+	verified.Header, err = headerForVerification(o, &sign1)
+	if err != nil {
+		return verified, err
+	}
 	verified.Payload = sign1.Payload
+	verified.Tags = tags
+	verified.PublicKey = public_key
 	return verified, nil
 }
+
+func headerFromSign1(sign1 *cose.Sign1Message) Header {
+	var header Header
+	var h []byte
+	cbor.Unmarshal(sign1.Headers.RawProtected, &h)
+	cbor.Unmarshal(h, &header)
+	return header
+}
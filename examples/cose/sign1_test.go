@@ -1,6 +1,7 @@
 package cose
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"os"
@@ -143,3 +144,50 @@ func TestSign1_2(t *testing.T) {
 	}, "", "  ")
 	_ = os.WriteFile("examples/ML_DSA_87.cose.json", examples, 0644)
 }
+
+// TestSign1Hedged calls cose.Sign1 with hedged signing and a fixed source of
+// randomness and confirms the result verifies and uses the randomness
+func TestSign1Hedged(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_65, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	var header = Header{
+		Alg: key.Alg,
+		Kid: key.Kid,
+	}
+	fixed_rand := bytes.Repeat([]byte{0x42}, 32)
+	deterministic, _ := Sign1(private_key, header, payload)
+	hedged, err := Sign1(private_key, header, payload, Hedged(bytes.NewReader(fixed_rand)))
+	if err != nil {
+		t.Fatalf("Hedged signing failed: %v", err)
+	}
+	_, verify_error := VerifySign1(public_key, hedged)
+	if verify_error != nil {
+		t.Fatalf("Verification failed")
+	}
+	deterministic_sig, _ := SignatureFromSign1(deterministic)
+	hedged_sig, _ := SignatureFromSign1(hedged)
+	if bytes.Equal(deterministic_sig, hedged_sig) {
+		t.Fatalf("Hedged signature is deterministic")
+	}
+	repeated, _ := Sign1(private_key, header, payload, Hedged(bytes.NewReader(fixed_rand)))
+	if !bytes.Equal(hedged, repeated) {
+		t.Fatalf("Hedged signature does not depend only on the injected randomness")
+	}
+	zero_rand, _ := Sign1(private_key, header, payload, Hedged(bytes.NewReader(make([]byte, 32))))
+	if !bytes.Equal(deterministic, zero_rand) {
+		t.Fatalf("Hedged signature with zero randomness differs from deterministic signature")
+	}
+	unseeded, err := Sign1(private_key, header, payload, Hedged(nil))
+	if err != nil {
+		t.Fatalf("Hedged signing with crypto/rand failed: %v", err)
+	}
+	_, verify_error = VerifySign1(public_key, unseeded)
+	if verify_error != nil {
+		t.Fatalf("Verification failed")
+	}
+	_, err = Sign1(private_key, header, payload, Hedged(bytes.NewReader(fixed_rand[:16])))
+	if err == nil {
+		t.Fatalf("Hedged signing succeeded with short randomness")
+	}
+}
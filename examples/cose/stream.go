@@ -0,0 +1,204 @@
+package cose
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"io"
+
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/mldsa"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+// sigStructurePrefix encodes the Sig_structure for a COSE_Sign1 up to and
+// including the head of the payload byte string, so that the payload can
+// be streamed after it.
+func sigStructurePrefix(sign1 *cose.Sign1Message, payload_size int64) ([]byte, error) {
+	if payload_size < 0 {
+		return nil, errors.New("Payload size must not be negative")
+	}
+	protected, err := sign1.Headers.MarshalProtected()
+	if err != nil {
+		return nil, err
+	}
+	protected, err = deterministicBinaryString(protected)
+	if err != nil {
+		return nil, err
+	}
+	context, _ := cbor.Marshal("Signature1")
+	prefix := []byte{0x84} // array(4)
+	prefix = append(prefix, context...)
+	prefix = append(prefix, protected...)
+	prefix = append(prefix, 0x40) // external_aad: empty bstr
+	return append(prefix, byteStringHead(uint64(payload_size))...), nil
+}
+
+func byteStringHead(size uint64) []byte {
+	const major = 2 << 5 // major type 2: bstr
+	switch {
+	case size < 24:
+		return []byte{major | byte(size)}
+	case size <= 0xff:
+		return []byte{major | 24, byte(size)}
+	case size <= 0xffff:
+		return []byte{major | 25, byte(size >> 8), byte(size)}
+	case size <= 0xffffffff:
+		return []byte{major | 26, byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size)}
+	default:
+		return []byte{major | 27, byte(size >> 56), byte(size >> 48), byte(size >> 40), byte(size >> 32), byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size)}
+	}
+}
+
+// streamedMessage writes the ML-DSA formatted message M' for the
+// Sig_structure of a streamed payload. The ToBeSigned bytes are never held
+// in memory, they are fed into the computation of mu as they are read.
+type streamedMessage struct {
+	ctx          []byte
+	prehash      bool
+	prefix       []byte
+	payload      io.Reader
+	payload_size int64
+	err          error
+}
+
+func (m *streamedMessage) write(w io.Writer) {
+	if m.prehash {
+		h := sha512.New()
+		m.writeContent(h)
+		mldsa.PreHashSHA512(m.ctx, h.Sum(nil))(w)
+		return
+	}
+	_, _ = w.Write([]byte{0, byte(len(m.ctx))})
+	_, _ = w.Write(m.ctx)
+	m.writeContent(w)
+}
+
+func (m *streamedMessage) writeContent(w io.Writer) {
+	_, _ = w.Write(m.prefix)
+	read, err := io.Copy(w, io.LimitReader(m.payload, m.payload_size))
+	if err != nil {
+		m.err = err
+		return
+	}
+	if read != m.payload_size {
+		m.err = errors.New("Payload is shorter than the payload size")
+		return
+	}
+	var extra [1]byte
+	if n, _ := m.payload.Read(extra[:]); n != 0 {
+		m.err = errors.New("Payload is longer than the payload size")
+	}
+}
+
+// Sign1Stream signs a payload of payload_size bytes read from payload, and
+// returns a COSE_Sign1 with a detached payload. The signature is the same
+// as the one produced by Sign1 over the same payload.
+func Sign1Stream(private_key []byte, header Header, payload io.Reader, payload_size int64, opts ...SignOption) ([]byte, error) {
+	o := newSignOptions(opts)
+	tags, err := tagsForSigning(o)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := signerFromPrivateKey(private_key)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != signer.alg {
+		return nil, errors.New("Header algorithm does not match the key algorithm")
+	}
+	headers, err := headersForSigning(header, o)
+	if err != nil {
+		return nil, err
+	}
+	sign1 := cose.Sign1Message{
+		Headers: headers,
+	}
+	prefix, err := sigStructurePrefix(&sign1, payload_size)
+	if err != nil {
+		return nil, err
+	}
+	var rnd [32]byte
+	if o.hedged {
+		var rand = o.rand
+		if rand == nil {
+			rand = crypto_rand.Reader
+		}
+		rnd, err = mldsa.Randomness(rand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	message := streamedMessage{
+		ctx:          o.ctx,
+		prehash:      IsHashMLDSA(signer.alg),
+		prefix:       prefix,
+		payload:      payload,
+		payload_size: payload_size,
+	}
+	sign1.Signature, err = mldsa.Sign(signer.key, message.write, rnd)
+	if err != nil {
+		return nil, err
+	}
+	if message.err != nil {
+		return nil, message.err
+	}
+	return encodeSign1(&sign1, tags)
+}
+
+// VerifySign1Stream verifies a COSE_Sign1 with a detached payload of
+// payload_size bytes read from payload.
+func VerifySign1Stream(public_key []byte, signature []byte, payload io.Reader, payload_size int64, opts ...VerifyOption) (Sign1Verification, error) {
+	var verified = Sign1Verification{}
+	o := newVerifyOptions(opts)
+	verifier, err := verifierFromPublicKey(public_key)
+	if err != nil {
+		return verified, err
+	}
+	sign1, tags, err := decodeSign1(signature)
+	if err != nil {
+		return verified, err
+	}
+	if sign1.Payload != nil {
+		return verified, errors.New("COSE_Sign1 payload is not detached")
+	}
+	err = checkTags(o, tags)
+	if err != nil {
+		return verified, err
+	}
+	alg, err := sign1.Headers.Protected.Algorithm()
+	if err != nil || alg != verifier.alg {
+		return verified, errors.New("Header algorithm does not match the key algorithm")
+	}
+	ctx, err := contextForVerification(o, sign1.Headers.Protected)
+	if err != nil {
+		return verified, err
+	}
+	prefix, err := sigStructurePrefix(&sign1, payload_size)
+	if err != nil {
+		return verified, err
+	}
+	message := streamedMessage{
+		ctx:          ctx,
+		prehash:      IsHashMLDSA(verifier.alg),
+		prefix:       prefix,
+		payload:      payload,
+		payload_size: payload_size,
+	}
+	valid, err := mldsa.Verify(verifier.key, message.write, sign1.Signature)
+	if err != nil {
+		return verified, err
+	}
+	if message.err != nil {
+		return verified, message.err
+	}
+	if !valid {
+		return verified, errors.New("Signature not from public key")
+	}
+	verified.Header, err = headerForVerification(o, &sign1)
+	if err != nil {
+		return verified, err
+	}
+	verified.Tags = tags
+	return verified, nil
+}
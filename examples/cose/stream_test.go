@@ -0,0 +1,118 @@
+package cose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/veraison/go-cose"
+)
+
+var large_payload = bytes.Repeat(payload, 1<<16)
+
+// TestSign1Stream calls cose.Sign1Stream with a payload reader and confirms
+// the signature is byte identical to cose.Sign1 and verifies with
+// cose.VerifySign1Stream
+func TestSign1Stream(t *testing.T) {
+	for _, alg := range []cose.Algorithm{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		var private_key, _ = GenerateKey(alg, seed[:])
+		var public_key, _ = PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		var header = Header{
+			Alg: key.Alg,
+			Kid: key.Kid,
+		}
+		for _, message := range [][]byte{{}, payload, large_payload} {
+			in_memory, _ := Sign1(private_key, header, message)
+			streamed, err := Sign1Stream(private_key, header, bytes.NewReader(message), int64(len(message)))
+			if err != nil {
+				t.Fatalf("Streaming signature failed: %v", err)
+			}
+			in_memory_sig, _ := SignatureFromSign1(in_memory)
+			streamed_sig, _ := SignatureFromSign1(streamed)
+			if !bytes.Equal(in_memory_sig, streamed_sig) {
+				t.Fatalf("Streamed signature differs from in memory signature")
+			}
+			verified, err := VerifySign1Stream(public_key, streamed, bytes.NewReader(message), int64(len(message)))
+			if err != nil {
+				t.Fatalf("Streaming verification failed: %v", err)
+			}
+			if verified.Header.Alg != alg || !bytes.Equal(verified.Header.Kid, key.Kid) {
+				t.Fatalf("Invalid header")
+			}
+		}
+	}
+}
+
+// TestSign1StreamOptions confirms streaming signatures match in memory
+// signatures with hedged signing and an experimental context
+func TestSign1StreamOptions(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	var header = Header{
+		Alg: key.Alg,
+		Kid: key.Kid,
+	}
+	fixed_rand := bytes.Repeat([]byte{0x42}, 32)
+	in_memory, _ := Sign1(private_key, header, large_payload, Hedged(bytes.NewReader(fixed_rand)), WithExperimentalContext(experimental_ctx))
+	streamed, err := Sign1Stream(private_key, header, bytes.NewReader(large_payload), int64(len(large_payload)), Hedged(bytes.NewReader(fixed_rand)), WithExperimentalContext(experimental_ctx))
+	if err != nil {
+		t.Fatalf("Streaming signature failed: %v", err)
+	}
+	in_memory_sig, _ := SignatureFromSign1(in_memory)
+	streamed_sig, _ := SignatureFromSign1(streamed)
+	if !bytes.Equal(in_memory_sig, streamed_sig) {
+		t.Fatalf("Streamed signature differs from in memory signature")
+	}
+	_, err = VerifySign1Stream(public_key, streamed, bytes.NewReader(large_payload), int64(len(large_payload)), ExpectExperimentalContext(experimental_ctx))
+	if err != nil {
+		t.Fatalf("Streaming verification failed: %v", err)
+	}
+	_, err = VerifySign1Stream(public_key, streamed, bytes.NewReader(large_payload), int64(len(large_payload)))
+	if err == nil {
+		t.Fatalf("Verified with context without opting in")
+	}
+}
+
+// TestVerifySign1StreamRejects confirms cose.VerifySign1Stream rejects
+// modified payloads, wrong payload sizes and attached payloads
+func TestVerifySign1StreamRejects(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	var header = Header{
+		Alg: key.Alg,
+		Kid: key.Kid,
+	}
+	size := int64(len(large_payload))
+	streamed, _ := Sign1Stream(private_key, header, bytes.NewReader(large_payload), size)
+	modified := bytes.Clone(large_payload)
+	modified[len(modified)/2] ^= 1
+	_, err := VerifySign1Stream(public_key, streamed, bytes.NewReader(modified), size)
+	if err == nil {
+		t.Fatalf("Verified a modified payload")
+	}
+	_, err = VerifySign1Stream(public_key, streamed, bytes.NewReader(large_payload), size+1)
+	if err == nil {
+		t.Fatalf("Verified a payload shorter than its size")
+	}
+	_, err = VerifySign1Stream(public_key, streamed, bytes.NewReader(large_payload), size-1)
+	if err == nil {
+		t.Fatalf("Verified a payload longer than its size")
+	}
+	_, err = Sign1Stream(private_key, header, bytes.NewReader(large_payload), size+1)
+	if err == nil {
+		t.Fatalf("Signed a payload shorter than its size")
+	}
+	attached, _ := Sign1(private_key, header, large_payload)
+	_, err = VerifySign1Stream(public_key, attached, bytes.NewReader(large_payload), size)
+	if err == nil {
+		t.Fatalf("Verified a COSE_Sign1 with an attached payload")
+	}
+	other_key, _ := GenerateKey(ML_DSA_44, notary_seed)
+	other_public_key, _ := PublicKeyFromPrivateKey(other_key)
+	_, err = VerifySign1Stream(other_public_key, streamed, bytes.NewReader(large_payload), size)
+	if err == nil {
+		t.Fatalf("Verified with the wrong key")
+	}
+}
@@ -0,0 +1,136 @@
+package cose
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/veraison/go-cose"
+)
+
+const (
+	TAG_COSE_SIGN1 = 18
+	TAG_CWT        = 61
+	TAG_COSE_SIGN  = 98
+	// see: https://www.rfc-editor.org/rfc/rfc8949#section-3.4.6
+	TAG_SELF_DESCRIBED_CBOR = 55799
+)
+
+// TagsFromMessage returns the tags enclosing a CBOR data item, outermost
+// first, together with the untagged content.
+func TagsFromMessage(message []byte) ([]uint64, []byte, error) {
+	var tags []uint64
+	for len(message) > 0 && message[0]>>5 == 6 { // major type 6: tag
+		// the tag head is parsed by hand, because the decoder silently
+		// drops the self-described CBOR tag (55799)
+		var tag uint64
+		var size int
+		switch ai := message[0] & 0x1f; {
+		case ai < 24:
+			tag, size = uint64(ai), 1
+		case ai == 24 && len(message) >= 2:
+			tag, size = uint64(message[1]), 2
+		case ai == 25 && len(message) >= 3:
+			tag, size = uint64(binary.BigEndian.Uint16(message[1:])), 3
+		case ai == 26 && len(message) >= 5:
+			tag, size = uint64(binary.BigEndian.Uint32(message[1:])), 5
+		case ai == 27 && len(message) >= 9:
+			tag, size = binary.BigEndian.Uint64(message[1:]), 9
+		default:
+			return nil, nil, errors.New("Failed to decode cbor tag")
+		}
+		tags = append(tags, tag)
+		message = message[size:]
+	}
+	if len(message) == 0 {
+		return nil, nil, errors.New("Empty cbor message")
+	}
+	return tags, message, nil
+}
+
+// decodeSign1 accepts a COSE_Sign1 that is either untagged, or tagged with
+// COSE_Sign1_Tagged (18), optionally enclosed in the CWT tag (61). Either
+// may be prefixed with the self-described CBOR tag (55799).
+func decodeSign1(message []byte) (cose.Sign1Message, []uint64, error) {
+	var sign1 cose.Sign1Message
+	all_tags, content, err := TagsFromMessage(message)
+	if err != nil {
+		return sign1, nil, err
+	}
+	tags := all_tags
+	if len(tags) > 0 && tags[0] == TAG_SELF_DESCRIBED_CBOR {
+		tags = tags[1:]
+	}
+	if content[0]>>5 != 4 { // major type 4: array
+		return sign1, nil, errors.New("COSE_Sign1 must be a cbor array")
+	}
+	for i, tag := range tags {
+		if tag == TAG_COSE_SIGN1 && i != len(tags)-1 {
+			return sign1, nil, errors.New("COSE_Sign1_Tagged must be the innermost tag")
+		}
+	}
+	if len(tags) > 0 && tags[len(tags)-1] != TAG_COSE_SIGN1 {
+		return sign1, nil, fmt.Errorf("Unexpected tag %d on COSE_Sign1", tags[len(tags)-1])
+	}
+	for _, tag := range tags[:max(len(tags)-1, 0)] {
+		if tag != TAG_CWT {
+			return sign1, nil, fmt.Errorf("Unknown outer tag %d on COSE_Sign1", tag)
+		}
+	}
+	if len(tags) > 2 {
+		return sign1, nil, errors.New("COSE_Sign1 has more than one CWT tag")
+	}
+	err = (*cose.UntaggedSign1Message)(&sign1).UnmarshalCBOR(content)
+	if err != nil {
+		return sign1, nil, err
+	}
+	return sign1, all_tags, nil
+}
+
+// encodeSign1 encodes a COSE_Sign1 enclosed in the given tags, outermost
+// first.
+func encodeSign1(sign1 *cose.Sign1Message, tags []uint64) ([]byte, error) {
+	message, err := (*cose.UntaggedSign1Message)(sign1).MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(tags) - 1; i >= 0; i-- {
+		message, err = cbor.Marshal(cbor.RawTag{
+			Number:  tags[i],
+			Content: message,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return message, nil
+}
+
+func tagsForSigning(o signOptions) ([]uint64, error) {
+	if o.untagged && o.cwt_tag {
+		return nil, errors.New("The CWT tag requires a tagged COSE_Sign1")
+	}
+	if o.untagged {
+		return nil, nil
+	}
+	if o.cwt_tag {
+		return []uint64{TAG_CWT, TAG_COSE_SIGN1}, nil
+	}
+	return []uint64{TAG_COSE_SIGN1}, nil
+}
+
+func checkTags(o verifyOptions, tags []uint64) error {
+	for _, tag := range o.required_tags {
+		if !slices.Contains(tags, tag) {
+			return fmt.Errorf("COSE_Sign1 is missing required tag %d", tag)
+		}
+	}
+	for _, tag := range o.forbidden_tags {
+		if slices.Contains(tags, tag) {
+			return fmt.Errorf("COSE_Sign1 has forbidden tag %d", tag)
+		}
+	}
+	return nil
+}
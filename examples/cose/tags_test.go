@@ -0,0 +1,175 @@
+package cose
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func wrapTag(t *testing.T, tag uint64, content []byte) []byte {
+	tagged, err := cbor.Marshal(cbor.RawTag{Number: tag, Content: content})
+	if err != nil {
+		t.Fatalf("Failed to encode tag %d", tag)
+	}
+	return tagged
+}
+
+// TestSign1Tags calls cose.Sign1 with the tag options and confirms
+// cose.VerifySign1 reports and enforces the resulting tags
+func TestSign1Tags(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	var header = Header{
+		Alg: key.Alg,
+		Kid: key.Kid,
+	}
+
+	tagged, _ := Sign1(private_key, header, payload)
+	if tagged[0] != 0xd2 {
+		t.Fatalf("COSE_Sign1 is not tagged by default")
+	}
+	verified, err := VerifySign1(public_key, tagged, RequireTag(TAG_COSE_SIGN1))
+	if err != nil {
+		t.Fatalf("Verification of tagged COSE_Sign1 failed: %v", err)
+	}
+	if !slices.Equal(verified.Tags, []uint64{TAG_COSE_SIGN1}) {
+		t.Fatalf("Invalid tags %v", verified.Tags)
+	}
+	_, err = VerifySign1(public_key, tagged, ForbidTag(TAG_COSE_SIGN1))
+	if err == nil {
+		t.Fatalf("Verified COSE_Sign1 with forbidden tag")
+	}
+
+	untagged, err := Sign1(private_key, header, payload, Untagged())
+	if err != nil {
+		t.Fatalf("Signing untagged COSE_Sign1 failed: %v", err)
+	}
+	if untagged[0] != 0x84 {
+		t.Fatalf("COSE_Sign1 is not untagged")
+	}
+	verified, err = VerifySign1(public_key, untagged)
+	if err != nil {
+		t.Fatalf("Verification of untagged COSE_Sign1 failed: %v", err)
+	}
+	if len(verified.Tags) != 0 {
+		t.Fatalf("Invalid tags %v", verified.Tags)
+	}
+	_, err = VerifySign1(public_key, untagged, RequireTag(TAG_COSE_SIGN1))
+	if err == nil {
+		t.Fatalf("Verified untagged COSE_Sign1 that requires a tag")
+	}
+
+	cwt, err := Sign1(private_key, header, payload, WithCWTTag())
+	if err != nil {
+		t.Fatalf("Signing CWT tagged COSE_Sign1 failed: %v", err)
+	}
+	verified, err = VerifySign1(public_key, cwt, RequireTag(TAG_CWT), RequireTag(TAG_COSE_SIGN1))
+	if err != nil {
+		t.Fatalf("Verification of CWT tagged COSE_Sign1 failed: %v", err)
+	}
+	if !slices.Equal(verified.Tags, []uint64{TAG_CWT, TAG_COSE_SIGN1}) {
+		t.Fatalf("Invalid tags %v", verified.Tags)
+	}
+	_, err = VerifySign1(public_key, cwt, ForbidTag(TAG_CWT))
+	if err == nil {
+		t.Fatalf("Verified COSE_Sign1 with forbidden CWT tag")
+	}
+	_, err = VerifySign1(public_key, tagged, RequireTag(TAG_CWT))
+	if err == nil {
+		t.Fatalf("Verified COSE_Sign1 without required CWT tag")
+	}
+
+	_, err = Sign1(private_key, header, payload, Untagged(), WithCWTTag())
+	if err == nil {
+		t.Fatalf("Signed CWT tagged COSE_Sign1 without the COSE_Sign1 tag")
+	}
+
+	sig, _ := SignatureFromSign1(untagged)
+	sig_cwt, _ := SignatureFromSign1(cwt)
+	if string(sig) != string(sig_cwt) {
+		t.Fatalf("Tags changed the signature")
+	}
+}
+
+// TestSign1TagStacking confirms cose.VerifySign1 accepts the CWT and
+// self-described CBOR tags around a tagged COSE_Sign1, and rejects any
+// other stacked tags
+func TestSign1TagStacking(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	untagged, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload, Untagged())
+	tagged := wrapTag(t, TAG_COSE_SIGN1, untagged)
+
+	accepted := map[string]struct {
+		message []byte
+		tags    []uint64
+	}{
+		"cwt": {
+			message: wrapTag(t, TAG_CWT, tagged),
+			tags:    []uint64{TAG_CWT, TAG_COSE_SIGN1},
+		},
+		"self described cwt": {
+			message: wrapTag(t, TAG_SELF_DESCRIBED_CBOR, wrapTag(t, TAG_CWT, tagged)),
+			tags:    []uint64{TAG_SELF_DESCRIBED_CBOR, TAG_CWT, TAG_COSE_SIGN1},
+		},
+		"self described": {
+			message: wrapTag(t, TAG_SELF_DESCRIBED_CBOR, tagged),
+			tags:    []uint64{TAG_SELF_DESCRIBED_CBOR, TAG_COSE_SIGN1},
+		},
+	}
+	for name, test := range accepted {
+		verified, err := VerifySign1(public_key, test.message)
+		if err != nil {
+			t.Fatalf("Verification of %s COSE_Sign1 failed: %v", name, err)
+		}
+		if !slices.Equal(verified.Tags, test.tags) {
+			t.Fatalf("Invalid tags for %s, got %v want %v", name, verified.Tags, test.tags)
+		}
+	}
+
+	rejected := map[string][]byte{
+		"double cose sign1 tag":     wrapTag(t, TAG_COSE_SIGN1, tagged),
+		"cwt without cose tag":      wrapTag(t, TAG_CWT, untagged),
+		"cwt inside cose tag":       wrapTag(t, TAG_COSE_SIGN1, wrapTag(t, TAG_CWT, tagged)),
+		"cose sign tag":             wrapTag(t, 98, untagged),
+		"tagged non array":          wrapTag(t, TAG_COSE_SIGN1, []byte{0x40}),
+		"double self described":     wrapTag(t, TAG_SELF_DESCRIBED_CBOR, wrapTag(t, TAG_SELF_DESCRIBED_CBOR, tagged)),
+		"self described inside cwt": wrapTag(t, TAG_CWT, wrapTag(t, TAG_SELF_DESCRIBED_CBOR, tagged)),
+		"double cwt tag":            wrapTag(t, TAG_CWT, wrapTag(t, TAG_CWT, tagged)),
+		"unknown outer tag":         wrapTag(t, 1000, tagged),
+	}
+	for name, message := range rejected {
+		_, err := VerifySign1(public_key, message)
+		if err == nil {
+			t.Fatalf("Verified %s", name)
+		}
+	}
+}
+
+// TestCountersignPreservesTags confirms countersigning keeps the tags of
+// the countersigned message
+func TestCountersignPreservesTags(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	key, _ := DecodeKey(private_key)
+	notary_private_key, _ := GenerateKey(ML_DSA_44, notary_seed)
+	notary_public_key, _ := PublicKeyFromPrivateKey(notary_private_key)
+	for _, opt := range []SignOption{Untagged(), WithCWTTag()} {
+		signature, _ := Sign1(private_key, Header{Alg: key.Alg, Kid: key.Kid}, payload, opt)
+		countersigned, err := Countersign0(notary_private_key, signature)
+		if err != nil {
+			t.Fatalf("Countersigning failed: %v", err)
+		}
+		tags, _, _ := TagsFromMessage(signature)
+		countersigned_tags, _, _ := TagsFromMessage(countersigned)
+		if !slices.Equal(tags, countersigned_tags) {
+			t.Fatalf("Countersigning changed tags from %v to %v", tags, countersigned_tags)
+		}
+		err = VerifyCountersignature0(notary_public_key, countersigned)
+		if err != nil {
+			t.Fatalf("Countersignature verification failed: %v", err)
+		}
+	}
+}
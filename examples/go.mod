@@ -3,12 +3,15 @@ module github.com/cose-wg/draft-ietf-cose-dilithium/example
 go 1.23.1
 
 require (
+	// circl is pinned: internal/mldsa links to the unexported SignTo and
+	// Verify of its ML-DSA internal packages, which can change without
+	// notice. Rerun the internal/mldsa tests before upgrading.
 	github.com/cloudflare/circl v1.4.1-0.20240925100306-16fa7b7b8dc9
 	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/veraison/go-cose v1.3.0
 )
 
 require (
-	github.com/veraison/go-cose v1.3.0 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 )
@@ -0,0 +1,125 @@
+// Package batch runs verifications on a bounded pool of workers, and
+// returns their results in the order of the inputs.
+package batch
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+type Result[T any] struct {
+	Index int
+	Value T
+	Err   error
+}
+
+// Run applies f to the inputs on workers goroutines, or GOMAXPROCS when
+// workers is not positive. Results are sent in the order of the inputs,
+// and at most 4 * workers results are held waiting for an earlier one.
+//
+// Once ctx is done, no more inputs are read, and inputs that were read but
+// not yet started fail with the error of ctx. The result channel is closed
+// after the last result, callers must drain it.
+func Run[M any, T any](ctx context.Context, inputs <-chan M, workers int, f func(M) (T, error)) <-chan Result[T] {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	type job struct {
+		index int
+		input M
+	}
+	jobs := make(chan job)
+	done := make(chan Result[T])
+	out := make(chan Result[T])
+	in_flight := make(chan struct{}, 4*workers)
+
+	go func() {
+		defer close(jobs)
+		for index := 0; ; index++ {
+			select {
+			case <-ctx.Done():
+				return
+			case in_flight <- struct{}{}:
+			}
+			var input M
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case input, ok = <-inputs:
+			}
+			if !ok {
+				return
+			}
+			jobs <- job{index: index, input: input}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result := Result[T]{Index: j.index}
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+				} else {
+					result.Value, result.Err = f(j.input)
+				}
+				done <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	go func() {
+		defer close(out)
+		pending := map[int]Result[T]{}
+		next := 0
+		for result := range done {
+			pending[result.Index] = result
+			for {
+				result, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				out <- result
+				<-in_flight
+				next++
+			}
+		}
+	}()
+	return out
+}
+
+// RunSlice applies f to a slice of inputs with Run. Inputs that were not
+// verified before ctx was done fail with the error of ctx.
+func RunSlice[M any, T any](ctx context.Context, inputs []M, workers int, f func(M) (T, error)) []Result[T] {
+	feed := make(chan M)
+	go func() {
+		defer close(feed)
+		for _, input := range inputs {
+			select {
+			case <-ctx.Done():
+				return
+			case feed <- input:
+			}
+		}
+	}()
+	results := make([]Result[T], len(inputs))
+	received := 0
+	for result := range Run(ctx, feed, workers, f) {
+		results[result.Index] = result
+		received++
+	}
+	for i := received; i < len(results); i++ {
+		results[i] = Result[T]{Index: i, Err: ctx.Err()}
+	}
+	return results
+}
@@ -0,0 +1,90 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRunOrder confirms results are returned in the order of the inputs,
+// with no more than the requested number of concurrent calls
+func TestRunOrder(t *testing.T) {
+	inputs := make([]int, 200)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	var running, peak atomic.Int32
+	results := RunSlice(context.Background(), inputs, 4, func(i int) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration((i*7)%5) * 100 * time.Microsecond)
+		if i%10 == 0 {
+			return 0, errors.New("multiple of 10")
+		}
+		return i * i, nil
+	})
+	for i, result := range results {
+		if result.Index != i {
+			t.Fatalf("Result %d has index %d", i, result.Index)
+		}
+		if (i%10 == 0) != (result.Err != nil) || (result.Err == nil && result.Value != i*i) {
+			t.Fatalf("Invalid result %d", i)
+		}
+	}
+	if peak.Load() > 4 {
+		t.Fatalf("Ran %d concurrent calls, want at most 4", peak.Load())
+	}
+}
+
+// TestRunCancel confirms cancelling the context stops reading inputs, and
+// that the inputs that were not verified fail with the context error
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inputs := make([]int, 1000)
+	var calls atomic.Int32
+	results := RunSlice(ctx, inputs, 2, func(i int) (int, error) {
+		if calls.Add(1) == 10 {
+			cancel()
+		}
+		return i, nil
+	})
+	if calls.Load() >= 1000 {
+		t.Fatalf("Cancelling did not stop the batch")
+	}
+	if !errors.Is(results[len(results)-1].Err, context.Canceled) {
+		t.Fatalf("Unverified input does not fail with the context error")
+	}
+	for i, result := range results {
+		if result.Index != i {
+			t.Fatalf("Result %d has index %d", i, result.Index)
+		}
+	}
+}
+
+// TestRunChannel confirms Run closes its results after the inputs close
+func TestRunChannel(t *testing.T) {
+	inputs := make(chan string)
+	go func() {
+		defer close(inputs)
+		for _, input := range []string{"a", "bb", "ccc"} {
+			inputs <- input
+		}
+	}()
+	var lengths []int
+	for result := range Run(context.Background(), inputs, 0, func(s string) (int, error) {
+		return len(s), nil
+	}) {
+		lengths = append(lengths, result.Value)
+	}
+	if len(lengths) != 3 || lengths[0] != 1 || lengths[1] != 2 || lengths[2] != 3 {
+		t.Fatalf("Invalid results %v", lengths)
+	}
+}
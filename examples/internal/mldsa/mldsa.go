@@ -0,0 +1,108 @@
+// Package mldsa exposes the ML-DSA signing and verification internals of
+// circl, which take the per-signature randomness and the formatted message
+// from the caller (FIPS 204, Algorithm 7 and Algorithm 8).
+//
+// The public circl API only signs with zero randomness or randomness read
+// from crypto/rand, and only accepts the message as a single byte slice.
+package mldsa
+
+import (
+	"errors"
+	"io"
+	_ "unsafe" // for go:linkname
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+)
+
+//go:linkname signTo44 github.com/cloudflare/circl/sign/mldsa/mldsa44/internal.SignTo
+func signTo44(sk *mldsa44.PrivateKey, msg func(io.Writer), rnd [32]byte, signature []byte)
+
+//go:linkname signTo65 github.com/cloudflare/circl/sign/mldsa/mldsa65/internal.SignTo
+func signTo65(sk *mldsa65.PrivateKey, msg func(io.Writer), rnd [32]byte, signature []byte)
+
+//go:linkname signTo87 github.com/cloudflare/circl/sign/mldsa/mldsa87/internal.SignTo
+func signTo87(sk *mldsa87.PrivateKey, msg func(io.Writer), rnd [32]byte, signature []byte)
+
+//go:linkname verify44 github.com/cloudflare/circl/sign/mldsa/mldsa44/internal.Verify
+func verify44(pk *mldsa44.PublicKey, msg func(io.Writer), signature []byte) bool
+
+//go:linkname verify65 github.com/cloudflare/circl/sign/mldsa/mldsa65/internal.Verify
+func verify65(pk *mldsa65.PublicKey, msg func(io.Writer), signature []byte) bool
+
+//go:linkname verify87 github.com/cloudflare/circl/sign/mldsa/mldsa87/internal.Verify
+func verify87(pk *mldsa87.PublicKey, msg func(io.Writer), signature []byte) bool
+
+var ErrKeyType = errors.New("mldsa: unsupported key type")
+
+// Sign signs the formatted message M' written by msg, using rnd as the
+// per-signature randomness. An all zero rnd is the deterministic variant.
+func Sign(sk sign.PrivateKey, msg func(io.Writer), rnd [32]byte) ([]byte, error) {
+	switch sk := sk.(type) {
+	case *mldsa44.PrivateKey:
+		signature := make([]byte, mldsa44.SignatureSize)
+		signTo44(sk, msg, rnd, signature)
+		return signature, nil
+	case *mldsa65.PrivateKey:
+		signature := make([]byte, mldsa65.SignatureSize)
+		signTo65(sk, msg, rnd, signature)
+		return signature, nil
+	case *mldsa87.PrivateKey:
+		signature := make([]byte, mldsa87.SignatureSize)
+		signTo87(sk, msg, rnd, signature)
+		return signature, nil
+	default:
+		return nil, ErrKeyType
+	}
+}
+
+// Verify checks signature over the formatted message M' written by msg.
+func Verify(pk sign.PublicKey, msg func(io.Writer), signature []byte) (bool, error) {
+	switch pk := pk.(type) {
+	case *mldsa44.PublicKey:
+		return verify44(pk, msg, signature), nil
+	case *mldsa65.PublicKey:
+		return verify65(pk, msg, signature), nil
+	case *mldsa87.PublicKey:
+		return verify87(pk, msg, signature), nil
+	default:
+		return false, ErrKeyType
+	}
+}
+
+// Pure returns the writer of the formatted message M' for pure ML-DSA
+// (FIPS 204, Algorithm 2).
+func Pure(ctx []byte, message []byte) func(io.Writer) {
+	return func(w io.Writer) {
+		_, _ = w.Write([]byte{0, byte(len(ctx))})
+		_, _ = w.Write(ctx)
+		_, _ = w.Write(message)
+	}
+}
+
+// OID of SHA-512 in DER, as used in the HashML-DSA formatted message.
+var oidSHA512 = []byte{0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03}
+
+// PreHashSHA512 returns the writer of the formatted message M' for
+// HashML-DSA with SHA-512 (FIPS 204, Algorithm 4), given the SHA-512 digest
+// of the message.
+func PreHashSHA512(ctx []byte, digest []byte) func(io.Writer) {
+	return func(w io.Writer) {
+		_, _ = w.Write([]byte{1, byte(len(ctx))})
+		_, _ = w.Write(ctx)
+		_, _ = w.Write(oidSHA512)
+		_, _ = w.Write(digest)
+	}
+}
+
+// Randomness reads the per-signature randomness for hedged signing.
+func Randomness(rand io.Reader) ([32]byte, error) {
+	var rnd [32]byte
+	_, err := io.ReadFull(rand, rnd[:])
+	if err != nil {
+		return rnd, errors.New("mldsa: failed to read signing randomness")
+	}
+	return rnd, nil
+}
@@ -0,0 +1,125 @@
+package mldsa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"runtime/debug"
+	"testing"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/schemes"
+)
+
+// TestSignMatchesCircl confirms signing with zero randomness matches the
+// deterministic circl signature, and hedged signatures verify with circl
+func TestSignMatchesCircl(t *testing.T) {
+	var seed [32]byte // zero seed
+	message := []byte("It’s a dangerous business, Frodo, going out your door.")
+	for _, name := range []string{"ML-DSA-44", "ML-DSA-65", "ML-DSA-87"} {
+		suite := schemes.ByName(name)
+		pub, priv := suite.DeriveKey(seed[:])
+		deterministic, err := Sign(priv, Pure(nil, message), [32]byte{})
+		if err != nil {
+			t.Fatalf("Signing %s failed: %v", name, err)
+		}
+		if !bytes.Equal(deterministic, suite.Sign(priv, message, nil)) {
+			t.Fatalf("Deterministic %s signature does not match circl", name)
+		}
+		with_context, _ := Sign(priv, Pure([]byte("ctx"), message), [32]byte{})
+		if !bytes.Equal(with_context, suite.Sign(priv, message, &sign.SignatureOpts{Context: "ctx"})) {
+			t.Fatalf("Deterministic %s signature with a context does not match circl", name)
+		}
+		rnd, _ := Randomness(bytes.NewReader(bytes.Repeat([]byte{0x42}, 32)))
+		hedged, _ := Sign(priv, Pure(nil, message), rnd)
+		if bytes.Equal(deterministic, hedged) {
+			t.Fatalf("Hedged %s signature is deterministic", name)
+		}
+		if !suite.Verify(pub, message, hedged, nil) {
+			t.Fatalf("Hedged %s signature does not verify with circl", name)
+		}
+		valid, _ := Verify(pub, Pure(nil, message), hedged)
+		if !valid {
+			t.Fatalf("Hedged %s signature does not verify", name)
+		}
+		valid, _ = Verify(pub, Pure([]byte("ctx"), message), hedged)
+		if valid {
+			t.Fatalf("%s signature verified with a different context", name)
+		}
+	}
+	_, err := Randomness(bytes.NewReader(make([]byte, 16)))
+	if err == nil {
+		t.Fatalf("Read randomness from a short reader")
+	}
+}
+
+// TestCirclVersion confirms the circl version is the one whose internal
+// SignTo and Verify are linked, see go.mod
+func TestCirclVersion(t *testing.T) {
+	const pinned = "v1.4.1-0.20240925100306-16fa7b7b8dc9"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("No build information")
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/cloudflare/circl" {
+			if dep.Version != pinned || dep.Replace != nil {
+				t.Fatalf("circl %s is not the pinned %s, check the go:linkname signatures", dep.Version, pinned)
+			}
+			return
+		}
+	}
+	t.Fatalf("circl is not a dependency")
+}
+
+// TestPreHashSHA512 confirms HashML-DSA signatures do not verify as pure
+// ML-DSA signatures over the same message or digest
+func TestPreHashSHA512(t *testing.T) {
+	var seed [32]byte // zero seed
+	message := []byte("It’s a dangerous business, Frodo, going out your door.")
+	digest := sha512.Sum512(message)
+	suite := schemes.ByName("ML-DSA-87")
+	pub, priv := suite.DeriveKey(seed[:])
+	signature, _ := Sign(priv, PreHashSHA512(nil, digest[:]), [32]byte{})
+	valid, _ := Verify(pub, PreHashSHA512(nil, digest[:]), signature)
+	if !valid {
+		t.Fatalf("HashML-DSA signature does not verify")
+	}
+	if suite.Verify(pub, message, signature, nil) || suite.Verify(pub, digest[:], signature, nil) {
+		t.Fatalf("HashML-DSA signature verified as pure ML-DSA")
+	}
+}
+
+// TestPreHashSHA512KnownAnswer confirms deterministic HashML-DSA signatures
+// with SHA-512 match known answers. The expected SHA-256 digests of the
+// signatures were computed with the crypto/mldsa package of Go 1.27, an
+// independent implementation, signing the external mu of the HashML-DSA
+// formatted message (FIPS 204, Algorithm 4).
+func TestPreHashSHA512KnownAnswer(t *testing.T) {
+	var seed [32]byte // zero seed
+	message := []byte("It’s a dangerous business, Frodo, going out your door.")
+	digest := sha512.Sum512(message)
+	for _, test := range []struct {
+		name string
+		ctx  string
+		sha  string
+	}{
+		{"ML-DSA-44", "", "d3934e7331b9e8d698a2125cf522b63c52bdf599c1f8cf72b8718d3abf42b59e"},
+		{"ML-DSA-44", "ctx", "e1675614154dec1d4a3f9df986ceef0862a53de889f18025c3f6f397378e6800"},
+		{"ML-DSA-65", "", "474785b9ee392840a586dc325e8e4d431e32099d177dbc960bac1d4576d45934"},
+		{"ML-DSA-65", "ctx", "b16adccf41112cfb5dc4dba68e86d2be05f65d89ec5fefc5ca71eca4afce508c"},
+		{"ML-DSA-87", "", "32e206463d963408b1c76807cbdb4db55facccec867dcae2c3b73799bccd7512"},
+		{"ML-DSA-87", "ctx", "73a5379dde2d110de58cfe0f2a7131b43faa03e0416eb88c0ebfd6503d5a7cff"},
+	} {
+		_, priv := schemes.ByName(test.name).DeriveKey(seed[:])
+		signature, err := Sign(priv, PreHashSHA512([]byte(test.ctx), digest[:]), [32]byte{})
+		if err != nil {
+			t.Fatalf("Signing HashML-DSA %s failed: %v", test.name, err)
+		}
+		sum := sha256.Sum256(signature)
+		if hex.EncodeToString(sum[:]) != test.sha {
+			t.Fatalf("HashML-DSA %s signature with context %q does not match the known answer", test.name, test.ctx)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package jose
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/batch"
+)
+
+// publicKeyCache keeps parsed public keys, so that a batch does not parse
+// the same public key for every JWS. A nil cache parses every time.
+type publicKeyCache struct {
+	mu   sync.Mutex
+	keys map[string]*jwkPublicKey
+}
+
+func (c *publicKeyCache) publicKey(public_key string) (*jwkPublicKey, error) {
+	if c != nil {
+		c.mu.Lock()
+		key, exists := c.keys[public_key]
+		c.mu.Unlock()
+		if exists {
+			return key, nil
+		}
+	}
+	key, err := parsePublicKey(public_key)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		c.mu.Lock()
+		if c.keys == nil {
+			c.keys = map[string]*jwkPublicKey{}
+		}
+		c.keys[public_key] = key
+		c.mu.Unlock()
+	}
+	return key, nil
+}
+
+type JWSBatchResult struct {
+	Index        int
+	Verification JWSVerification
+	Err          error
+}
+
+func batchVerifier(resolver KeyResolver, opts []VerifyOption) func(string) (JWSVerification, error) {
+	o := newVerifyOptions(opts)
+	cache := &publicKeyCache{}
+	return func(jws string) (JWSVerification, error) {
+		return compactVerifyWithResolver(resolver, cache, jws, o)
+	}
+}
+
+// CompactVerifyBatch verifies compact JWS with keys from the resolver on
+// workers goroutines, or GOMAXPROCS when workers is not positive. Results
+// are in the order of jws. JWS that were not verified before ctx was done
+// fail with the error of ctx.
+func CompactVerifyBatch(ctx context.Context, resolver KeyResolver, jws []string, workers int, opts ...VerifyOption) []JWSBatchResult {
+	results := batch.RunSlice(ctx, jws, workers, batchVerifier(resolver, opts))
+	batch_results := make([]JWSBatchResult, len(results))
+	for i, result := range results {
+		batch_results[i] = JWSBatchResult{
+			Index:        result.Index,
+			Verification: result.Value,
+			Err:          result.Err,
+		}
+	}
+	return batch_results
+}
+
+// CompactVerifyChannel verifies the compact JWS received from a channel
+// like CompactVerifyBatch, sending results in the order they were
+// received. The result channel is closed once jws is closed or ctx is
+// done, and callers must drain it.
+func CompactVerifyChannel(ctx context.Context, resolver KeyResolver, jws <-chan string, workers int, opts ...VerifyOption) <-chan JWSBatchResult {
+	results := batch.Run(ctx, jws, workers, batchVerifier(resolver, opts))
+	batch_results := make(chan JWSBatchResult)
+	go func() {
+		defer close(batch_results)
+		for result := range results {
+			batch_results <- JWSBatchResult{
+				Index:        result.Index,
+				Verification: result.Value,
+				Err:          result.Err,
+			}
+		}
+	}()
+	return batch_results
+}
@@ -0,0 +1,83 @@
+package jose
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestCompactVerifyBatch calls jose.CompactVerifyBatch on JWS from several
+// keys and confirms each result is in order and correct
+func TestCompactVerifyBatch(t *testing.T) {
+	var public_keys []string
+	var jws []string
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		public_keys = append(public_keys, public_key)
+		for i := 0; i < 10; i++ {
+			signed, _ := CompactSign(private_key, []byte{byte(i)})
+			jws = append(jws, signed)
+		}
+	}
+	other_seed := [32]byte{1}
+	other_private_key, _ := GenerateKey(ML_DSA_44, other_seed[:])
+	unknown, _ := CompactSign(other_private_key, payload)
+	jws = append(jws, unknown, "not a jws")
+
+	results := CompactVerifyBatch(context.Background(), NewStaticKeyResolver(public_keys...), jws, 4)
+	if len(results) != len(jws) {
+		t.Fatalf("Invalid result count (%d), want %d", len(results), len(jws))
+	}
+	for i, result := range results[:30] {
+		if result.Index != i || result.Err != nil {
+			t.Fatalf("Verifying JWS %d failed: %v", i, result.Err)
+		}
+		if result.Verification.Payload[0] != byte(i%10) {
+			t.Fatalf("Result %d is out of order", i)
+		}
+	}
+	if results[30].Err == nil {
+		t.Fatalf("Verified a JWS from an unknown key")
+	}
+	if results[31].Err == nil {
+		t.Fatalf("Verified a malformed JWS")
+	}
+}
+
+// TestCompactVerifyChannel calls jose.CompactVerifyChannel and confirms
+// results arrive in order, and cancelling stops the verification
+func TestCompactVerifyChannel(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	resolver := NewStaticKeyResolver(public_key)
+
+	jws := make(chan string)
+	go func() {
+		defer close(jws)
+		for i := 0; i < 20; i++ {
+			signed, _ := CompactSign(private_key, []byte{byte(i)})
+			jws <- signed
+		}
+	}()
+	count := 0
+	for result := range CompactVerifyChannel(context.Background(), resolver, jws, 3) {
+		if result.Err != nil || result.Index != count || result.Verification.Payload[0] != byte(count) {
+			t.Fatalf("Invalid result %d: %v", count, result.Err)
+		}
+		count++
+	}
+	if count != 20 {
+		t.Fatalf("Invalid result count (%d), want 20", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	signed, _ := CompactSign(private_key, payload)
+	results := CompactVerifyBatch(ctx, resolver, []string{signed, signed}, 2)
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Fatalf("Verified a JWS after cancelling")
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package jose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// required_jwk_members are the members of a JWK hashed in its thumbprint.
+// see: https://datatracker.ietf.org/doc/html/rfc7638#section-3.2
+var required_jwk_members = map[string][]string{
+	"AKP": {"alg", "kty", "pub"},
+	"EC":  {"crv", "kty", "x", "y"},
+	"OKP": {"crv", "kty", "x"},
+	"RSA": {"e", "kty", "n"},
+	"oct": {"k", "kty"},
+}
+
+// private_jwk_members are removed when a public key is exported, key types
+// without them have no public key.
+var private_jwk_members = map[string][]string{
+	"AKP": {"priv"},
+	"EC":  {"d"},
+	"OKP": {"d"},
+	"RSA": {"d", "p", "q", "dp", "dq", "qi", "oth"},
+}
+
+// decodeJWK decodes a JWK with members of any JSON type, and checks the
+// required members of its key type are non-empty strings.
+func decodeJWK(jwk string) (map[string]any, string, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(jwk)))
+	decoder.UseNumber()
+	var key map[string]any
+	err := decoder.Decode(&key)
+	if err != nil || key == nil || decoder.More() {
+		return nil, "", errors.New("Failed to parse JSON")
+	}
+	kty, _ := key["kty"].(string)
+	required, known := required_jwk_members[kty]
+	if !known {
+		return nil, "", errors.New(`Unknown JWK key type (kty)`)
+	}
+	for _, name := range required {
+		if value, _ := key[name].(string); value == "" {
+			return nil, "", errors.New("JWK is missing a required member: " + name)
+		}
+	}
+	return key, kty, nil
+}
+
+// canonicalJSON encodes a decoded JSON value without whitespace, with
+// object members sorted by code point and strings escaped only where JSON
+// requires it.
+// see: https://datatracker.ietf.org/doc/html/rfc7638#section-3.3
+func canonicalJSON(value any) []byte {
+	return appendCanonicalJSON(nil, value)
+}
+
+func appendCanonicalJSON(encoded []byte, value any) []byte {
+	switch value := value.(type) {
+	case map[string]any:
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		encoded = append(encoded, '{')
+		for i, name := range names {
+			if i > 0 {
+				encoded = append(encoded, ',')
+			}
+			encoded = appendCanonicalString(encoded, name)
+			encoded = append(encoded, ':')
+			encoded = appendCanonicalJSON(encoded, value[name])
+		}
+		return append(encoded, '}')
+	case []any:
+		encoded = append(encoded, '[')
+		for i, item := range value {
+			if i > 0 {
+				encoded = append(encoded, ',')
+			}
+			encoded = appendCanonicalJSON(encoded, item)
+		}
+		return append(encoded, ']')
+	case string:
+		return appendCanonicalString(encoded, value)
+	case json.Number:
+		return append(encoded, value...)
+	case bool:
+		if value {
+			return append(encoded, "true"...)
+		}
+		return append(encoded, "false"...)
+	default:
+		return append(encoded, "null"...)
+	}
+}
+
+func appendCanonicalString(encoded []byte, value string) []byte {
+	encoded = append(encoded, '"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			encoded = append(encoded, '\\', byte(r))
+		case r == '\b':
+			encoded = append(encoded, `\b`...)
+		case r == '\f':
+			encoded = append(encoded, `\f`...)
+		case r == '\n':
+			encoded = append(encoded, `\n`...)
+		case r == '\r':
+			encoded = append(encoded, `\r`...)
+		case r == '\t':
+			encoded = append(encoded, `\t`...)
+		case r < 0x20:
+			encoded = fmt.Appendf(encoded, `\u%04x`, r)
+		default:
+			encoded = utf8.AppendRune(encoded, r)
+		}
+	}
+	return append(encoded, '"')
+}
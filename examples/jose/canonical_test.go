@@ -0,0 +1,105 @@
+package jose
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestCalculateJwkThumbprintKeyTypes calls jose.CalculateJwkThumbprint with
+// the examples of RFC 7638 and RFC 8037 and confirms only the required
+// members are hashed
+func TestCalculateJwkThumbprintKeyTypes(t *testing.T) {
+	for jwk, expected := range map[string]string{
+		// see: https://datatracker.ietf.org/doc/html/rfc7638#section-3.1
+		`{"kty":"RSA","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw","e":"AQAB","alg":"RS256","kid":"2011-04-29"}`: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		// see: https://datatracker.ietf.org/doc/html/rfc8037#appendix-A.3
+		`{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`:                                                                                 "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		`{"kty":"EC","crv":"P-256","x":"zQwCN0Q1A2OF-vzRFYMDTThEjkSl3o6vSonhDQwHHz4","y":"ahiGLX7rLYv4DIlKk017zC-zqgzexrxoVuQvaJuObzA","key_ops":["verify"],"use":"sig"}`: "sF8ijcZ3yIRTT6M9vtM_jMouZZKTtlkCM5BwbK75mck",
+	} {
+		thumbprint, err := CalculateJwkThumbprint(jwk)
+		if err != nil || thumbprint != expected {
+			t.Fatalf("Incorrect JWK thumbprint (%s), want %s: %v", thumbprint, expected, err)
+		}
+	}
+	for _, malformed := range []string{
+		`{"kty":"AKP","alg":"ML-DSA-44"}`,
+		`{"kty":"AKP","alg":"ML-DSA-44","pub":""}`,
+		`{"kty":"AKP","alg":"ML-DSA-44","pub":1}`,
+		`{"kty":"EC","crv":"P-256","x":"zQwCN0Q1A2OF-vzRFYMDTThEjkSl3o6vSonhDQwHHz4"}`,
+		`{"kty":"XYZ","k":"AA"}`,
+		`{"alg":"ML-DSA-44","pub":"AA"}`,
+		`[]`,
+	} {
+		_, err := CalculateJwkThumbprint(malformed)
+		if err == nil {
+			t.Fatalf("Calculated the thumbprint of malformed JWK %s", malformed)
+		}
+	}
+}
+
+// TestPublicKeyFromPrivateKeyMembers calls jose.PublicKeyFromPrivateKey and
+// confirms every member that is not private is kept, and keys without a
+// public key or required members are rejected
+func TestPublicKeyFromPrivateKeyMembers(t *testing.T) {
+	public_key, err := PublicKeyFromPrivateKey(`{"kty":"AKP","alg":"ML-DSA-44","pub":"AA","priv":"AQ","kid":"a\"b<c>é\n","use":"sig","key_ops":["verify"],"exp":1.5e3,"x-ext":null}`)
+	if err != nil {
+		t.Fatalf("Exporting public key failed: %v", err)
+	}
+	if public_key != `{"alg":"ML-DSA-44","exp":1.5e3,"key_ops":["verify"],"kid":"a\"b<c>é\n","kty":"AKP","pub":"AA","use":"sig","x-ext":null}` {
+		t.Fatalf("Invalid public key %s", public_key)
+	}
+	public_key, _ = PublicKeyFromPrivateKey(`{"kty":"EC","crv":"P-256","x":"AA","y":"AQ","d":"Ag"}`)
+	if public_key != `{"crv":"P-256","kty":"EC","x":"AA","y":"AQ"}` {
+		t.Fatalf("Invalid public key %s", public_key)
+	}
+	for _, malformed := range []string{
+		`{"kty":"AKP","alg":"ML-DSA-44","priv":"AQ"}`,
+		`{"kty":"oct","k":"AA"}`,
+		`{"kty":"AKP","alg":"ML-DSA-44","pub":"AA"} {}`,
+	} {
+		_, err = PublicKeyFromPrivateKey(malformed)
+		if err == nil {
+			t.Fatalf("Exported a public key from %s", malformed)
+		}
+	}
+}
+
+// TestCanonicalJSON confirms strings are escaped only where JSON requires,
+// unlike encoding/json which also escapes U+2028
+func TestCanonicalJSON(t *testing.T) {
+	encoded := canonicalJSON(map[string]any{"b": "\x01\t\\/\u2028", "a": []any{true, false, nil}})
+	if string(encoded) != `{"a":[true,false,null],"b":"\u0001\t\\/`+"\u2028"+`"}` {
+		t.Fatalf("Invalid canonical JSON %s", encoded)
+	}
+}
+
+// TestKeysWithKeyOps confirms keys with members that are not strings, such
+// as key_ops, sign, verify and build a suite like any other key
+func TestKeysWithKeyOps(t *testing.T) {
+	generated, _ := GenerateKey(ML_DSA_44, seed[:])
+	private_key := strings.Replace(generated, "{", `{"key_ops":["sign"],"exp":1.5e3,`, 1)
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	jws, err := CompactSign(private_key, payload)
+	if err != nil {
+		t.Fatalf("Signing with key_ops failed: %v", err)
+	}
+	_, err = CompactVerify(public_key, jws)
+	if err != nil {
+		t.Fatalf("Verifying with key_ops failed: %v", err)
+	}
+	streamed, err := CompactSignStream(private_key, bytes.NewReader(payload))
+	if err != nil || strings.Split(streamed, ".")[2] != strings.Split(jws, ".")[2] {
+		t.Fatalf("Streaming signature with key_ops failed: %v", err)
+	}
+	for _, jwk := range []string{private_key, public_key} {
+		suite, pub, _, err := SuiteFromJWK(jwk)
+		if err != nil || suite == nil || pub == nil {
+			t.Fatalf("Suite from JWK with key_ops failed: %v", err)
+		}
+	}
+	_, err = CompactSign(strings.Replace(generated, `"kid":"`, `"kid":1,"x-kid":"`, 1), payload)
+	if err == nil {
+		t.Fatalf("Signed with a kid that is not a string")
+	}
+}
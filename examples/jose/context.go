@@ -0,0 +1,37 @@
+package jose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+)
+
+// EXPERIMENTAL: the draft requires the ML-DSA context string to be empty.
+// A non-empty context string is carried base64url encoded in this private
+// header parameter, so that verifiers can detect it instead of failing to
+// verify.
+const HEADER_EXPERIMENTAL_CONTEXT = "x-ml-dsa-ctx"
+
+func checkContext(ctx []byte) error {
+	if len(ctx) > 255 {
+		return errors.New("ML-DSA context string is longer than 255 bytes")
+	}
+	return nil
+}
+
+func contextForVerification(o verifyOptions, header JWSHeader) ([]byte, error) {
+	if !header.Has(HEADER_EXPERIMENTAL_CONTEXT) {
+		if len(o.ctx) != 0 {
+			return nil, errors.New("JWS is missing the experimental context header")
+		}
+		return nil, nil
+	}
+	if len(o.ctx) == 0 {
+		return nil, errors.New("Experimental context header requires ExpectExperimentalContext")
+	}
+	ctx, err := base64.RawURLEncoding.DecodeString(header.Ctx)
+	if err != nil || !bytes.Equal(ctx, o.ctx) {
+		return nil, errors.New("Experimental context header does not match the expected context")
+	}
+	return ctx, nil
+}
@@ -0,0 +1,73 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+type JOSEContextTestVector struct {
+	Ctx string `json:"experimental_ctx"`
+	JOSETestVector
+}
+
+var experimental_ctx = []byte("draft-ietf-cose-dilithium experimental context")
+
+// TestSignExperimentalContext calls jose.CompactSign with an experimental
+// context string and confirms the result only verifies with the same
+// context
+func TestSignExperimentalContext(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		var private_key, _ = GenerateKey(alg, seed[:])
+		var key, _ = DecodeKey(private_key)
+		var public_key, _ = PublicKeyFromPrivateKey(private_key)
+		jws, err := CompactSign(private_key, payload, WithExperimentalContext(experimental_ctx))
+		if err != nil {
+			t.Fatalf("Signing %s with context failed: %v", alg, err)
+		}
+		verified, verify_error := CompactVerify(public_key, jws, ExpectExperimentalContext(experimental_ctx))
+		if verify_error != nil {
+			t.Fatalf("Verification %s with context failed: %v", alg, verify_error)
+		}
+		if verified.Header.Ctx != base64.RawURLEncoding.EncodeToString(experimental_ctx) {
+			t.Fatalf("Invalid experimental context header")
+		}
+		_, verify_error = CompactVerify(public_key, jws)
+		if verify_error == nil {
+			t.Fatalf("Verified %s with context without opting in", alg)
+		}
+		_, verify_error = CompactVerify(public_key, jws, ExpectExperimentalContext([]byte("other context")))
+		if verify_error == nil {
+			t.Fatalf("Verified %s with a different context", alg)
+		}
+		plain, _ := CompactSign(private_key, payload)
+		_, verify_error = CompactVerify(public_key, plain, ExpectExperimentalContext(experimental_ctx))
+		if verify_error == nil {
+			t.Fatalf("Verified %s without context header while expecting a context", alg)
+		}
+
+		tbs := ToBeSignedFromJWS(jws)
+		sig, _ := SignatureFromJWS(jws)
+		pub, _ := base64.RawURLEncoding.DecodeString(key.Pub)
+		examples, _ := json.MarshalIndent(JOSEContextTestVector{
+			Ctx: hex.EncodeToString(experimental_ctx),
+			JOSETestVector: JOSETestVector{
+				Priv:   hex.EncodeToString(seed[:]),
+				Jwk:    key,
+				Jws:    jws,
+				RawTbs: hex.EncodeToString(tbs),
+				RawSig: hex.EncodeToString(sig),
+				RawPub: hex.EncodeToString(pub),
+			},
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".ctx.jose.json", examples, 0644)
+	}
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	_, err := CompactSign(private_key, payload, WithExperimentalContext(make([]byte, 256)))
+	if err == nil {
+		t.Fatalf("Signed with a context longer than 255 bytes")
+	}
+}
@@ -0,0 +1,395 @@
+package jose
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc9449
+const (
+	DPOP_JWT_TYPE     = "dpop+jwt"
+	DPOP_HEADER       = "DPoP"
+	DPOP_NONCE_HEADER = "DPoP-Nonce"
+	DPOP_SCHEME       = "DPoP"
+	DPOP_CLAIM_HTM    = "htm"
+	DPOP_CLAIM_HTU    = "htu"
+	DPOP_CLAIM_ATH    = "ath"
+	DPOP_CLAIM_NONCE  = "nonce"
+	// DPOP_DEFAULT_MAX_AGE is how old a proof may be
+	DPOP_DEFAULT_MAX_AGE = 5 * time.Minute
+	// DPOP_DEFAULT_REPLAY_CACHE_SIZE is how many recent proofs are
+	// remembered, proofs are rejected when that many are still recent
+	DPOP_DEFAULT_REPLAY_CACHE_SIZE = 1 << 16
+)
+
+// ErrUseDPoPNonce is returned when a proof is missing the current server
+// nonce, the client should retry with the nonce in the DPoP-Nonce header.
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-9
+var ErrUseDPoPNonce = errors.New("DPoP proof requires the server nonce")
+
+// DPoPProofClaims are the request a DPoP proof is bound to.
+type DPoPProofClaims struct {
+	Method string
+	URI    string
+	// AccessToken is hashed into ath when the proof accompanies it
+	AccessToken string
+	// Nonce is the last DPoP-Nonce from the server
+	Nonce string
+	// IssuedAt defaults to time.Now
+	IssuedAt time.Time
+	// JWTID defaults to 128 random bits
+	JWTID string
+}
+
+// accessTokenHash is the ath of an access token.
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-4.2
+func accessTokenHash(access_token string) string {
+	digest := sha256.Sum256([]byte(access_token))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// normalizeHTU removes the query and fragment of a URI, and normalizes
+// its scheme, host, port and empty path.
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-4.3
+func normalizeHTU(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" {
+		return "", errors.New("DPoP htu must be an absolute URI")
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.ToLower(parsed.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	port := parsed.Port()
+	if port != "" && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+		host += ":" + port
+	}
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path, nil
+}
+
+// CreateDPoPProof signs a DPoP proof with an AKP private key, with its
+// public key in the jwk header parameter. The typ and jwk header
+// parameters replace those set with WithHeader.
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-4.2
+func CreateDPoPProof(private_key string, proof DPoPProofClaims, opts ...SignOption) (string, error) {
+	public_key, err := PublicKeyFromPrivateKey(private_key)
+	if err != nil {
+		return "", err
+	}
+	if proof.Method == "" {
+		return "", errors.New("DPoP proof requires the HTTP method")
+	}
+	htu, err := normalizeHTU(proof.URI)
+	if err != nil {
+		return "", err
+	}
+	claims := Claims{
+		IssuedAt: proof.IssuedAt,
+		JWTID:    proof.JWTID,
+		Custom: map[string]any{
+			DPOP_CLAIM_HTM: proof.Method,
+			DPOP_CLAIM_HTU: htu,
+		},
+	}
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = time.Now()
+	}
+	if claims.JWTID == "" {
+		jti := make([]byte, 16)
+		_, err = io.ReadFull(crypto_rand.Reader, jti)
+		if err != nil {
+			return "", err
+		}
+		claims.JWTID = base64.RawURLEncoding.EncodeToString(jti)
+	}
+	if proof.AccessToken != "" {
+		claims.Custom[DPOP_CLAIM_ATH] = accessTokenHash(proof.AccessToken)
+	}
+	if proof.Nonce != "" {
+		claims.Custom[DPOP_CLAIM_NONCE] = proof.Nonce
+	}
+	return IssueJWT(private_key, claims, slices.Concat(opts, []SignOption{func(o *signOptions) {
+		extra := map[string]json.RawMessage{"jwk": json.RawMessage(public_key)}
+		for name, value := range o.header.Extra {
+			if name != "jwk" {
+				extra[name] = value
+			}
+		}
+		o.header.Typ = DPOP_JWT_TYPE
+		o.header.Extra = extra
+	}})...)
+}
+
+// DPoPProof is a validated DPoP proof.
+type DPoPProof struct {
+	Header JWSHeader
+	Claims Claims
+	// PublicKey is the jwk header parameter, and JKT its JWK thumbprint,
+	// which an access token is bound to with cnf.jkt
+	PublicKey string
+	JKT       string
+}
+
+// DPoPValidator validates the DPoP proofs a server receives. It rejects
+// replayed proofs, and when nonces are enabled proofs without a recent
+// server nonce.
+type DPoPValidator struct {
+	max_age        time.Duration
+	leeway         time.Duration
+	nonce_lifetime time.Duration
+	now            func() time.Time
+	verify_options []VerifyOption
+	cache_size     int
+
+	mu             sync.Mutex
+	seen           map[string]bool
+	expiry         []dpopSeen
+	nonce          string
+	previous_nonce string
+	nonce_issued   time.Time
+}
+
+// dpopSeen is a proof in the replay cache, which is kept in the order
+// proofs were seen and so the order they expire.
+type dpopSeen struct {
+	jti     string
+	expires time.Time
+}
+
+type DPoPValidatorOption func(*DPoPValidator)
+
+// WithDPoPMaxAge changes DPOP_DEFAULT_MAX_AGE.
+func WithDPoPMaxAge(max_age time.Duration) DPoPValidatorOption {
+	return func(v *DPoPValidator) {
+		v.max_age = max_age
+	}
+}
+
+// WithDPoPLeeway allows for clock skew between client and server.
+func WithDPoPLeeway(leeway time.Duration) DPoPValidatorOption {
+	return func(v *DPoPValidator) {
+		v.leeway = leeway
+	}
+}
+
+// WithDPoPNonces requires proofs to have a server nonce, which changes
+// every lifetime. The previous nonce is still accepted.
+func WithDPoPNonces(lifetime time.Duration) DPoPValidatorOption {
+	return func(v *DPoPValidator) {
+		v.nonce_lifetime = lifetime
+	}
+}
+
+// WithDPoPReplayCacheSize changes DPOP_DEFAULT_REPLAY_CACHE_SIZE.
+func WithDPoPReplayCacheSize(size int) DPoPValidatorOption {
+	return func(v *DPoPValidator) {
+		v.cache_size = size
+	}
+}
+
+// WithDPoPClock replaces time.Now for proof age, replay and nonce expiry.
+func WithDPoPClock(now func() time.Time) DPoPValidatorOption {
+	return func(v *DPoPValidator) {
+		v.now = now
+	}
+}
+
+// WithDPoPVerifyOptions verifies proof signatures with opts, such as
+// AllowedAlgorithms.
+func WithDPoPVerifyOptions(opts ...VerifyOption) DPoPValidatorOption {
+	return func(v *DPoPValidator) {
+		v.verify_options = append(v.verify_options, opts...)
+	}
+}
+
+func NewDPoPValidator(opts ...DPoPValidatorOption) *DPoPValidator {
+	v := &DPoPValidator{
+		max_age:    DPOP_DEFAULT_MAX_AGE,
+		cache_size: DPOP_DEFAULT_REPLAY_CACHE_SIZE,
+		now:        time.Now,
+		seen:       map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Nonce returns the current server nonce, to send in the DPoP-Nonce
+// header, or an empty string when nonces are not enabled.
+func (v *DPoPValidator) Nonce() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.currentNonce(v.now())
+}
+
+func (v *DPoPValidator) currentNonce(now time.Time) string {
+	if v.nonce_lifetime == 0 {
+		return ""
+	}
+	if v.nonce == "" || now.Sub(v.nonce_issued) >= v.nonce_lifetime {
+		nonce := make([]byte, 16)
+		_, _ = io.ReadFull(crypto_rand.Reader, nonce)
+		v.previous_nonce = v.nonce
+		v.nonce = base64.RawURLEncoding.EncodeToString(nonce)
+		v.nonce_issued = now
+	}
+	return v.nonce
+}
+
+// Validate validates a DPoP proof for a request with the method and URI,
+// and the access token it accompanies, if any. The proof key must have the
+// JWK thumbprint jkt, the cnf.jkt of the access token. Only requests to the
+// token endpoint, which have no access token, may leave jkt empty, and the
+// returned JKT is then the thumbprint to bind the issued token to.
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-4.3
+func (v *DPoPValidator) Validate(proof string, method string, uri string, access_token string, jkt string) (DPoPProof, error) {
+	var validated DPoPProof
+	if access_token != "" && jkt == "" {
+		return validated, errors.New("DPoP proof for an access token requires its jkt")
+	}
+	header, err := decodeHeader(strings.SplitN(proof, ".", 2)[0])
+	if err != nil {
+		return validated, err
+	}
+	if header.Typ != DPOP_JWT_TYPE {
+		return validated, errors.New("DPoP proof type must be dpop+jwt")
+	}
+	jwk, exists := header.Extra["jwk"]
+	if !exists {
+		return validated, errors.New("DPoP proof is missing the jwk header parameter")
+	}
+	validated.PublicKey = string(jwk)
+	verified, err := CompactVerify(validated.PublicKey, proof, v.verify_options...)
+	if err != nil {
+		return validated, err
+	}
+	validated.Header = verified.Header
+	validated.JKT, err = CalculateJwkThumbprint(validated.PublicKey)
+	if err != nil {
+		return validated, err
+	}
+	if jkt != "" && validated.JKT != jkt {
+		return validated, errors.New("DPoP proof key is not the key the access token is bound to")
+	}
+	err = json.Unmarshal(verified.Payload, &validated.Claims)
+	if err != nil {
+		return validated, errors.New("Failed to decode DPoP proof claims")
+	}
+	claims := validated.Claims
+	if claims.JWTID == "" || claims.IssuedAt.IsZero() {
+		return validated, errors.New("DPoP proof is missing jti or iat")
+	}
+	if htm, _ := claims.Custom[DPOP_CLAIM_HTM].(string); htm != method {
+		return validated, errors.New("DPoP proof htm is not the request method")
+	}
+	htu, _ := claims.Custom[DPOP_CLAIM_HTU].(string)
+	normalized_htu, htu_err := normalizeHTU(htu)
+	normalized_uri, uri_err := normalizeHTU(uri)
+	if htu_err != nil || uri_err != nil || normalized_htu != normalized_uri {
+		return validated, errors.New("DPoP proof htu is not the request URI")
+	}
+	ath, has_ath := claims.Custom[DPOP_CLAIM_ATH].(string)
+	if access_token != "" && (!has_ath || ath != accessTokenHash(access_token)) {
+		return validated, errors.New("DPoP proof ath is not the hash of the access token")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	if now.Add(v.leeway).Before(claims.IssuedAt) || now.Sub(claims.IssuedAt) > v.max_age+v.leeway {
+		return validated, errors.New("DPoP proof issued at time is not acceptable")
+	}
+	if v.nonce_lifetime != 0 {
+		nonce, _ := claims.Custom[DPOP_CLAIM_NONCE].(string)
+		current := v.currentNonce(now)
+		if nonce == "" || (nonce != current && nonce != v.previous_nonce) {
+			return validated, ErrUseDPoPNonce
+		}
+	}
+	return validated, v.checkReplay(validated.JKT+"."+claims.JWTID, now)
+}
+
+// checkReplay remembers a jti until proofs with it are too old to be
+// accepted, and rejects it if it was seen before. Every entry is kept for
+// the same time, so expired entries are at the front of the queue.
+func (v *DPoPValidator) checkReplay(jti string, now time.Time) error {
+	expired := 0
+	for expired < len(v.expiry) && !now.Before(v.expiry[expired].expires) {
+		delete(v.seen, v.expiry[expired].jti)
+		expired++
+	}
+	v.expiry = v.expiry[expired:]
+	if v.seen[jti] {
+		return errors.New("DPoP proof has been used before")
+	}
+	if len(v.expiry) >= v.cache_size {
+		return errors.New("DPoP replay cache is full")
+	}
+	v.seen[jti] = true
+	v.expiry = append(v.expiry, dpopSeen{jti: jti, expires: now.Add(v.max_age + 2*v.leeway)})
+	return nil
+}
+
+// requestURI reconstructs the URI of a request received by a server.
+func requestURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.EscapedPath()
+}
+
+// ValidateRequest validates the DPoP header of a request, and the access
+// token in its DPoP Authorization header, if any.
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-7.1
+func (v *DPoPValidator) ValidateRequest(r *http.Request, jkt string) (DPoPProof, error) {
+	proofs := r.Header.Values(DPOP_HEADER)
+	if len(proofs) != 1 {
+		return DPoPProof{}, errors.New("Request must have exactly one DPoP header")
+	}
+	access_token := ""
+	authorization := r.Header.Get("Authorization")
+	if authorization != "" {
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, DPOP_SCHEME) || token == "" {
+			return DPoPProof{}, errors.New("Access token must use the DPoP authorization scheme")
+		}
+		access_token = token
+	}
+	return v.Validate(proofs[0], r.Method, requestURI(r), access_token, jkt)
+}
+
+// WriteChallenge responds 401 with a DPoP challenge for a proof that
+// failed validation, and the server nonce when nonces are enabled. It is
+// for resource servers only, an authorization server responds 400 with a
+// JSON error instead (RFC 9449, Section 8).
+// see: https://datatracker.ietf.org/doc/html/rfc9449#section-7.1
+func (v *DPoPValidator) WriteChallenge(w http.ResponseWriter, err error) {
+	if err == nil {
+		err = errors.New("Invalid DPoP proof")
+	}
+	challenge := DPOP_SCHEME + ` error="invalid_dpop_proof"`
+	if errors.Is(err, ErrUseDPoPNonce) {
+		challenge = DPOP_SCHEME + ` error="use_dpop_nonce"`
+	}
+	if nonce := v.Nonce(); nonce != "" {
+		w.Header().Set(DPOP_NONCE_HEADER, nonce)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
@@ -0,0 +1,266 @@
+package jose
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type JOSEDPoPTestVector struct {
+	Jwk         AKPKey `json:"jwk"`
+	AccessToken string `json:"access_token"`
+	Proof       string `json:"dpop"`
+}
+
+var dpop_proof = DPoPProofClaims{
+	Method:      http.MethodGet,
+	URI:         "https://resource.example.org/protectedresource",
+	AccessToken: "Kz~8mXK1EalYznwH-LC-1fBAo.4Ljp~zsPE_NeO.gxU",
+	IssuedAt:    time.Unix(1562262616, 0),
+	JWTID:       "e1j3V_bKic8-LAEB",
+}
+
+// TestDPoPProof calls jose.CreateDPoPProof and jose.DPoPValidator.Validate
+// for each ML-DSA level and confirms the proof is bound to the request, the
+// access token and the key thumbprint
+func TestDPoPProof(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		validator := NewDPoPValidator(WithDPoPClock(func() time.Time { return dpop_proof.IssuedAt.Add(time.Minute) }))
+		proof, err := CreateDPoPProof(private_key, dpop_proof)
+		if err != nil {
+			t.Fatalf("Creating %s DPoP proof failed: %v", alg, err)
+		}
+		validated, err := validator.Validate(proof, http.MethodGet, "HTTPS://Resource.example.org:443/protectedresource?query", dpop_proof.AccessToken, key.Kid)
+		if err != nil {
+			t.Fatalf("Validating %s DPoP proof failed: %v", alg, err)
+		}
+		if validated.Header.Typ != DPOP_JWT_TYPE || validated.PublicKey != public_key || validated.JKT != key.Kid || validated.Claims.JWTID != dpop_proof.JWTID {
+			t.Fatalf("Invalid DPoP proof %+v", validated)
+		}
+		_, err = validator.Validate(proof, http.MethodGet, dpop_proof.URI, dpop_proof.AccessToken, key.Kid)
+		if err == nil {
+			t.Fatalf("Validated a replayed DPoP proof")
+		}
+		examples, _ := json.MarshalIndent(JOSEDPoPTestVector{
+			Jwk:         key,
+			AccessToken: dpop_proof.AccessToken,
+			Proof:       proof,
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".dpop.jose.json", examples, 0644)
+	}
+}
+
+// TestDPoPProofRejected calls jose.DPoPValidator.Validate with proofs for
+// another request, access token, key or time, and confirms each is rejected
+func TestDPoPProofRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	key, _ := DecodeKey(private_key)
+	other_private_key, _ := GenerateKey(ML_DSA_44, large_payload[:32])
+	now := func() time.Time { return dpop_proof.IssuedAt }
+	proof_with := func(private_key string, change func(*DPoPProofClaims)) string {
+		claims := dpop_proof
+		claims.JWTID = ""
+		change(&claims)
+		proof, _ := CreateDPoPProof(private_key, claims)
+		return proof
+	}
+	unchanged := func(*DPoPProofClaims) {}
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	jwt_with := func(header JWSHeader) string {
+		jwt, _ := IssueJWT(private_key, Claims{
+			IssuedAt: dpop_proof.IssuedAt,
+			JWTID:    "1",
+			Custom: map[string]any{
+				DPOP_CLAIM_HTM: dpop_proof.Method,
+				DPOP_CLAIM_HTU: dpop_proof.URI,
+				DPOP_CLAIM_ATH: accessTokenHash(dpop_proof.AccessToken),
+			},
+		}, WithHeader(header))
+		return jwt
+	}
+	for name, proof := range map[string]string{
+		"other method":         proof_with(private_key, func(c *DPoPProofClaims) { c.Method = http.MethodPost }),
+		"other uri":            proof_with(private_key, func(c *DPoPProofClaims) { c.URI = "https://resource.example.org/other" }),
+		"other access token":   proof_with(private_key, func(c *DPoPProofClaims) { c.AccessToken = "other" }),
+		"no access token hash": proof_with(private_key, func(c *DPoPProofClaims) { c.AccessToken = "" }),
+		"other key":            proof_with(other_private_key, unchanged),
+		"expired":              proof_with(private_key, func(c *DPoPProofClaims) { c.IssuedAt = c.IssuedAt.Add(-time.Hour) }),
+		"issued in the future": proof_with(private_key, func(c *DPoPProofClaims) { c.IssuedAt = c.IssuedAt.Add(time.Minute) }),
+		"other type":           jwt_with(JWSHeader{Typ: JWT_TYPE, Extra: map[string]json.RawMessage{"jwk": json.RawMessage(public_key)}}),
+		"no jwk":               jwt_with(JWSHeader{Typ: DPOP_JWT_TYPE}),
+		"private key in jwk":   jwt_with(JWSHeader{Typ: DPOP_JWT_TYPE, Extra: map[string]json.RawMessage{"jwk": json.RawMessage(private_key)}}),
+		"malformed":            "a.b.c",
+	} {
+		validator := NewDPoPValidator(WithDPoPClock(now))
+		_, err := validator.Validate(proof, http.MethodGet, dpop_proof.URI, dpop_proof.AccessToken, key.Kid)
+		if err == nil {
+			t.Fatalf("Validated DPoP proof with %s", name)
+		}
+	}
+	validator := NewDPoPValidator(WithDPoPClock(now), WithDPoPVerifyOptions(AllowedAlgorithms(ML_DSA_87)))
+	_, err := validator.Validate(proof_with(private_key, unchanged), http.MethodGet, dpop_proof.URI, dpop_proof.AccessToken, key.Kid)
+	if err == nil {
+		t.Fatalf("Validated DPoP proof with an algorithm that is not allowed")
+	}
+	validator = NewDPoPValidator(WithDPoPClock(now))
+	_, err = validator.Validate(proof_with(private_key, unchanged), http.MethodGet, dpop_proof.URI, dpop_proof.AccessToken, "")
+	if err == nil {
+		t.Fatalf("Validated DPoP proof for an access token without its jkt")
+	}
+	token_request := proof_with(private_key, func(c *DPoPProofClaims) { c.Method = http.MethodPost; c.AccessToken = "" })
+	validated, err := validator.Validate(token_request, http.MethodPost, dpop_proof.URI, "", "")
+	if err != nil || validated.JKT != key.Kid {
+		t.Fatalf("Validating DPoP proof for a token request failed: %v", err)
+	}
+	_, err = CreateDPoPProof(private_key, DPoPProofClaims{Method: http.MethodGet, URI: "/relative"})
+	if err == nil {
+		t.Fatalf("Created DPoP proof for a relative URI")
+	}
+}
+
+// TestDPoPReplayCache confirms proofs are remembered until they are too old
+// to be accepted, and new proofs are rejected while the cache is full
+func TestDPoPReplayCache(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	key, _ := DecodeKey(private_key)
+	now := dpop_proof.IssuedAt
+	validator := NewDPoPValidator(WithDPoPClock(func() time.Time { return now }), WithDPoPReplayCacheSize(2))
+	validate := func(jti string) error {
+		proof, _ := CreateDPoPProof(private_key, DPoPProofClaims{Method: http.MethodGet, URI: dpop_proof.URI, IssuedAt: now, JWTID: jti})
+		_, err := validator.Validate(proof, http.MethodGet, dpop_proof.URI, "", key.Kid)
+		return err
+	}
+	if validate("1") != nil || validate("2") != nil {
+		t.Fatalf("Validating DPoP proofs failed")
+	}
+	if validate("1") == nil {
+		t.Fatalf("Validated a replayed DPoP proof")
+	}
+	if validate("3") == nil {
+		t.Fatalf("Validated a DPoP proof while the replay cache is full")
+	}
+	now = now.Add(DPOP_DEFAULT_MAX_AGE)
+	if validate("3") != nil || validate("1") != nil {
+		t.Fatalf("Expired DPoP proofs were not removed from the replay cache")
+	}
+	if len(validator.seen) != 2 || len(validator.expiry) != 2 {
+		t.Fatalf("Replay cache has %d entries, want 2", len(validator.seen))
+	}
+}
+
+// TestNormalizeHTU confirms htu is compared without query, fragment,
+// default port and case differences in the scheme and host
+func TestNormalizeHTU(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"https://server.example.com/token":                 "https://server.example.com/token",
+		"HTTPS://Server.Example.COM:443/token?x=1#section": "https://server.example.com/token",
+		"http://server.example.com:80":                     "http://server.example.com/",
+		"https://server.example.com:8443/a%2Fb":            "https://server.example.com:8443/a%2Fb",
+		"https://[::1]:443/token":                          "https://[::1]/token",
+	} {
+		normalized, err := normalizeHTU(uri)
+		if err != nil || normalized != expected {
+			t.Fatalf("Normalized %s to %s, want %s: %v", uri, normalized, expected, err)
+		}
+	}
+}
+
+// TestDPoPResourceServer serves a protected resource with httptest that
+// requires a DPoP bound access token, and confirms a client must retry with
+// the server nonce and cannot replay a proof or use another key
+func TestDPoPResourceServer(t *testing.T) {
+	authorization_server_key, _ := GenerateKey(ML_DSA_65, seed[:])
+	client_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	other_client_key, _ := GenerateKey(ML_DSA_44, large_payload[:32])
+	client_jkt, _ := CalculateJwkThumbprint(client_key)
+	access_token, _ := IssueJWT(authorization_server_key, Claims{
+		Issuer:   "https://server.example.com",
+		Audience: []string{"https://resource.example.org"},
+		Custom:   map[string]any{SD_JWT_CLAIM_CNF: map[string]any{"jkt": client_jkt}},
+	}, WithHeader(JWSHeader{Typ: "at+jwt"}))
+	resolver := NewStaticKeyResolver(authorization_server_key)
+	validator := NewDPoPValidator(WithDPoPNonces(time.Minute))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		claims, err := VerifyJWT(resolver, token, JWTValidation{Audience: "https://resource.example.org", Type: "at+jwt"})
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", DPOP_SCHEME+` error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		cnf, _ := claims.Custom[SD_JWT_CLAIM_CNF].(map[string]any)
+		jkt, _ := cnf["jkt"].(string)
+		_, err = validator.ValidateRequest(r, jkt)
+		if err != nil {
+			validator.WriteChallenge(w, err)
+			return
+		}
+		_, _ = w.Write([]byte("protected"))
+	}))
+	defer server.Close()
+	request := func(private_key string, nonce string) (*http.Response, string) {
+		proof, _ := CreateDPoPProof(private_key, DPoPProofClaims{
+			Method:      http.MethodGet,
+			URI:         server.URL + "/resource?q=1",
+			AccessToken: access_token,
+			Nonce:       nonce,
+		})
+		return sendWithDPoP(t, server.URL+"/resource?q=1", access_token, proof), proof
+	}
+
+	response, _ := request(client_key, "")
+	nonce := response.Header.Get(DPOP_NONCE_HEADER)
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `DPoP error="use_dpop_nonce"` || nonce == "" {
+		t.Fatalf("Request without a nonce returned %s %v", response.Status, response.Header)
+	}
+	response, proof := request(client_key, nonce)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Request with a nonce returned %s", response.Status)
+	}
+	response = sendWithDPoP(t, server.URL+"/resource?q=1", access_token, proof)
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `DPoP error="invalid_dpop_proof"` {
+		t.Fatalf("Replayed proof returned %s", response.Status)
+	}
+	response, _ = request(other_client_key, nonce)
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Proof with another key returned %s", response.Status)
+	}
+	response, _ = request(client_key, "stale")
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `DPoP error="use_dpop_nonce"` {
+		t.Fatalf("Proof with a stale nonce returned %s", response.Status)
+	}
+	recorder := httptest.NewRecorder()
+	validator.WriteChallenge(recorder, nil)
+	if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") != `DPoP error="invalid_dpop_proof"` {
+		t.Fatalf("Challenge without an error returned %d %v", recorder.Code, recorder.Header())
+	}
+	proof, _ = CreateDPoPProof(client_key, DPoPProofClaims{Method: http.MethodGet, URI: server.URL + "/resource", AccessToken: access_token, Nonce: nonce})
+	request_with_proofs, _ := http.NewRequest(http.MethodGet, server.URL+"/resource", nil)
+	request_with_proofs.Header.Set("Authorization", DPOP_SCHEME+" "+access_token)
+	request_with_proofs.Header.Add(DPOP_HEADER, proof)
+	request_with_proofs.Header.Add(DPOP_HEADER, proof)
+	response, _ = http.DefaultClient.Do(request_with_proofs)
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Request with two proofs returned %s", response.Status)
+	}
+}
+
+func sendWithDPoP(t *testing.T, url string, access_token string, proof string) *http.Response {
+	request, _ := http.NewRequest(http.MethodGet, url, nil)
+	request.Header.Set("Authorization", DPOP_SCHEME+" "+access_token)
+	request.Header.Set(DPOP_HEADER, proof)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	response.Body.Close()
+	return response
+}
@@ -0,0 +1,144 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-7.2.2
+type JWSFlattened struct {
+	Protected string     `json:"protected,omitempty"`
+	Header    *JWSHeader `json:"header,omitempty"`
+	Payload   string     `json:"payload"`
+	Signature string     `json:"signature"`
+}
+
+// keyResolver resolves a single public key regardless of kid, as
+// CompactVerify does not require the kid to match the key.
+type keyResolver string
+
+func (r keyResolver) Resolve(kid string, alg string) ([]string, error) {
+	return []string{string(r)}, nil
+}
+
+// FlattenedSign signs a payload with the protected header produced by
+// CompactSign, and adds the unprotected header members of header.
+func FlattenedSign(private_key string, payload []byte, header *JWSHeader, opts ...SignOption) (string, error) {
+	o := newSignOptions(opts)
+	if o.unencoded || o.detached {
+		return "", errors.New("Unencoded and detached payloads are only supported in compact serialization")
+	}
+	var encoded_payload = base64.RawURLEncoding.EncodeToString(payload)
+	protected, signature, err := signJWS(private_key, encoded_payload, o)
+	if err != nil {
+		return "", err
+	}
+	flattened := JWSFlattened{
+		Protected: protected,
+		Header:    header,
+		Payload:   encoded_payload,
+		Signature: signature,
+	}
+	_, err = jointHeader(flattened.signature())
+	if err != nil {
+		return "", err
+	}
+	jws, err := json.Marshal(flattened)
+	if err != nil {
+		return "", err
+	}
+	return string(jws), nil
+}
+
+func (f JWSFlattened) signature() JWSJSONSignature {
+	return JWSJSONSignature{
+		Protected: f.Protected,
+		Header:    f.Header,
+		Signature: f.Signature,
+	}
+}
+
+// FlattenedVerify verifies a JWS in Flattened JSON serialization, the
+// verified header is the union of the protected and unprotected headers.
+func FlattenedVerify(public_key string, jws string, opts ...VerifyOption) (JWSVerification, error) {
+	var verified = JWSVerification{}
+	_, err := parsePublicKey(public_key)
+	if err != nil {
+		return verified, err
+	}
+	flattened, err := decodeFlattened(jws)
+	if err != nil {
+		return verified, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(flattened.Payload)
+	if err != nil {
+		return verified, errors.New("JWS Payload is not encoded as base64url")
+	}
+	signature := verifyJSONSignature(keyResolver(public_key), flattened.Payload, flattened.signature(), newVerifyOptions(opts))
+	if signature.Err != nil {
+		return verified, signature.Err
+	}
+	verified.Header = signature.Header
+	verified.Payload = payload
+	return verified, nil
+}
+
+func decodeFlattened(jws string) (JWSFlattened, error) {
+	var flattened JWSFlattened
+	var members map[string]json.RawMessage
+	err := json.Unmarshal([]byte(jws), &members)
+	if err != nil {
+		return flattened, errors.New("Failed to parse JWS JSON serialization")
+	}
+	if _, exists := members["signatures"]; exists {
+		return flattened, errors.New("JWS is in General JSON serialization")
+	}
+	if _, exists := members["signature"]; !exists {
+		return flattened, errors.New("JWS is missing the signature")
+	}
+	err = json.Unmarshal([]byte(jws), &flattened)
+	if err != nil {
+		return flattened, errors.New("Failed to parse JWS JSON serialization")
+	}
+	return flattened, nil
+}
+
+// CompactToFlattened converts a JWS from compact to Flattened JSON
+// serialization, the signature is unchanged.
+func CompactToFlattened(jws string) (string, error) {
+	components := strings.Split(jws, ".")
+	if len(components) != 3 {
+		return "", errors.New("JWS must have three components")
+	}
+	flattened, err := json.Marshal(JWSFlattened{
+		Protected: components[0],
+		Payload:   components[1],
+		Signature: components[2],
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(flattened), nil
+}
+
+// FlattenedToCompact converts a JWS from Flattened JSON to compact
+// serialization. It fails when there is an unprotected header, which the
+// compact serialization cannot carry.
+func FlattenedToCompact(jws string) (string, error) {
+	flattened, err := decodeFlattened(jws)
+	if err != nil {
+		return "", err
+	}
+	if flattened.Header != nil {
+		return "", errors.New("Compact serialization cannot carry an unprotected header")
+	}
+	if flattened.Protected == "" {
+		return "", errors.New("Compact serialization requires a protected header")
+	}
+	if strings.Contains(flattened.Protected+flattened.Payload+flattened.Signature, ".") {
+		return "", errors.New("JWS members are not encoded as base64url")
+	}
+	return flattened.Protected + "." + flattened.Payload + "." + flattened.Signature, nil
+}
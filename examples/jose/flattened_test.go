@@ -0,0 +1,94 @@
+package jose
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+type JOSEFlattenedTestVector struct {
+	Jwk       AKPKey          `json:"jwk"`
+	Jws       string          `json:"jws"`
+	Flattened json.RawMessage `json:"flattened"`
+}
+
+// TestFlattenedSign calls jose.FlattenedSign with an unprotected header for
+// each ML-DSA level and confirms jose.FlattenedVerify returns both headers
+func TestFlattenedSign(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		jws, err := FlattenedSign(private_key, payload, &JWSHeader{Extra: map[string]json.RawMessage{"x-route": json.RawMessage(`"ingest"`)}})
+		if err != nil {
+			t.Fatalf("Flattened signing %s failed: %v", alg, err)
+		}
+		verified, err := FlattenedVerify(public_key, jws)
+		if err != nil {
+			t.Fatalf("Flattened verification %s failed: %v", alg, err)
+		}
+		if string(verified.Payload) != string(payload) || verified.Header.Alg != alg || verified.Header.Kid != key.Kid || string(verified.Header.Extra["x-route"]) != `"ingest"` {
+			t.Fatalf("Invalid flattened verification")
+		}
+		_, err = FlattenedToCompact(jws)
+		if err == nil {
+			t.Fatalf("Converted an unprotected header to compact serialization")
+		}
+		_, err = FlattenedVerify(private_key, jws)
+		if err == nil {
+			t.Fatalf("FlattenedVerify accepted a private key")
+		}
+
+		compact, _ := CompactSign(private_key, payload)
+		flattened, err := CompactToFlattened(compact)
+		if err != nil {
+			t.Fatalf("Converting to flattened serialization failed: %v", err)
+		}
+		_, err = FlattenedVerify(public_key, flattened)
+		if err != nil {
+			t.Fatalf("Converted flattened JWS does not verify: %v", err)
+		}
+		round_trip, err := FlattenedToCompact(flattened)
+		if err != nil || round_trip != compact {
+			t.Fatalf("Compact serialization does not round trip: %v", err)
+		}
+		examples, _ := json.MarshalIndent(JOSEFlattenedTestVector{
+			Jwk:       key,
+			Jws:       compact,
+			Flattened: json.RawMessage(flattened),
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".flattened.jose.json", examples, 0644)
+	}
+}
+
+// TestFlattenedRejected confirms tampered and malformed flattened JWS are
+// rejected
+func TestFlattenedRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	jws, _ := FlattenedSign(private_key, payload, nil)
+	var flattened JWSFlattened
+	json.Unmarshal([]byte(jws), &flattened)
+	flattened.Payload = "e30"
+	tampered, _ := json.Marshal(flattened)
+	_, err := FlattenedVerify(public_key, string(tampered))
+	if err == nil {
+		t.Fatalf("Verified a tampered payload")
+	}
+	general, _ := GeneralSign(payload, GeneralSigner{PrivateKey: private_key})
+	for _, malformed := range []string{``, `{}`, general, `{"payload":"e30","signature":"AA"}`} {
+		_, err = FlattenedVerify(public_key, malformed)
+		if err == nil {
+			t.Fatalf("Verified malformed flattened JWS %q", malformed)
+		}
+	}
+	_, err = FlattenedToCompact(`{"protected":"e30","payload":"a.b","signature":"AA"}`)
+	if err == nil {
+		t.Fatalf("Converted a payload that is not base64url")
+	}
+	_, err = CompactToFlattened("e30.e30")
+	if err == nil {
+		t.Fatalf("Converted a JWS with two components")
+	}
+}
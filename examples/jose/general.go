@@ -0,0 +1,210 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-7.2.1
+type JWSGeneral struct {
+	Payload    string             `json:"payload"`
+	Signatures []JWSJSONSignature `json:"signatures"`
+}
+
+type JWSJSONSignature struct {
+	Protected string     `json:"protected,omitempty"`
+	Header    *JWSHeader `json:"header,omitempty"`
+	Signature string     `json:"signature"`
+}
+
+// GeneralSigner signs with an AKP private key, adding an unprotected header
+// to the protected header produced by CompactSign.
+type GeneralSigner struct {
+	PrivateKey string
+	Header     *JWSHeader
+	Options    []SignOption
+}
+
+type GeneralSignatureVerification struct {
+	// Header is the JOSE Header, the union of the protected and unprotected
+	// headers of the signature
+	Header JWSHeader
+	// PublicKey is the key the signature validated against, it is empty
+	// when Err is set
+	PublicKey string
+	Err       error
+}
+
+type GeneralVerification struct {
+	Payload    []byte
+	Signatures []GeneralSignatureVerification
+}
+
+// jointHeader decodes the protected header, and adds the unprotected header.
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-7.2.1
+func jointHeader(signature JWSJSONSignature) (JWSHeader, error) {
+	var header JWSHeader
+	members := map[string]json.RawMessage{}
+	if signature.Protected != "" {
+		decoded_header, err := base64.RawURLEncoding.DecodeString(signature.Protected)
+		if err != nil {
+			return header, errors.New("JWS Header is not encoded as base64url")
+		}
+		err = json.Unmarshal(decoded_header, &members)
+		if err != nil {
+			return header, errors.New("Failed to parse JWS header")
+		}
+	}
+	if signature.Header != nil {
+		if signature.Header.Has(HEADER_CRITICAL) || signature.Header.Has(HEADER_B64) || signature.Header.Has(HEADER_EXPERIMENTAL_CONTEXT) {
+			return header, errors.New("crit, b64 and experimental context header parameters must be in the protected header")
+		}
+		unprotected, err := json.Marshal(signature.Header)
+		if err != nil {
+			return header, err
+		}
+		var unprotected_members map[string]json.RawMessage
+		_ = json.Unmarshal(unprotected, &unprotected_members)
+		for name, value := range unprotected_members {
+			if _, exists := members[name]; exists {
+				return header, errors.New("Protected and unprotected headers must be disjoint")
+			}
+			members[name] = value
+		}
+	}
+	joint, _ := json.Marshal(members)
+	err := json.Unmarshal(joint, &header)
+	if err != nil {
+		return header, errors.New("Failed to parse JWS header")
+	}
+	return header, nil
+}
+
+func GeneralSign(payload []byte, signers ...GeneralSigner) (string, error) {
+	if len(signers) == 0 {
+		return "", errors.New("General JWS requires at least one signer")
+	}
+	general := JWSGeneral{
+		Payload: base64.RawURLEncoding.EncodeToString(payload),
+	}
+	for _, signer := range signers {
+		o := newSignOptions(signer.Options)
+		if o.unencoded || o.detached {
+			return "", errors.New("Unencoded and detached payloads are only supported in compact serialization")
+		}
+		protected, signature, err := signJWS(signer.PrivateKey, general.Payload, o)
+		if err != nil {
+			return "", err
+		}
+		json_signature := JWSJSONSignature{
+			Protected: protected,
+			Header:    signer.Header,
+			Signature: signature,
+		}
+		_, err = jointHeader(json_signature)
+		if err != nil {
+			return "", err
+		}
+		general.Signatures = append(general.Signatures, json_signature)
+	}
+	jws, err := json.Marshal(general)
+	if err != nil {
+		return "", err
+	}
+	return string(jws), nil
+}
+
+// verifyJSONSignature verifies one signature of a JSON serialized JWS with
+// the candidate keys from the resolver, and returns the key it validated
+// against.
+func verifyJSONSignature(resolver KeyResolver, encoded_payload string, signature JWSJSONSignature, o verifyOptions) GeneralSignatureVerification {
+	var verified GeneralSignatureVerification
+	header, err := jointHeader(signature)
+	if err != nil {
+		verified.Err = err
+		return verified
+	}
+	verified.Header = header
+	if signature.Protected == "" {
+		verified.Err = errors.New("JWS signature is missing the protected header")
+		return verified
+	}
+	protected, err := decodeProtectedHeader(signature.Protected, o)
+	if err != nil {
+		verified.Err = err
+		return verified
+	}
+	if protected.unencoded() {
+		verified.Err = errors.New("Unencoded payloads are only supported in compact serialization")
+		return verified
+	}
+	if protected.Alg == "" {
+		verified.Err = errors.New("JWS algorithm must be in the protected header")
+		return verified
+	}
+	err = checkAlgorithm(o, protected.Alg)
+	if err != nil {
+		verified.Err = err
+		return verified
+	}
+	ctx, err := contextForVerification(o, protected)
+	if err != nil {
+		verified.Err = err
+		return verified
+	}
+	raw_signature, err := base64.RawURLEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		verified.Err = errors.New("Failed to decode signature from JWS")
+		return verified
+	}
+	candidates, err := resolver.Resolve(header.Kid, header.Alg)
+	if err != nil {
+		verified.Err = err
+		return verified
+	}
+	var to_be_signed_bytes = []byte(signature.Protected + "." + encoded_payload)
+	for _, public_key := range candidates {
+		key, err := parsePublicKey(public_key)
+		if err != nil || key.alg != header.Alg {
+			continue
+		}
+		if verifyWithContext(key.alg, key.suite, key.key, to_be_signed_bytes, raw_signature, ctx) {
+			verified.PublicKey = public_key
+			return verified
+		}
+	}
+	verified.Err = errors.New("Signature not from a resolved public key")
+	return verified
+}
+
+// GeneralVerify verifies every signature of a JWS in General JSON
+// serialization with keys from the resolver, and reports which key each
+// signature validated against. It fails when no signature validates.
+func GeneralVerify(resolver KeyResolver, jws string, opts ...VerifyOption) (GeneralVerification, error) {
+	var verified = GeneralVerification{}
+	o := newVerifyOptions(opts)
+	var general JWSGeneral
+	err := json.Unmarshal([]byte(jws), &general)
+	if err != nil {
+		return verified, errors.New("Failed to parse JWS JSON serialization")
+	}
+	if len(general.Signatures) == 0 {
+		return verified, errors.New("JWS JSON serialization has no signatures")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(general.Payload)
+	if err != nil {
+		return verified, errors.New("JWS Payload is not encoded as base64url")
+	}
+	valid := false
+	for _, signature := range general.Signatures {
+		signature_verified := verifyJSONSignature(resolver, general.Payload, signature, o)
+		valid = valid || signature_verified.Err == nil
+		verified.Signatures = append(verified.Signatures, signature_verified)
+	}
+	if !valid {
+		return verified, errors.New("No JWS signature validated")
+	}
+	verified.Payload = payload
+	return verified, nil
+}
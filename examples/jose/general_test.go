@@ -0,0 +1,127 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/mldsa"
+)
+
+type JOSEGeneralTestVector struct {
+	Jwks []AKPKey        `json:"jwks"`
+	Jws  json.RawMessage `json:"jws"`
+}
+
+// TestGeneralSign calls jose.GeneralSign with a key of each ML-DSA level
+// and confirms jose.GeneralVerify reports the key of each signature
+func TestGeneralSign(t *testing.T) {
+	var signers []GeneralSigner
+	var private_keys []AKPKey
+	var public_keys []string
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		signers = append(signers, GeneralSigner{
+			PrivateKey: private_key,
+			Header:     &JWSHeader{Extra: map[string]json.RawMessage{"x-signer": json.RawMessage(`"` + alg + `"`)}},
+		})
+		private_keys = append(private_keys, key)
+		public_keys = append(public_keys, public_key)
+	}
+	jws, err := GeneralSign(payload, signers...)
+	if err != nil {
+		t.Fatalf("General signing failed: %v", err)
+	}
+	verified, err := GeneralVerify(NewStaticKeyResolver(public_keys...), jws)
+	if err != nil {
+		t.Fatalf("General verification failed: %v", err)
+	}
+	if string(verified.Payload) != string(payload) || len(verified.Signatures) != 3 {
+		t.Fatalf("Invalid general verification")
+	}
+	for i, signature := range verified.Signatures {
+		if signature.Err != nil || signature.PublicKey != public_keys[i] {
+			t.Fatalf("Signature %d did not validate against its key: %v", i, signature.Err)
+		}
+		if signature.Header.Alg != private_keys[i].Alg || string(signature.Header.Extra["x-signer"]) != `"`+private_keys[i].Alg+`"` {
+			t.Fatalf("Invalid joint header for signature %d", i)
+		}
+	}
+
+	verified, err = GeneralVerify(NewStaticKeyResolver(public_keys[1]), jws)
+	if err != nil {
+		t.Fatalf("General verification with one key failed: %v", err)
+	}
+	if verified.Signatures[0].Err == nil || verified.Signatures[1].Err != nil || verified.Signatures[2].Err == nil {
+		t.Fatalf("Signatures validated against the wrong keys")
+	}
+	_, err = GeneralVerify(NewStaticKeyResolver(), jws)
+	if err == nil {
+		t.Fatalf("Verified a general JWS without keys")
+	}
+
+	examples, _ := json.MarshalIndent(JOSEGeneralTestVector{
+		Jwks: private_keys,
+		Jws:  json.RawMessage(jws),
+	}, "", "  ")
+	_ = os.WriteFile("examples/general.jose.json", examples, 0644)
+}
+
+// TestGeneralSignRejected confirms overlapping headers, tampered payloads
+// and malformed serializations are rejected
+func TestGeneralSignRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	resolver := NewStaticKeyResolver(public_key)
+	_, err := GeneralSign(payload, GeneralSigner{
+		PrivateKey: private_key,
+		Header:     &JWSHeader{Alg: ML_DSA_65},
+	})
+	if err == nil {
+		t.Fatalf("Signed with overlapping protected and unprotected headers")
+	}
+	_, err = GeneralSign(payload)
+	if err == nil {
+		t.Fatalf("Signed without signers")
+	}
+
+	jws, _ := GeneralSign(payload, GeneralSigner{PrivateKey: private_key})
+	var general JWSGeneral
+	json.Unmarshal([]byte(jws), &general)
+	general.Payload = "e30"
+	tampered, _ := json.Marshal(general)
+	_, err = GeneralVerify(resolver, string(tampered))
+	if err == nil {
+		t.Fatalf("Verified a tampered payload")
+	}
+	json.Unmarshal([]byte(jws), &general)
+	general.Signatures[0].Header = &JWSHeader{Kid: "other"}
+	overlapping, _ := json.Marshal(general)
+	verified, err := GeneralVerify(resolver, string(overlapping))
+	if err == nil || verified.Signatures[0].Err == nil {
+		t.Fatalf("Verified a signature with overlapping headers")
+	}
+	// signed with a context that is only carried in the unprotected header
+	key, _ := parsePrivateKey(private_key)
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"` + ML_DSA_44 + `","kid":"` + key.kid + `"}`))
+	raw_signature, _ := mldsa.Sign(key.key, mldsa.Pure([]byte("ctx"), []byte(protected+"."+general.Payload)), [32]byte{})
+	general.Signatures = []JWSJSONSignature{{
+		Protected: protected,
+		Header:    &JWSHeader{Ctx: base64.RawURLEncoding.EncodeToString([]byte("ctx"))},
+		Signature: base64.RawURLEncoding.EncodeToString(raw_signature),
+	}}
+	unprotected_context, _ := json.Marshal(general)
+	verified, err = GeneralVerify(resolver, string(unprotected_context), ExpectExperimentalContext([]byte("ctx")))
+	if err == nil || verified.Signatures[0].Err == nil {
+		t.Fatalf("Verified a signature with an unprotected experimental context")
+	}
+	for _, malformed := range []string{``, `{}`, `{"payload":"e30","signatures":[]}`, `{"payload":"e30","signatures":[{"signature":"AA"}]}`} {
+		_, err = GeneralVerify(resolver, malformed)
+		if err == nil {
+			t.Fatalf("Verified malformed general JWS %q", malformed)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package jose
+
+import (
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/schemes"
+)
+
+// EXPERIMENTAL: the draft does not register HashML-DSA. These private
+// algorithms only exist to verify signatures from devices that only support
+// HashML-DSA with SHA-512. Their AKP keys are distinct from ML-DSA keys, and
+// signatures never verify across the two.
+const (
+	HASH_ML_DSA_44_SHA512 = "HashML-DSA-44-SHA512"
+	HASH_ML_DSA_65_SHA512 = "HashML-DSA-65-SHA512"
+	HASH_ML_DSA_87_SHA512 = "HashML-DSA-87-SHA512"
+)
+
+func IsHashMLDSA(alg string) bool {
+	switch alg {
+	case HASH_ML_DSA_44_SHA512, HASH_ML_DSA_65_SHA512, HASH_ML_DSA_87_SHA512:
+		return true
+	default:
+		return false
+	}
+}
+
+// SuiteFromAlgorithm returns the circl scheme for the parameter set of a
+// JOSE algorithm, or nil for unknown algorithms. HashML-DSA keys use the
+// parameter set of the corresponding ML-DSA.
+func SuiteFromAlgorithm(alg string) sign.Scheme {
+	switch alg {
+	case ML_DSA_44, HASH_ML_DSA_44_SHA512:
+		return schemes.ByName(ML_DSA_44)
+	case ML_DSA_65, HASH_ML_DSA_65_SHA512:
+		return schemes.ByName(ML_DSA_65)
+	case ML_DSA_87, HASH_ML_DSA_87_SHA512:
+		return schemes.ByName(ML_DSA_87)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+var hash_ml_dsa_algorithms = map[string]string{
+	HASH_ML_DSA_44_SHA512: ML_DSA_44,
+	HASH_ML_DSA_65_SHA512: ML_DSA_65,
+	HASH_ML_DSA_87_SHA512: ML_DSA_87,
+}
+
+// TestSignHashMLDSA calls jose.CompactSign with experimental HashML-DSA keys
+// and confirms the result verifies with jose.CompactVerify
+func TestSignHashMLDSA(t *testing.T) {
+	for alg := range hash_ml_dsa_algorithms {
+		var private_key, _ = GenerateKey(alg, seed[:])
+		var key, _ = DecodeKey(private_key)
+		var public_key, _ = PublicKeyFromPrivateKey(private_key)
+		if key.Alg != alg {
+			t.Fatalf(`JWK did not contain expected alg (%s)`, alg)
+		}
+		jws, err := CompactSign(private_key, payload)
+		if err != nil {
+			t.Fatalf("Signing %s failed: %v", alg, err)
+		}
+		verified, verify_error := CompactVerify(public_key, jws)
+		if verify_error != nil {
+			t.Fatalf("Verification %s failed: %v", alg, verify_error)
+		}
+		if verified.Header.Alg != alg {
+			t.Fatalf("Invalid Header Algorithm")
+		}
+		if string(verified.Payload) != string(payload) {
+			t.Fatalf("Invalid Signature")
+		}
+		tbs := ToBeSignedFromJWS(jws)
+		sig, _ := SignatureFromJWS(jws)
+		pub, _ := base64.RawURLEncoding.DecodeString(key.Pub)
+		examples, _ := json.MarshalIndent(JOSETestVector{
+			Priv:   hex.EncodeToString(seed[:]),
+			Jwk:    key,
+			Jws:    jws,
+			RawTbs: hex.EncodeToString(tbs),
+			RawSig: hex.EncodeToString(sig),
+			RawPub: hex.EncodeToString(pub),
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".jose.json", examples, 0644)
+	}
+}
+
+// TestHashMLDSACrossVerification confirms HashML-DSA and ML-DSA signatures
+// made with the same seed never verify as each other
+func TestHashMLDSACrossVerification(t *testing.T) {
+	for hash_alg, pure_alg := range hash_ml_dsa_algorithms {
+		hash_private_key, _ := GenerateKey(hash_alg, seed[:])
+		hash_public_key, _ := PublicKeyFromPrivateKey(hash_private_key)
+		pure_private_key, _ := GenerateKey(pure_alg, seed[:])
+		pure_public_key, _ := PublicKeyFromPrivateKey(pure_private_key)
+		hash_jws, _ := CompactSign(hash_private_key, payload)
+		pure_jws, _ := CompactSign(pure_private_key, payload)
+		_, err := CompactVerify(pure_public_key, hash_jws)
+		if err == nil {
+			t.Fatalf("%s signature verified with ML-DSA key", hash_alg)
+		}
+		_, err = CompactVerify(hash_public_key, pure_jws)
+		if err == nil {
+			t.Fatalf("ML-DSA signature verified with %s key", hash_alg)
+		}
+
+		// the same key material and signing input, with only the algorithm
+		// changed
+		suite, pub, priv, _ := SuiteFromJWK(pure_private_key)
+		tbs := ToBeSignedFromJWS(pure_jws)
+		hash_sig, _ := signWithOptions(hash_alg, suite, priv, tbs, signOptions{})
+		pure_sig, _ := signWithOptions(pure_alg, suite, priv, tbs, signOptions{})
+		if verifyWithContext(pure_alg, suite, pub, tbs, hash_sig, nil) {
+			t.Fatalf("%s signature verified as ML-DSA", hash_alg)
+		}
+		if verifyWithContext(hash_alg, suite, pub, tbs, pure_sig, nil) {
+			t.Fatalf("ML-DSA signature verified as %s", hash_alg)
+		}
+		if !verifyWithContext(hash_alg, suite, pub, tbs, hash_sig, nil) || !verifyWithContext(pure_alg, suite, pub, tbs, pure_sig, nil) {
+			t.Fatalf("Signatures do not verify with their own algorithm")
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc7797#section-3
+const HEADER_B64 = "b64"
+
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1.11
+const HEADER_CRITICAL = "crit"
+
+// registered_header_parameters are defined by JWS, and must not be listed
+// in crit.
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1
+var registered_header_parameters = []string{"alg", "jku", "jwk", "kid", "x5u", "x5c", "x5t", "x5t#S256", "typ", "cty", HEADER_CRITICAL}
+
+// understood_critical are the extension header parameters this package
+// implements, and accepts in crit.
+var understood_critical = []string{HEADER_B64}
+
+// JWSHeader is a JOSE Header. Header parameters without a field are kept
+// in Extra as raw JSON.
+type JWSHeader struct {
+	Alg  string   `json:"alg,omitempty"`
+	Kid  string   `json:"kid,omitempty"`
+	Typ  string   `json:"typ,omitempty"`
+	Cty  string   `json:"cty,omitempty"`
+	Ctx  string   `json:"x-ml-dsa-ctx,omitempty"`
+	B64  *bool    `json:"b64,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+	// Extra holds the other header parameters, such as jwk or x5c
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// jwsHeaderFields marshals the fields of a JWSHeader without its methods.
+type jwsHeaderFields JWSHeader
+
+// fields returns pointers to the typed fields, by header parameter name.
+func (h *JWSHeader) fields() map[string]any {
+	return map[string]any{
+		"alg":                       &h.Alg,
+		"kid":                       &h.Kid,
+		"typ":                       &h.Typ,
+		"cty":                       &h.Cty,
+		HEADER_EXPERIMENTAL_CONTEXT: &h.Ctx,
+		HEADER_B64:                  &h.B64,
+		HEADER_CRITICAL:             &h.Crit,
+	}
+}
+
+func (h JWSHeader) MarshalJSON() ([]byte, error) {
+	encoded, err := json.Marshal(jwsHeaderFields(h))
+	if err != nil || len(h.Extra) == 0 {
+		return encoded, err
+	}
+	fields := h.fields()
+	names := make([]string, 0, len(h.Extra))
+	for name := range h.Extra {
+		if _, typed := fields[name]; typed {
+			return nil, errors.New("Header parameter must be set with its field: " + name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	encoded = encoded[:len(encoded)-1]
+	for _, name := range names {
+		if len(encoded) > 1 {
+			encoded = append(encoded, ',')
+		}
+		encoded_name, _ := json.Marshal(name)
+		encoded = append(append(encoded, encoded_name...), ':')
+		encoded = append(encoded, h.Extra[name]...)
+	}
+	return append(encoded, '}'), nil
+}
+
+// UnmarshalJSON decodes the typed fields by their exact member names, as
+// JSON member names are case sensitive.
+func (h *JWSHeader) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	err := json.Unmarshal(data, &members)
+	if err != nil || members == nil {
+		return errors.New("JOSE Header must be a JSON object")
+	}
+	*h = JWSHeader{}
+	fields := h.fields()
+	for name, value := range members {
+		field, typed := fields[name]
+		if !typed {
+			if h.Extra == nil {
+				h.Extra = map[string]json.RawMessage{}
+			}
+			h.Extra[name] = value
+			continue
+		}
+		err = json.Unmarshal(value, field)
+		if err != nil {
+			return errors.New("Malformed header parameter: " + name)
+		}
+	}
+	return nil
+}
+
+// Has reports whether the header parameter is present.
+func (h JWSHeader) Has(name string) bool {
+	switch name {
+	case "alg":
+		return h.Alg != ""
+	case "kid":
+		return h.Kid != ""
+	case "typ":
+		return h.Typ != ""
+	case "cty":
+		return h.Cty != ""
+	case HEADER_EXPERIMENTAL_CONTEXT:
+		return h.Ctx != ""
+	case HEADER_B64:
+		return h.B64 != nil
+	case HEADER_CRITICAL:
+		return h.Crit != nil
+	}
+	_, exists := h.Extra[name]
+	return exists
+}
+
+// unencoded reports whether the header has "b64": false.
+// see: https://datatracker.ietf.org/doc/html/rfc7797#section-3
+func (h JWSHeader) unencoded() bool {
+	return h.B64 != nil && !*h.B64
+}
+
+// validCritical checks crit lists only extension header parameters that
+// are present, and that b64 is listed when it is used.
+func validCritical(header JWSHeader) error {
+	if header.Crit != nil && len(header.Crit) == 0 {
+		return errors.New("Critical header parameter must be a non-empty array of names")
+	}
+	for _, name := range header.Crit {
+		if slices.Contains(registered_header_parameters, name) {
+			return errors.New("Critical header parameter is defined by JWS: " + name)
+		}
+		if !header.Has(name) {
+			return errors.New("Critical header parameter is missing: " + name)
+		}
+	}
+	if header.B64 != nil && !slices.Contains(header.Crit, HEADER_B64) {
+		return errors.New("b64 header parameter must be listed in crit")
+	}
+	return nil
+}
+
+// checkCritical rejects protected headers with critical header parameters
+// that are not understood by this package or the caller.
+func checkCritical(header JWSHeader, o verifyOptions) error {
+	err := validCritical(header)
+	if err != nil {
+		return err
+	}
+	for _, name := range header.Crit {
+		if !slices.Contains(understood_critical, name) && !slices.Contains(o.critical, name) {
+			return errors.New("Critical header parameter is not understood: " + name)
+		}
+	}
+	return nil
+}
+
+func decodeHeader(encoded_header string) (JWSHeader, error) {
+	var header JWSHeader
+	decoded_header, err := base64.RawURLEncoding.DecodeString(encoded_header)
+	if err != nil {
+		return header, errors.New("JWS Header is not encoded as base64url")
+	}
+	err = json.Unmarshal(decoded_header, &header)
+	if err != nil {
+		return header, errors.New("Failed to parse JWS header")
+	}
+	return header, nil
+}
+
+// decodeProtectedHeader decodes a JWS Protected Header, and checks its
+// critical header parameters.
+func decodeProtectedHeader(encoded_header string, o verifyOptions) (JWSHeader, error) {
+	header, err := decodeHeader(encoded_header)
+	if err != nil {
+		return header, err
+	}
+	return header, checkCritical(header, o)
+}
+
+// headerForSigning combines the header members supplied by the caller
+// with the algorithm and key identifier of the private key.
+func headerForSigning(key *jwkPrivateKey, o signOptions) (JWSHeader, error) {
+	header := o.header
+	if header.Alg != "" && header.Alg != key.alg {
+		return header, errors.New("Header algorithm does not match the key algorithm")
+	}
+	if header.Ctx != "" || header.B64 != nil {
+		return header, errors.New("Experimental context and b64 header parameters are set with sign options")
+	}
+	header.Alg = key.alg
+	if header.Kid == "" {
+		header.Kid = key.kid
+	}
+	header.Ctx = base64.RawURLEncoding.EncodeToString(o.ctx)
+	if o.unencoded {
+		b64 := false
+		header.B64 = &b64
+		if !slices.Contains(header.Crit, HEADER_B64) {
+			header.Crit = append(slices.Clip(header.Crit), HEADER_B64)
+		}
+	}
+	return header, validCritical(header)
+}
@@ -0,0 +1,103 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+// TestCompactSignHeader calls jose.CompactSign with caller supplied header
+// members, including members that are not strings, and confirms
+// jose.CompactVerify returns them
+func TestCompactSignHeader(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_65, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	header := JWSHeader{
+		Typ: "example+jwt",
+		Cty: "text/plain",
+		Extra: map[string]json.RawMessage{
+			"jwk": json.RawMessage(public_key),
+			"x5c": json.RawMessage(`["MIIB"]`),
+		},
+	}
+	jws, err := CompactSign(private_key, payload, WithHeader(header))
+	if err != nil {
+		t.Fatalf("Signing with header members failed: %v", err)
+	}
+	verified, err := CompactVerify(public_key, jws)
+	if err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	if verified.Header.Alg != ML_DSA_65 || verified.Header.Kid != key.Kid || verified.Header.Typ != header.Typ || verified.Header.Cty != header.Cty {
+		t.Fatalf("Invalid header: %+v", verified.Header)
+	}
+	if string(verified.Header.Extra["x5c"]) != `["MIIB"]` || !verified.Header.Has("jwk") {
+		t.Fatalf("Header members that are not strings were not kept")
+	}
+
+	jws, _ = CompactSign(private_key, payload, WithHeader(JWSHeader{Kid: "other"}))
+	verified, _ = CompactVerify(public_key, jws)
+	if verified.Header.Kid != "other" {
+		t.Fatalf("Key identifier from the header was not used")
+	}
+	for name, header := range map[string]JWSHeader{
+		"another algorithm":   {Alg: ML_DSA_44},
+		"experimental ctx":    {Ctx: "AA"},
+		"unlisted critical":   {Crit: []string{"x-missing"}},
+		"registered critical": {Typ: "JWT", Crit: []string{"typ"}},
+		"typed extra member":  {Extra: map[string]json.RawMessage{"alg": json.RawMessage(`"ML-DSA-44"`)}},
+	} {
+		_, err = CompactSign(private_key, payload, WithHeader(header))
+		if err == nil {
+			t.Fatalf("Signed with %s", name)
+		}
+	}
+}
+
+// TestHeaderJSON confirms JOSE Headers round trip through JSON, that member
+// names are case sensitive, and that malformed typed members are rejected
+func TestHeaderJSON(t *testing.T) {
+	const encoded = `{"alg":"ML-DSA-44","kid":"1","typ":"JWT","b64":false,"crit":["b64"],"ALG":"none","jwk":{"kty":"AKP"},"n":1}`
+	var header JWSHeader
+	err := json.Unmarshal([]byte(encoded), &header)
+	if err != nil {
+		t.Fatalf("Failed to parse header: %v", err)
+	}
+	if header.Alg != ML_DSA_44 || string(header.Extra["ALG"]) != `"none"` || !header.unencoded() {
+		t.Fatalf("Invalid header: %+v", header)
+	}
+	round_trip, _ := json.Marshal(header)
+	if string(round_trip) != encoded {
+		t.Fatalf("Header does not round trip: %s", round_trip)
+	}
+	for _, malformed := range []string{`[]`, `null`, `{"alg":1}`, `{"b64":"false"}`, `{"crit":"b64"}`} {
+		err = json.Unmarshal([]byte(malformed), &header)
+		if err == nil {
+			t.Fatalf("Parsed malformed header %s", malformed)
+		}
+	}
+}
+
+// TestAcceptCritical confirms extension header parameters in crit are
+// rejected unless the caller accepts them, and header parameters defined
+// by JWS are never accepted in crit
+func TestAcceptCritical(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	encoded_payload := base64.RawURLEncoding.EncodeToString(payload)
+	extension := signWithHeader(private_key, `{"alg":"ML-DSA-44","exp":1,"crit":["exp"]}`, encoded_payload)
+	_, err := CompactVerify(public_key, extension)
+	if err == nil {
+		t.Fatalf("Verified a JWS with a critical header parameter that is not understood")
+	}
+	verified, err := CompactVerify(public_key, extension, AcceptCritical("exp"))
+	if err != nil || string(verified.Header.Extra["exp"]) != "1" {
+		t.Fatalf("Accepted critical header parameter was rejected: %v", err)
+	}
+	registered := signWithHeader(private_key, `{"alg":"ML-DSA-44","typ":"JWT","crit":["typ"]}`, encoded_payload)
+	_, err = CompactVerify(public_key, registered, AcceptCritical("typ"))
+	if err == nil {
+		t.Fatalf("Verified a JWS listing a JWS header parameter in crit")
+	}
+}
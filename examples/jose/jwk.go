@@ -5,10 +5,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"fmt"
 
 	"github.com/cloudflare/circl/sign"
-	"github.com/cloudflare/circl/sign/schemes"
 )
 
 const (
@@ -25,8 +23,15 @@ type AKPKey struct {
 	Priv string `json:"priv"`
 }
 
-func GenerateKey(alg string, seed []byte) (string, error) {
-	suite := schemes.ByName(alg)
+func GenerateKey(alg string, seed []byte, opts ...KeyOption) (string, error) {
+	o := newKeyOptions(opts)
+	suite := SuiteFromAlgorithm(alg)
+	if suite == nil {
+		return "", errors.New("Unknown algorithm")
+	}
+	if len(seed) != suite.SeedSize() {
+		return "", errors.New("Seed has the wrong length for the algorithm")
+	}
 	pub, _ := suite.DeriveKey(seed[:])
 	pub_bytes, _ := pub.MarshalBinary()
 	jwk, err := json.Marshal(AKPKey{
@@ -36,6 +41,9 @@ func GenerateKey(alg string, seed []byte) (string, error) {
 		Priv: base64.RawURLEncoding.EncodeToString(seed[:]),
 	})
 	kid, _ := CalculateJwkThumbprint(string(jwk))
+	if o.thumbprint_uri {
+		kid = JwkThumbprintURI(kid)
+	}
 	jwk_with_thumbprint, err := json.Marshal(AKPKey{
 		Kid:  kid,
 		Kty:  "AKP",
@@ -52,52 +60,52 @@ func DecodeKey(jwk string) (AKPKey, error) {
 	return key, err
 }
 
+// PublicKeyFromPrivateKey exports the public key of a JWK, keeping every
+// member that is not private, such as kid, use and key_ops.
 func PublicKeyFromPrivateKey(jwk string) (string, error) {
-	var key map[string]string
-	err := json.Unmarshal([]byte(jwk), &key)
+	key, kty, err := decodeJWK(jwk)
 	if err != nil {
-		return "", errors.New("Failed to parse JSON")
+		return "", err
+	}
+	private_members, asymmetric := private_jwk_members[kty]
+	if !asymmetric {
+		return "", errors.New("JWK key type has no public key")
 	}
-	var public_key = fmt.Sprintf(`{"kty":"%s","alg":"%s","pub":"%s"}`, key["kty"], key["alg"], key["pub"])
-	return public_key, err
+	for _, name := range private_members {
+		delete(key, name)
+	}
+	return string(canonicalJSON(key)), nil
 }
 
 func SuiteFromJWK(jwk string) (sign.Scheme, sign.PublicKey, sign.PrivateKey, error) {
-	var key map[string]string
-	err := json.Unmarshal([]byte(jwk), &key)
+	key, _, err := decodeJWK(jwk)
 	if err != nil {
-		return nil, nil, nil, errors.New("Failed to parse JSON")
+		return nil, nil, nil, err
 	}
-	suite := schemes.ByName(key["alg"])
-	if key["priv"] != "" {
-		suite := schemes.ByName(key["alg"])
-		seed, err := base64.RawURLEncoding.DecodeString(key["priv"])
+	if _, private := key["priv"]; private {
+		private_key, err := parsePrivateKey(jwk)
 		if err != nil {
-			return nil, nil, nil, errors.New("Failed to decode jwk.priv, malformed priv")
+			return nil, nil, nil, err
 		}
-		pub, priv := suite.DeriveKey(seed[:])
-		return suite, pub, priv, nil
+		return private_key.suite, private_key.key.Public().(sign.PublicKey), private_key.key, nil
 	}
-	binary_pub, err := base64.RawURLEncoding.DecodeString(key["pub"])
-	pub, _ := suite.UnmarshalBinaryPublicKey(binary_pub)
-	return suite, pub, nil, nil
+	public_key, err := parsePublicKey(jwk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return public_key.suite, public_key.key, nil, nil
 }
 
 // see: https://datatracker.ietf.org/doc/html/rfc7638
 func CalculateJwkThumbprint(jwk string) (string, error) {
-	var key map[string]string
-	err := json.Unmarshal([]byte(jwk), &key)
+	key, kty, err := decodeJWK(jwk)
 	if err != nil {
-		return "", errors.New("Failed to parse JSON")
+		return "", err
 	}
-	h := sha256.New()
-	switch kty := key["kty"]; kty {
-	case "EC":
-		h.Write([]byte(fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, key["crv"], key["kty"], key["x"], key["y"])))
-	case "AKP":
-		h.Write([]byte(fmt.Sprintf(`{"alg":"%s","kty":"%s","pub":"%s"}`, key["alg"], key["kty"], key["pub"])))
-	default:
-		return "", errors.New(`Unknown JWK key type (kty)`)
+	members := map[string]any{}
+	for _, name := range required_jwk_members[kty] {
+		members[name] = key[name]
 	}
-	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+	digest := sha256.Sum256(canonicalJSON(members))
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
 }
@@ -0,0 +1,323 @@
+package jose
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// see: https://www.iana.org/assignments/media-types/application/jwk-set+json
+const JWK_SET_MEDIA_TYPE = "application/jwk-set+json"
+
+const (
+	// JWKS_DEFAULT_MAX_AGE is how long a JWK Set is cached when the response
+	// has no max-age
+	JWKS_DEFAULT_MAX_AGE = 5 * time.Minute
+	// JWKS_MIN_REFRESH_INTERVAL limits how often an unknown kid causes the
+	// JWK Set to be fetched again
+	JWKS_MIN_REFRESH_INTERVAL = 30 * time.Second
+	// jwks_max_size limits the size of a fetched JWK Set
+	jwks_max_size = 1 << 20
+)
+
+// JWKSHandler serves a JWK Set of public keys, with an ETag so clients can
+// revalidate their cached copy.
+type JWKSHandler struct {
+	max_age time.Duration
+	mu      sync.RWMutex
+	body    []byte
+	etag    string
+}
+
+// NewJWKSHandler serves the public keys, as produced by
+// PublicKeyFromPrivateKey, cached by clients for max_age.
+func NewJWKSHandler(max_age time.Duration, public_keys ...string) (*JWKSHandler, error) {
+	h := &JWKSHandler{max_age: max_age}
+	err := h.Update(public_keys...)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Update replaces the served public keys, for example when keys rotate.
+func (h *JWKSHandler) Update(public_keys ...string) error {
+	keys := make([]json.RawMessage, len(public_keys))
+	for i, public_key := range public_keys {
+		jwk, kty, err := decodeJWK(public_key)
+		if err != nil {
+			return err
+		}
+		private_members, asymmetric := private_jwk_members[kty]
+		if !asymmetric {
+			return errors.New("JWK Set cannot contain a symmetric key")
+		}
+		for _, name := range private_members {
+			if _, private := jwk[name]; private {
+				return errors.New("JWK Set cannot contain a private key")
+			}
+		}
+		keys[i] = json.RawMessage(public_key)
+	}
+	body, err := json.Marshal(struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{Keys: keys})
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(body)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.body = body
+	h.etag = `"` + base64.RawURLEncoding.EncodeToString(digest[:]) + `"`
+	return nil
+}
+
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.mu.RLock()
+	body, etag := h.body, h.etag
+	h.mu.RUnlock()
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(h.max_age/time.Second)))
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", JWK_SET_MEDIA_TYPE)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(body)
+}
+
+// etagMatches uses the weak comparison of If-None-Match.
+// see: https://datatracker.ietf.org/doc/html/rfc9110#section-13.1.2
+func etagMatches(if_none_match string, etag string) bool {
+	for _, candidate := range strings.Split(if_none_match, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// maxAge returns how long a response may be cached from its Cache-Control
+// header.
+func maxAge(cache_control string, default_max_age time.Duration) time.Duration {
+	max_age := default_max_age
+	for _, directive := range strings.Split(cache_control, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0
+		case "max-age":
+			seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+			if err == nil && seconds >= 0 {
+				max_age = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return max_age
+}
+
+// JWKSClient is a KeyResolver for a remote JWK Set. The set is cached for
+// the max-age of the response, and fetched again when a kid is unknown, at
+// most once per refresh interval. Fetches happen without holding the lock,
+// so resolving from the cached set is not blocked by a slow server.
+type JWKSClient struct {
+	url             string
+	client          *http.Client
+	default_max_age time.Duration
+	min_refresh     time.Duration
+	now             func() time.Time
+
+	mu        sync.Mutex
+	keys      []string
+	etag      string
+	attempted time.Time
+	expires   time.Time
+	err       error
+	fetching  *jwksFetch
+}
+
+// jwksFetch is a fetch in flight, which concurrent refreshes wait for
+// instead of fetching again.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+type JWKSClientOption func(*JWKSClient)
+
+// WithHTTPClient fetches the JWK Set with client instead of a client with
+// a 10 second timeout.
+func WithHTTPClient(client *http.Client) JWKSClientOption {
+	return func(c *JWKSClient) {
+		c.client = client
+	}
+}
+
+// WithMinRefreshInterval changes JWKS_MIN_REFRESH_INTERVAL.
+func WithMinRefreshInterval(interval time.Duration) JWKSClientOption {
+	return func(c *JWKSClient) {
+		c.min_refresh = interval
+	}
+}
+
+// WithDefaultMaxAge changes JWKS_DEFAULT_MAX_AGE.
+func WithDefaultMaxAge(max_age time.Duration) JWKSClientOption {
+	return func(c *JWKSClient) {
+		c.default_max_age = max_age
+	}
+}
+
+// WithJWKSClock replaces time.Now for cache expiry and rate limiting.
+func WithJWKSClock(now func() time.Time) JWKSClientOption {
+	return func(c *JWKSClient) {
+		c.now = now
+	}
+}
+
+func NewJWKSClient(url string, opts ...JWKSClientOption) *JWKSClient {
+	c := &JWKSClient{
+		url:             url,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		default_max_age: JWKS_DEFAULT_MAX_AGE,
+		min_refresh:     JWKS_MIN_REFRESH_INTERVAL,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Resolve returns the keys of the cached JWK Set matching kid and alg. A
+// stale JWK Set is used when it cannot be fetched again.
+func (c *JWKSClient) Resolve(kid string, alg string) ([]string, error) {
+	now := c.now()
+	c.mu.Lock()
+	expired := !now.Before(c.expires)
+	c.mu.Unlock()
+	if expired {
+		err := c.refresh(now)
+		if err != nil && c.cachedKeys() == nil {
+			return nil, err
+		}
+	}
+	candidates, err := resolveFromKeys(c.cachedKeys(), kid, alg)
+	if err != nil || len(candidates) != 0 || kid == "" {
+		return candidates, err
+	}
+	err = c.refresh(now)
+	if err != nil {
+		return nil, err
+	}
+	return resolveFromKeys(c.cachedKeys(), kid, alg)
+}
+
+func (c *JWKSClient) cachedKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.keys
+}
+
+// refresh fetches the JWK Set, unless a fetch was attempted within the
+// refresh interval, in which case the error of that fetch is returned when
+// nothing is cached. Concurrent refreshes share one fetch.
+func (c *JWKSClient) refresh(now time.Time) error {
+	c.mu.Lock()
+	if fetch := c.fetching; fetch != nil {
+		c.mu.Unlock()
+		<-fetch.done
+		return fetch.err
+	}
+	if !c.attempted.IsZero() && now.Sub(c.attempted) < c.min_refresh {
+		defer c.mu.Unlock()
+		if c.keys != nil {
+			return nil
+		}
+		return c.err
+	}
+	fetch := &jwksFetch{done: make(chan struct{})}
+	c.fetching = fetch
+	c.attempted = now
+	etag := ""
+	if c.keys != nil {
+		etag = c.etag
+	}
+	c.mu.Unlock()
+
+	keys, etag, max_age, err := c.fetch(etag)
+
+	c.mu.Lock()
+	switch {
+	case err != nil:
+	case keys != nil:
+		c.keys, c.etag = keys, etag
+		c.expires = now.Add(max_age)
+	case c.keys == nil:
+		err = errors.New("JWK Set was not modified, but is not cached")
+	default:
+		c.expires = now.Add(max_age)
+	}
+	c.err = err
+	c.fetching = nil
+	c.mu.Unlock()
+	fetch.err = err
+	close(fetch.done)
+	return err
+}
+
+// fetch requests the JWK Set, conditionally when etag is not empty. The
+// keys are nil when the JWK Set was not modified.
+func (c *JWKSClient) fetch(etag string) ([]string, string, time.Duration, error) {
+	request, err := http.NewRequest(http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	request.Header.Set("Accept", JWK_SET_MEDIA_TYPE+", application/json")
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer response.Body.Close()
+	max_age := maxAge(response.Header.Get("Cache-Control"), c.default_max_age)
+	switch response.StatusCode {
+	case http.StatusNotModified:
+		return nil, "", max_age, nil
+	case http.StatusOK:
+		body, err := io.ReadAll(io.LimitReader(response.Body, jwks_max_size+1))
+		if err != nil {
+			return nil, "", 0, err
+		}
+		if len(body) > jwks_max_size {
+			return nil, "", 0, errors.New("JWK Set is too large")
+		}
+		keys, err := parseKeySet(body)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return keys, response.Header.Get("ETag"), max_age, nil
+	default:
+		return nil, "", 0, fmt.Errorf("Failed to fetch JWK Set: %s", response.Status)
+	}
+}
@@ -0,0 +1,252 @@
+package jose
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestJWKSHandler serves public keys with jose.NewJWKSHandler and confirms
+// the JWK Set, caching headers and conditional requests
+func TestJWKSHandler(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	handler, err := NewJWKSHandler(time.Hour, public_key)
+	if err != nil {
+		t.Fatalf("Creating the JWKS handler failed: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Fetching the JWK Set failed: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != JWK_SET_MEDIA_TYPE || response.Header.Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("Invalid response %s %v", response.Status, response.Header)
+	}
+	if string(body) != `{"keys":[`+public_key+`]}` {
+		t.Fatalf("Invalid JWK Set %s", body)
+	}
+	etag := response.Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("JWK Set has no ETag")
+	}
+
+	for _, if_none_match := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		request.Header.Set("If-None-Match", if_none_match)
+		response, _ = http.DefaultClient.Do(request)
+		response.Body.Close()
+		if response.StatusCode != http.StatusNotModified {
+			t.Fatalf("Conditional request with %s returned %s", if_none_match, response.Status)
+		}
+	}
+	response, _ = http.Post(server.URL, "application/json", nil)
+	response.Body.Close()
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("POST returned %s", response.Status)
+	}
+
+	other_private_key, _ := GenerateKey(ML_DSA_65, seed[:])
+	other_public_key, _ := PublicKeyFromPrivateKey(other_private_key)
+	_ = handler.Update(public_key, other_public_key)
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("If-None-Match", etag)
+	response, _ = http.DefaultClient.Do(request)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("ETag") == etag {
+		t.Fatalf("Updated JWK Set was not served")
+	}
+	for _, private := range []string{
+		private_key,
+		`{"kty":"EC","crv":"P-256","x":"AA","y":"AQ","d":"Ag"}`,
+		`{"kty":"OKP","crv":"Ed25519","x":"AA","d":""}`,
+		`{"kty":"oct","k":"AA"}`,
+	} {
+		_, err = NewJWKSHandler(time.Hour, private)
+		if err == nil {
+			t.Fatalf("Served a private key %s", private)
+		}
+	}
+	with_key_ops, _ := PublicKeyFromPrivateKey(strings.Replace(private_key, "{", `{"key_ops":["verify"],"use":"sig",`, 1))
+	_, err = NewJWKSHandler(time.Hour, with_key_ops)
+	if err != nil || !strings.Contains(with_key_ops, `"key_ops":["verify"]`) {
+		t.Fatalf("Failed to serve a public key with key_ops: %v", err)
+	}
+}
+
+// TestJWKSClient resolves keys with jose.NewJWKSClient and confirms the
+// JWK Set is cached, revalidated when it expires, and fetched again for an
+// unknown kid at most once per refresh interval
+func TestJWKSClient(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	rotated_private_key, _ := GenerateKey(ML_DSA_44, large_payload[:32])
+	rotated_public_key, _ := PublicKeyFromPrivateKey(rotated_private_key)
+	rotated_key, _ := DecodeKey(rotated_private_key)
+	handler, _ := NewJWKSHandler(time.Hour, public_key)
+	var requests, conditional atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") != "" {
+			conditional.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	now := time.Unix(1700000000, 0)
+	client := NewJWKSClient(server.URL, WithJWKSClock(func() time.Time { return now }), WithHTTPClient(server.Client()))
+
+	jwt, _ := IssueJWT(private_key, jwt_claims)
+	_, err := VerifyJWT(client, jwt, jwt_validation)
+	if err != nil {
+		t.Fatalf("Verifying with the JWKS client failed: %v", err)
+	}
+	_, _ = VerifyJWT(client, jwt, jwt_validation)
+	if requests.Load() != 1 {
+		t.Fatalf("JWK Set was not cached, %d requests", requests.Load())
+	}
+
+	_ = handler.Update(public_key, rotated_public_key)
+	candidates, err := client.Resolve(rotated_key.Kid, ML_DSA_44)
+	if err != nil || len(candidates) != 0 || requests.Load() != 1 {
+		t.Fatalf("Unknown kid was fetched within the refresh interval")
+	}
+	now = now.Add(JWKS_MIN_REFRESH_INTERVAL)
+	candidates, err = client.Resolve(rotated_key.Kid, ML_DSA_44)
+	if err != nil || len(candidates) != 1 || requests.Load() != 2 {
+		t.Fatalf("Unknown kid did not fetch the rotated JWK Set: %v", err)
+	}
+	_, _ = client.Resolve("unknown", ML_DSA_44)
+	_, _ = client.Resolve("unknown", ML_DSA_44)
+	if requests.Load() != 2 {
+		t.Fatalf("Unknown kids were not rate limited, %d requests", requests.Load())
+	}
+
+	now = now.Add(time.Hour)
+	_, err = VerifyJWT(client, jwt, jwt_validation)
+	if err != nil || requests.Load() != 3 || conditional.Load() != 2 {
+		t.Fatalf("Expired JWK Set was not revalidated: %v", err)
+	}
+	server.Close()
+	now = now.Add(2 * time.Hour)
+	_, err = VerifyJWT(client, jwt, jwt_validation)
+	if err != nil {
+		t.Fatalf("Stale JWK Set was not used when the server is down: %v", err)
+	}
+}
+
+// TestJWKSClientRejected confirms the JWKS client fails without a cached
+// JWK Set when the response is an error or not a JWK Set
+func TestJWKSClientRejected(t *testing.T) {
+	for _, serve := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{"kty":"AKP"}`)) },
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotModified) },
+		func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(make([]byte, jwks_max_size+1)) },
+	} {
+		server := httptest.NewServer(serve)
+		_, err := NewJWKSClient(server.URL).Resolve("", ML_DSA_44)
+		server.Close()
+		if err == nil {
+			t.Fatalf("Resolved keys from an invalid response")
+		}
+	}
+}
+
+// TestJWKSClientBackoff confirms a failing JWK Set is not fetched again
+// within the refresh interval, even though nothing is cached
+func TestJWKSClientBackoff(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	now := time.Unix(1700000000, 0)
+	client := NewJWKSClient(server.URL, WithJWKSClock(func() time.Time { return now }))
+	for i := 0; i < 3; i++ {
+		_, err := client.Resolve("kid", ML_DSA_44)
+		if err == nil {
+			t.Fatalf("Resolved keys from a failing JWK Set")
+		}
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("Failing JWK Set was fetched %d times within the refresh interval", requests.Load())
+	}
+	now = now.Add(JWKS_MIN_REFRESH_INTERVAL)
+	_, _ = client.Resolve("kid", ML_DSA_44)
+	if requests.Load() != 2 {
+		t.Fatalf("Failing JWK Set was not fetched again after the refresh interval")
+	}
+}
+
+// TestJWKSClientConcurrent confirms keys resolve from the cached JWK Set
+// while a slow fetch is in flight, and concurrent refreshes share it
+func TestJWKSClientConcurrent(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	key, _ := DecodeKey(private_key)
+	handler, _ := NewJWKSHandler(time.Hour, public_key)
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			<-release
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	now := time.Unix(1700000000, 0)
+	client := NewJWKSClient(server.URL, WithJWKSClock(func() time.Time { return now }), WithMinRefreshInterval(0))
+	_, _ = client.Resolve(key.Kid, ML_DSA_44)
+
+	unknown := make(chan error, 4)
+	for i := 0; i < cap(unknown); i++ {
+		go func() {
+			_, err := client.Resolve("unknown", ML_DSA_44)
+			unknown <- err
+		}()
+	}
+	for requests.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	candidates, err := client.Resolve(key.Kid, ML_DSA_44)
+	if err != nil || len(candidates) != 1 {
+		t.Fatalf("Cached key did not resolve during a fetch: %v", err)
+	}
+	// let the other refreshes join the fetch in flight
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for i := 0; i < cap(unknown); i++ {
+		<-unknown
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("Concurrent refreshes made %d requests", requests.Load())
+	}
+}
+
+// TestMaxAge confirms Cache-Control directives set the cache lifetime
+func TestMaxAge(t *testing.T) {
+	for cache_control, expected := range map[string]time.Duration{
+		"":                              time.Minute,
+		"public, max-age=600":           10 * time.Minute,
+		"max-age=600, no-cache":         0,
+		"no-store":                      0,
+		"max-age=-1":                    time.Minute,
+		`Max-Age="30", must-revalidate`: 30 * time.Second,
+	} {
+		if max_age := maxAge(cache_control, time.Minute); max_age != expected {
+			t.Fatalf("Max age of %q is %s, want %s", cache_control, max_age, expected)
+		}
+	}
+}
@@ -1,32 +1,39 @@
 package jose
 
 import (
+	crypto_rand "crypto/rand"
+	"crypto/sha512"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"slices"
 	"strings"
 
-	"github.com/cloudflare/circl/sign/schemes"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/mldsa"
 )
 
-type JWSHeader struct {
-	Alg string `json:"alg"`
-	Kid string `json:"kid"`
-}
-
 type JWSVerification struct {
-	Header  map[string]string
+	Header  JWSHeader
 	Payload []byte
 }
 
+// ToBeSignedFromJWS returns the JWS Signing Input of a JWS in compact
+// serialization, or nil when it does not have three components.
 func ToBeSignedFromJWS(jws string) []byte {
 	components := strings.Split(jws, ".")
+	if len(components) != 3 {
+		return nil
+	}
 	var to_be_signed_bytes = []byte(components[0] + "." + components[1])
 	return to_be_signed_bytes
 }
 
 func SignatureFromJWS(jws string) ([]byte, error) {
 	components := strings.Split(jws, ".")
+	if len(components) != 3 {
+		return nil, errors.New("JWS must have three components")
+	}
 	sig, err := base64.RawURLEncoding.DecodeString(components[2])
 	if err != nil {
 		return nil, errors.New("Failed to decode signature from JWS")
@@ -34,70 +41,226 @@ func SignatureFromJWS(jws string) ([]byte, error) {
 	return sig, nil
 }
 
-func CompactSign(private_key string, payload []byte) (string, error) {
-	var jwk map[string]string
-	err := json.Unmarshal([]byte(private_key), &jwk)
-	if err != nil {
-		return "", errors.New("Failed to parse jwk private key")
+func CompactSign(private_key string, payload []byte, opts ...SignOption) (string, error) {
+	o := newSignOptions(opts)
+	var encoded_payload = base64.RawURLEncoding.EncodeToString(payload)
+	if o.unencoded {
+		if !o.detached && strings.Contains(string(payload), ".") {
+			return "", errors.New("Unencoded payload containing '.' must be detached")
+		}
+		encoded_payload = string(payload)
 	}
-	// caution, this assumes circl and JOSE / COSE alg names are the name.
-	suite := schemes.ByName(jwk["alg"])
-	seed, err := base64.RawURLEncoding.DecodeString(jwk["priv"])
+	encoded_header, encoded_signature, err := signJWS(private_key, encoded_payload, o)
 	if err != nil {
-		return "", errors.New("Failed to decode jwk.priv, malformed priv")
-	}
-	_, priv := suite.DeriveKey(seed[:])
-	var header, _ = json.Marshal(JWSHeader{
-		Alg: jwk["alg"],
-		Kid: jwk["kid"],
-	})
-	var to_be_signed_bytes = ToBeSignedFromJWS(base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload))
-	var signature = suite.Sign(priv, to_be_signed_bytes, nil)
-	var encoded_signature = base64.RawURLEncoding.EncodeToString(signature)
-	var jws = string(to_be_signed_bytes) + "." + encoded_signature
+		return "", err
+	}
+	if o.detached {
+		encoded_payload = ""
+	}
+	var jws = encoded_header + "." + encoded_payload + "." + encoded_signature
 	return jws, nil
 }
 
-func CompactVerify(public_key string, jws string) (JWSVerification, error) {
-	var payload []byte
-	var verified = JWSVerification{}
-	var jwk map[string]string
-	err := json.Unmarshal([]byte(public_key), &jwk)
+// signJWS returns the encoded protected header and signature for the
+// payload, as it appears in the JWS Signing Input.
+func signJWS(private_key string, encoded_payload string, o signOptions) (string, string, error) {
+	key, err := parsePrivateKey(private_key)
+	if err != nil {
+		return "", "", err
+	}
+	err = checkContext(o.ctx)
+	if err != nil {
+		return "", "", err
+	}
+	jws_header, err := headerForSigning(key, o)
+	if err != nil {
+		return "", "", err
+	}
+	header, err := json.Marshal(jws_header)
 	if err != nil {
-		return verified, errors.New("Failed to parse jwk private key")
+		return "", "", err
 	}
-	if jwk["seed"] != "" {
-		return verified, errors.New("CompactVerify cannot be called with a private key")
+	var encoded_header = base64.RawURLEncoding.EncodeToString(header)
+	var to_be_signed_bytes = []byte(encoded_header + "." + encoded_payload)
+	signature, err := signWithOptions(key.alg, key.suite, key.key, to_be_signed_bytes, o)
+	if err != nil {
+		return "", "", err
+	}
+	return encoded_header, base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func signWithOptions(alg string, suite sign.Scheme, priv sign.PrivateKey, to_be_signed_bytes []byte, o signOptions) ([]byte, error) {
+	var rnd [32]byte
+	if o.hedged {
+		var rand = o.rand
+		if rand == nil {
+			rand = crypto_rand.Reader
+		}
+		var err error
+		rnd, err = mldsa.Randomness(rand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if IsHashMLDSA(alg) {
+		digest := sha512.Sum512(to_be_signed_bytes)
+		return mldsa.Sign(priv, mldsa.PreHashSHA512(o.ctx, digest[:]), rnd)
+	}
+	if o.hedged {
+		return mldsa.Sign(priv, mldsa.Pure(o.ctx, to_be_signed_bytes), rnd)
+	}
+	return suite.Sign(priv, to_be_signed_bytes, &sign.SignatureOpts{Context: string(o.ctx)}), nil
+}
+
+func verifyWithContext(alg string, suite sign.Scheme, pub sign.PublicKey, to_be_signed_bytes []byte, signature []byte, ctx []byte) bool {
+	if IsHashMLDSA(alg) {
+		digest := sha512.Sum512(to_be_signed_bytes)
+		valid, _ := mldsa.Verify(pub, mldsa.PreHashSHA512(ctx, digest[:]), signature)
+		return valid
+	}
+	return suite.Verify(pub, to_be_signed_bytes, signature, &sign.SignatureOpts{Context: string(ctx)})
+}
+
+// jwkPublicKey is a parsed public key, that can be shared between
+// concurrent verifications.
+type jwkPublicKey struct {
+	alg   string
+	suite sign.Scheme
+	key   sign.PublicKey
+}
+
+func publicKeyFromJWK(alg string, encoded_pub string) (*jwkPublicKey, error) {
+	suite := SuiteFromAlgorithm(alg)
+	if suite == nil {
+		return nil, errors.New("Unknown algorithm")
+	}
+	pub, err := base64.RawURLEncoding.DecodeString(encoded_pub)
+	if err != nil {
+		return nil, errors.New("Failed to decode jwk.pub, malformed pub")
 	}
-	// caution, this assumes circl and JOSE / COSE alg names are the name.
-	suite := schemes.ByName(jwk["alg"])
-	pub, err := base64.RawURLEncoding.DecodeString(jwk["pub"])
 	suite_public_key, malformed_public_key_error := suite.UnmarshalBinaryPublicKey(pub)
 	if malformed_public_key_error != nil {
-		return verified, malformed_public_key_error
+		return nil, malformed_public_key_error
+	}
+	return &jwkPublicKey{
+		alg:   alg,
+		suite: suite,
+		key:   suite_public_key,
+	}, nil
+}
+
+// parsePublicKey parses an AKP public key for verification. Private keys
+// are rejected, as verification keys are often distributed.
+func parsePublicKey(public_key string) (*jwkPublicKey, error) {
+	jwk, kty, err := decodeJWK(public_key)
+	if err != nil {
+		return nil, err
+	}
+	if kty != "AKP" {
+		return nil, errors.New("Verification key must be an AKP key")
 	}
+	if _, private := jwk["priv"]; private {
+		return nil, errors.New("Verification key must not be a private key")
+	}
+	return publicKeyFromJWK(jwk["alg"].(string), jwk["pub"].(string))
+}
+
+// jwkPrivateKey is a parsed AKP private key.
+type jwkPrivateKey struct {
+	alg   string
+	kid   string
+	suite sign.Scheme
+	key   sign.PrivateKey
+}
+
+// parsePrivateKey parses an AKP private key for signing, members other
+// than kid, alg and priv are allowed but not used.
+func parsePrivateKey(private_key string) (*jwkPrivateKey, error) {
+	jwk, kty, err := decodeJWK(private_key)
+	if err != nil {
+		return nil, err
+	}
+	if kty != "AKP" {
+		return nil, errors.New("Signing key must be an AKP key")
+	}
+	alg := jwk["alg"].(string)
+	suite := SuiteFromAlgorithm(alg)
+	if suite == nil {
+		return nil, errors.New("Unknown algorithm")
+	}
+	encoded_seed, _ := jwk["priv"].(string)
+	seed, err := base64.RawURLEncoding.DecodeString(encoded_seed)
+	if err != nil || len(seed) != suite.SeedSize() {
+		return nil, errors.New("Failed to decode jwk.priv, malformed priv")
+	}
+	kid, valid := jwk["kid"].(string)
+	if _, exists := jwk["kid"]; exists && !valid {
+		return nil, errors.New("JWK kid must be a string")
+	}
+	_, priv := suite.DeriveKey(seed)
+	return &jwkPrivateKey{alg: alg, kid: kid, suite: suite, key: priv}, nil
+}
+
+func CompactVerify(public_key string, jws string, opts ...VerifyOption) (JWSVerification, error) {
+	key, err := parsePublicKey(public_key)
+	if err != nil {
+		return JWSVerification{}, err
+	}
+	return compactVerify(key, jws, newVerifyOptions(opts))
+}
+
+func compactVerify(key *jwkPublicKey, jws string, o verifyOptions) (JWSVerification, error) {
 	components := strings.Split(jws, ".")
-	var to_be_signed_bytes = ToBeSignedFromJWS(jws)
-	signature, signature_encoding_error := SignatureFromJWS(jws)
-	if signature_encoding_error != nil {
-		return verified, signature_encoding_error
+	if len(components) != 3 {
+		return JWSVerification{}, errors.New("JWS must have three components")
 	}
-	signature_match := suite.Verify(suite_public_key, to_be_signed_bytes, signature, nil)
-	if !signature_match {
-		return verified, errors.New("Signature not from public key")
+	return compactVerifyParts(key, components[0], components[1], components[2], o)
+}
+
+// checkAlgorithm rejects an algorithm outside the allowlist given with
+// AllowedAlgorithms, when there is one.
+func checkAlgorithm(o verifyOptions, alg string) error {
+	if len(o.algorithms) == 0 || slices.Contains(o.algorithms, alg) {
+		return nil
 	}
-	decoded_header, decode_header_error := base64.RawURLEncoding.DecodeString(components[0])
-	if decode_header_error != nil {
-		return verified, errors.New("JWS Header is not encoded as base64url")
+	return errors.New("JWS algorithm is not allowed: " + alg)
+}
+
+// compactVerifyParts verifies a JWS from its encoded header, the payload
+// as it appears in the JWS Signing Input, and its encoded signature.
+func compactVerifyParts(key *jwkPublicKey, encoded_header string, encoded_payload string, encoded_signature string, o verifyOptions) (JWSVerification, error) {
+	var verified = JWSVerification{}
+	signature, signature_encoding_error := base64.RawURLEncoding.DecodeString(encoded_signature)
+	if signature_encoding_error != nil {
+		return verified, errors.New("Failed to decode signature from JWS")
 	}
-	var header map[string]string
-	decode_header_error = json.Unmarshal(decoded_header, &header)
+	header, decode_header_error := decodeProtectedHeader(encoded_header, o)
 	if decode_header_error != nil {
-		return verified, errors.New("Failed to parse JWS header")
+		return verified, decode_header_error
+	}
+	if header.Alg != key.alg {
+		return verified, errors.New("JWS algorithm does not match the key algorithm")
+	}
+	err := checkAlgorithm(o, header.Alg)
+	if err != nil {
+		return verified, err
+	}
+	ctx, err := contextForVerification(o, header)
+	if err != nil {
+		return verified, err
+	}
+	var to_be_signed_bytes = []byte(encoded_header + "." + encoded_payload)
+	signature_match := verifyWithContext(key.alg, key.suite, key.key, to_be_signed_bytes, signature, ctx)
+	if !signature_match {
+		return verified, errors.New("Signature not from public key")
 	}
-	payload, decode_payload_error := base64.RawURLEncoding.DecodeString(components[1])
-	if decode_payload_error != nil {
-		return verified, errors.New("JWS Payload is not encoded as base64url")
+	payload := []byte(encoded_payload)
+	if !header.unencoded() {
+		var decode_payload_error error
+		payload, decode_payload_error = base64.RawURLEncoding.DecodeString(encoded_payload)
+		if decode_payload_error != nil {
+			return verified, errors.New("JWS Payload is not encoded as base64url")
+		}
 	}
 	verified.Header = header
 	verified.Payload = payload
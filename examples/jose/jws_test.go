@@ -1,10 +1,12 @@
 package jose
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/cloudflare/circl/sign/schemes"
@@ -53,11 +55,11 @@ func TestSign_0(t *testing.T) {
 	if verify_error != nil {
 		t.Fatalf("Verification failed")
 	}
-	if string(verified.Header["alg"]) != ML_DSA_44 {
+	if verified.Header.Alg != ML_DSA_44 {
 		t.Fatalf("Invalid Header Algorithm")
 	}
-	if string(verified.Header["kid"]) != "T4xl70S7MT6Zeq6r9V9fPJGVn76wfnXJ21-gyo0Gu6o" {
-		t.Fatalf("Invalid Header Key Identifier, want %s", verified.Header["kid"])
+	if verified.Header.Kid != "T4xl70S7MT6Zeq6r9V9fPJGVn76wfnXJ21-gyo0Gu6o" {
+		t.Fatalf("Invalid Header Key Identifier, want %s", verified.Header.Kid)
 	}
 	if string(verified.Payload) != string(payload) {
 		t.Fatalf("Invalid Signature")
@@ -94,11 +96,11 @@ func TestSign_1(t *testing.T) {
 	if verify_error != nil {
 		t.Fatalf("Verification failed")
 	}
-	if string(verified.Header["alg"]) != ML_DSA_65 {
+	if verified.Header.Alg != ML_DSA_65 {
 		t.Fatalf("Invalid Header Algorithm")
 	}
-	if string(verified.Header["kid"]) != "Suiu29qbfuaBaR4Ats-c6XQBePB_OpAxAwcTR_0KXVM" {
-		t.Fatalf("Invalid Header Key Identifier, want %s", verified.Header["kid"])
+	if verified.Header.Kid != "Suiu29qbfuaBaR4Ats-c6XQBePB_OpAxAwcTR_0KXVM" {
+		t.Fatalf("Invalid Header Key Identifier, want %s", verified.Header.Kid)
 	}
 	if string(verified.Payload) != string(payload) {
 		t.Fatalf("Invalid Signature")
@@ -135,11 +137,11 @@ func TestSign_2(t *testing.T) {
 	if verify_error != nil {
 		t.Fatalf("Verification failed")
 	}
-	if string(verified.Header["alg"]) != ML_DSA_87 {
+	if verified.Header.Alg != ML_DSA_87 {
 		t.Fatalf("Invalid Header Algorithm")
 	}
-	if string(verified.Header["kid"]) != "tRn1JNIkgMsABVQBlXeDHxAIcclh-2IX0UdDEzPt5XU" {
-		t.Fatalf("Invalid Header Key Identifier, want %s", verified.Header["kid"])
+	if verified.Header.Kid != "tRn1JNIkgMsABVQBlXeDHxAIcclh-2IX0UdDEzPt5XU" {
+		t.Fatalf("Invalid Header Key Identifier, want %s", verified.Header.Kid)
 	}
 	if string(verified.Payload) != string(payload) {
 		t.Fatalf("Invalid Signature")
@@ -157,3 +159,146 @@ func TestSign_2(t *testing.T) {
 	}, "", "  ")
 	_ = os.WriteFile("examples/ML_DSA_87.jose.json", examples, 0644)
 }
+
+// TestSignHedged calls jose.CompactSign with hedged signing and a fixed
+// source of randomness and confirms the result verifies and uses the
+// randomness
+func TestSignHedged(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_65, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	fixed_rand := bytes.Repeat([]byte{0x42}, 32)
+	deterministic, _ := CompactSign(private_key, payload)
+	hedged, err := CompactSign(private_key, payload, Hedged(bytes.NewReader(fixed_rand)))
+	if err != nil {
+		t.Fatalf("Hedged signing failed: %v", err)
+	}
+	_, verify_error := CompactVerify(public_key, hedged)
+	if verify_error != nil {
+		t.Fatalf("Verification failed")
+	}
+	if deterministic == hedged {
+		t.Fatalf("Hedged signature is deterministic")
+	}
+	repeated, _ := CompactSign(private_key, payload, Hedged(bytes.NewReader(fixed_rand)))
+	if hedged != repeated {
+		t.Fatalf("Hedged signature does not depend only on the injected randomness")
+	}
+	zero_rand, _ := CompactSign(private_key, payload, Hedged(bytes.NewReader(make([]byte, 32))))
+	if deterministic != zero_rand {
+		t.Fatalf("Hedged signature with zero randomness differs from deterministic signature")
+	}
+	unseeded, err := CompactSign(private_key, payload, Hedged(nil))
+	if err != nil {
+		t.Fatalf("Hedged signing with crypto/rand failed: %v", err)
+	}
+	_, verify_error = CompactVerify(public_key, unseeded)
+	if verify_error != nil {
+		t.Fatalf("Verification failed")
+	}
+	_, err = CompactSign(private_key, payload, Hedged(bytes.NewReader(fixed_rand[:16])))
+	if err == nil {
+		t.Fatalf("Hedged signing succeeded with short randomness")
+	}
+}
+
+// TestCompactVerifyRejected calls jose.CompactVerify with malformed JWS
+// and keys found while fuzzing, and confirms each returns an error
+func TestCompactVerifyRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	jws, _ := CompactSign(private_key, payload)
+	components := strings.Split(jws, ".")
+	for _, malformed := range []string{
+		"",
+		".",
+		"..",
+		"...",
+		"a.b",
+		jws + ".",
+		"." + components[1] + "." + components[2],
+		components[0] + ".." + components[2],
+		components[0] + "." + components[1] + ".",
+		components[0] + "." + components[1] + "." + components[2][1:],
+		components[0] + "." + components[1] + "!." + components[2],
+		base64.RawURLEncoding.EncodeToString([]byte(`null`)) + "." + components[1] + "." + components[2],
+		base64.RawURLEncoding.EncodeToString([]byte(`[]`)) + "." + components[1] + "." + components[2],
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":1}`)) + "." + components[1] + "." + components[2],
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + components[1] + ".",
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ML-DSA-65"}`)) + "." + components[1] + "." + components[2],
+	} {
+		_, err := CompactVerify(public_key, malformed)
+		if err == nil {
+			t.Fatalf("Verified malformed JWS %q", malformed)
+		}
+	}
+	for _, key := range []string{
+		"",
+		"null",
+		private_key,
+		strings.Replace(private_key, `"priv":"`, `"seed":"x","priv":"`, 1),
+		strings.Replace(public_key, ML_DSA_44, "ML-DSA-00", 1),
+		strings.Replace(public_key, `"pub":"`, `"pub":"!`, 1),
+		strings.Replace(public_key, `"kty":"AKP"`, `"kty":"OKP"`, 1),
+		`{"kty":"AKP","alg":"ML-DSA-44","pub":"AA"}`,
+		`{"kty":"AKP","alg":"ML-DSA-44","pub":"AA","priv":""}`,
+	} {
+		_, err := CompactVerify(key, jws)
+		if err == nil {
+			t.Fatalf("Verified with invalid key %q", key)
+		}
+	}
+	if ToBeSignedFromJWS("a.b") != nil {
+		t.Fatalf("Signing input of a malformed JWS")
+	}
+	_, err := SignatureFromJWS("a.b")
+	if err == nil {
+		t.Fatalf("Signature of a malformed JWS")
+	}
+}
+
+// TestAllowedAlgorithms calls jose.CompactVerify with jose.AllowedAlgorithms
+// and confirms only JWS with an allowed algorithm verify
+func TestAllowedAlgorithms(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	jws, _ := CompactSign(private_key, payload)
+	_, err := CompactVerify(public_key, jws, AllowedAlgorithms(ML_DSA_65, ML_DSA_44))
+	if err != nil {
+		t.Fatalf("Verification with an allowed algorithm failed: %v", err)
+	}
+	_, err = CompactVerify(public_key, jws, AllowedAlgorithms(ML_DSA_65, ML_DSA_87))
+	if err == nil {
+		t.Fatalf("Verified a JWS with an algorithm that is not allowed")
+	}
+	general, _ := GeneralSign(payload, GeneralSigner{PrivateKey: private_key})
+	_, err = GeneralVerify(NewStaticKeyResolver(public_key), general, AllowedAlgorithms(ML_DSA_65))
+	if err == nil {
+		t.Fatalf("Verified a general JWS with an algorithm that is not allowed")
+	}
+}
+
+// FuzzCompactVerify calls jose.CompactVerify with mutations of a JWS and
+// confirms it returns instead of panicking, and only verifies when the
+// signing input and signature are unchanged
+func FuzzCompactVerify(f *testing.F) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	jws, _ := CompactSign(private_key, payload)
+	for _, corpus := range []string{jws, "", "..", "a.b.c", "e30.." + "AA"} {
+		f.Add(corpus)
+	}
+	f.Fuzz(func(t *testing.T, mutated string) {
+		verified, err := CompactVerify(public_key, mutated)
+		if err != nil {
+			return
+		}
+		signature, _ := SignatureFromJWS(mutated)
+		original, _ := SignatureFromJWS(jws)
+		if !bytes.Equal(ToBeSignedFromJWS(mutated), ToBeSignedFromJWS(jws)) || !bytes.Equal(signature, original) {
+			t.Fatalf("Verified a modified JWS %q", mutated)
+		}
+		if !bytes.Equal(verified.Payload, payload) {
+			t.Fatalf("Verified an invalid payload")
+		}
+	})
+}
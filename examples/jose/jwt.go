@@ -0,0 +1,276 @@
+package jose
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc7519#section-4.1
+const (
+	JWT_CLAIM_ISS = "iss"
+	JWT_CLAIM_SUB = "sub"
+	JWT_CLAIM_AUD = "aud"
+	JWT_CLAIM_EXP = "exp"
+	JWT_CLAIM_NBF = "nbf"
+	JWT_CLAIM_IAT = "iat"
+	JWT_CLAIM_JTI = "jti"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc7519#section-5.1
+const JWT_TYPE = "JWT"
+
+// Claims is a JWT Claims Set. Zero values are omitted, a single audience is
+// encoded as a string. Custom claims are keyed by claim name.
+type Claims struct {
+	Issuer     string
+	Subject    string
+	Audience   []string
+	Expiration time.Time
+	NotBefore  time.Time
+	IssuedAt   time.Time
+	JWTID      string
+	Custom     map[string]any
+}
+
+type JWTValidation struct {
+	// Now defaults to time.Now
+	Now    func() time.Time
+	Leeway time.Duration
+	// Issuer and Audience are required to match when set. A JWT with an
+	// audience is rejected when no Audience is expected.
+	Issuer   string
+	Audience string
+	// Required are the names of claims that must be present
+	Required []string
+	// Type is the expected typ header parameter, when it is empty the typ
+	// must be JWT or absent
+	Type string
+}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	claims := map[string]any{}
+	for name, value := range c.Custom {
+		switch name {
+		case JWT_CLAIM_ISS, JWT_CLAIM_SUB, JWT_CLAIM_AUD, JWT_CLAIM_EXP, JWT_CLAIM_NBF, JWT_CLAIM_IAT, JWT_CLAIM_JTI:
+			return nil, errors.New("Custom JWT claims must not contain the registered claim " + name)
+		}
+		claims[name] = value
+	}
+	if c.Issuer != "" {
+		claims[JWT_CLAIM_ISS] = c.Issuer
+	}
+	if c.Subject != "" {
+		claims[JWT_CLAIM_SUB] = c.Subject
+	}
+	if len(c.Audience) == 1 {
+		claims[JWT_CLAIM_AUD] = c.Audience[0]
+	} else if len(c.Audience) > 1 {
+		claims[JWT_CLAIM_AUD] = c.Audience
+	}
+	if !c.Expiration.IsZero() {
+		claims[JWT_CLAIM_EXP] = c.Expiration.Unix()
+	}
+	if !c.NotBefore.IsZero() {
+		claims[JWT_CLAIM_NBF] = c.NotBefore.Unix()
+	}
+	if !c.IssuedAt.IsZero() {
+		claims[JWT_CLAIM_IAT] = c.IssuedAt.Unix()
+	}
+	if c.JWTID != "" {
+		claims[JWT_CLAIM_JTI] = c.JWTID
+	}
+	return json.Marshal(claims)
+}
+
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	var claims map[string]json.RawMessage
+	err := json.Unmarshal(data, &claims)
+	if err != nil || claims == nil {
+		return errors.New("Failed to decode JWT claims")
+	}
+	*c = Claims{}
+	for name, value := range claims {
+		var claim_error error
+		switch name {
+		case JWT_CLAIM_ISS:
+			claim_error = json.Unmarshal(value, &c.Issuer)
+		case JWT_CLAIM_SUB:
+			claim_error = json.Unmarshal(value, &c.Subject)
+		case JWT_CLAIM_AUD:
+			c.Audience, claim_error = decodeAudience(value)
+		case JWT_CLAIM_EXP:
+			c.Expiration, claim_error = decodeNumericDate(value)
+		case JWT_CLAIM_NBF:
+			c.NotBefore, claim_error = decodeNumericDate(value)
+		case JWT_CLAIM_IAT:
+			c.IssuedAt, claim_error = decodeNumericDate(value)
+		case JWT_CLAIM_JTI:
+			claim_error = json.Unmarshal(value, &c.JWTID)
+		default:
+			var custom any
+			claim_error = json.Unmarshal(value, &custom)
+			if c.Custom == nil {
+				c.Custom = map[string]any{}
+			}
+			c.Custom[name] = custom
+		}
+		if claim_error != nil {
+			return errors.New("Failed to decode JWT claim: " + name)
+		}
+	}
+	return nil
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc7519#section-4.1.3
+func decodeAudience(value json.RawMessage) ([]string, error) {
+	var audience string
+	if json.Unmarshal(value, &audience) == nil {
+		return []string{audience}, nil
+	}
+	var audiences []string
+	err := json.Unmarshal(value, &audiences)
+	return audiences, err
+}
+
+// max_numeric_date bounds NumericDates to integers a float64 represents
+// exactly, so converting them to time.Time cannot overflow.
+const max_numeric_date = 1 << 53
+
+// see: https://datatracker.ietf.org/doc/html/rfc7519#section-2
+func decodeNumericDate(value json.RawMessage) (time.Time, error) {
+	var date float64
+	err := json.Unmarshal(value, &date)
+	if err != nil {
+		return time.Time{}, errors.New("NumericDate must be a number")
+	}
+	if math.IsNaN(date) || math.Abs(date) > max_numeric_date {
+		return time.Time{}, errors.New("NumericDate is out of range")
+	}
+	if date == math.Trunc(date) {
+		return time.Unix(int64(date), 0), nil
+	}
+	seconds, fraction := math.Modf(date)
+	return time.Unix(int64(seconds), int64(fraction*float64(time.Second))), nil
+}
+
+// Has reports whether the claim is present.
+func (c Claims) Has(name string) bool {
+	switch name {
+	case JWT_CLAIM_ISS:
+		return c.Issuer != ""
+	case JWT_CLAIM_SUB:
+		return c.Subject != ""
+	case JWT_CLAIM_AUD:
+		return len(c.Audience) != 0
+	case JWT_CLAIM_EXP:
+		return !c.Expiration.IsZero()
+	case JWT_CLAIM_NBF:
+		return !c.NotBefore.IsZero()
+	case JWT_CLAIM_IAT:
+		return !c.IssuedAt.IsZero()
+	case JWT_CLAIM_JTI:
+		return c.JWTID != ""
+	}
+	_, exists := c.Custom[name]
+	return exists
+}
+
+func ValidateClaims(claims Claims, validation JWTValidation) error {
+	now := time.Now()
+	if validation.Now != nil {
+		now = validation.Now()
+	}
+	if !claims.Expiration.IsZero() && !now.Before(claims.Expiration.Add(validation.Leeway)) {
+		return errors.New("JWT has expired")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(validation.Leeway).Before(claims.NotBefore) {
+		return errors.New("JWT is not yet valid")
+	}
+	if validation.Issuer != "" && claims.Issuer != validation.Issuer {
+		return errors.New("JWT issuer is not the expected issuer")
+	}
+	if len(claims.Audience) != 0 && !slices.Contains(claims.Audience, validation.Audience) {
+		return errors.New("JWT audience is not the expected audience")
+	}
+	if validation.Audience != "" && len(claims.Audience) == 0 {
+		return errors.New("JWT is missing the audience")
+	}
+	for _, name := range validation.Required {
+		if !claims.Has(name) {
+			return errors.New("JWT is missing a required claim: " + name)
+		}
+	}
+	return nil
+}
+
+// typeMatches compares media types case insensitively, with the
+// application/ prefix omitted.
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1.9
+func typeMatches(typ string, expected string) bool {
+	typ = strings.TrimPrefix(strings.ToLower(typ), "application/")
+	return typ == strings.TrimPrefix(strings.ToLower(expected), "application/")
+}
+
+// IssueJWT signs a JWT Claims Set into a JWS in compact serialization,
+// with "typ": "JWT" unless WithHeader sets another type.
+func IssueJWT(private_key string, claims Claims, opts ...SignOption) (string, error) {
+	o := newSignOptions(opts)
+	if o.unencoded || o.detached {
+		return "", errors.New("JWT payload must be encoded and attached")
+	}
+	payload, err := claims.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return signJWT(private_key, payload, opts)
+}
+
+// signJWT signs an encoded JWT Claims Set, with "typ": "JWT" unless
+// WithHeader sets another type.
+func signJWT(private_key string, payload []byte, opts []SignOption) (string, error) {
+	return CompactSign(private_key, payload, slices.Concat(opts, []SignOption{func(o *signOptions) {
+		if o.header.Typ == "" {
+			o.header.Typ = JWT_TYPE
+		}
+	}})...)
+}
+
+// VerifyJWT verifies a JWT with the key the resolver returns for its kid,
+// such as a key from NewKeySetResolver, and validates its claims.
+func VerifyJWT(resolver KeyResolver, jwt string, validation JWTValidation, opts ...VerifyOption) (Claims, error) {
+	var claims Claims
+	header, err := decodeHeader(strings.SplitN(jwt, ".", 2)[0])
+	if err != nil {
+		return claims, err
+	}
+	if header.Kid == "" {
+		return claims, errors.New("JWT is missing the key identifier")
+	}
+	verified, err := CompactVerifyWithResolver(resolver, jwt, opts...)
+	if err != nil {
+		return claims, err
+	}
+	expected := validation.Type
+	if expected == "" && verified.Header.Typ != "" {
+		expected = JWT_TYPE
+	}
+	if !typeMatches(verified.Header.Typ, expected) {
+		return claims, errors.New("JWT type is not the expected type")
+	}
+	if verified.Header.unencoded() || typeMatches(verified.Header.Cty, JWT_TYPE) {
+		return claims, errors.New("Unencoded and nested JWTs are not supported")
+	}
+	err = json.Unmarshal(verified.Payload, &claims)
+	if err != nil {
+		return claims, errors.New("Failed to decode JWT claims")
+	}
+	err = ValidateClaims(claims, validation)
+	if err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,210 @@
+package jose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type JOSEJWTTestVector struct {
+	Jwk    AKPKey          `json:"jwk"`
+	Claims json.RawMessage `json:"claims"`
+	Jwt    string          `json:"jwt"`
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc7519#section-3.1
+var jwt_claims = Claims{
+	Issuer:     "joe",
+	Subject:    "1234567890",
+	Audience:   []string{"https://api.example.com"},
+	Expiration: time.Unix(1300819380, 0),
+	NotBefore:  time.Unix(1300815780, 0),
+	IssuedAt:   time.Unix(1300815780, 0),
+	JWTID:      "ml-dsa-1",
+	Custom: map[string]any{
+		"http://example.com/is_root": true,
+	},
+}
+
+var jwt_validation = JWTValidation{
+	Now:      func() time.Time { return time.Unix(1300819000, 0) },
+	Issuer:   "joe",
+	Audience: "https://api.example.com",
+	Required: []string{JWT_CLAIM_EXP, JWT_CLAIM_JTI},
+}
+
+// TestJWT calls jose.IssueJWT and jose.VerifyJWT with a JWK set for each
+// ML-DSA level and confirms the claims round trip
+func TestJWT(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		other_key, _ := GenerateKey(alg, large_payload[:32])
+		resolver, _ := NewKeySetResolver(`{"keys":[` + other_key + `,` + private_key + `]}`)
+		jwt, err := IssueJWT(private_key, jwt_claims)
+		if err != nil {
+			t.Fatalf("Issuing %s JWT failed: %v", alg, err)
+		}
+		verified, _ := CompactVerify(public_key, jwt)
+		if verified.Header.Typ != JWT_TYPE || verified.Header.Kid != key.Kid {
+			t.Fatalf("Invalid JWT header: %+v", verified.Header)
+		}
+		claims, err := VerifyJWT(resolver, jwt, jwt_validation)
+		if err != nil {
+			t.Fatalf("Verifying %s JWT failed: %v", alg, err)
+		}
+		if claims.Issuer != jwt_claims.Issuer || claims.Subject != jwt_claims.Subject || claims.JWTID != jwt_claims.JWTID || len(claims.Audience) != 1 || claims.Audience[0] != jwt_claims.Audience[0] {
+			t.Fatalf("Invalid JWT string claims")
+		}
+		if !claims.Expiration.Equal(jwt_claims.Expiration) || !claims.NotBefore.Equal(jwt_claims.NotBefore) || !claims.IssuedAt.Equal(jwt_claims.IssuedAt) {
+			t.Fatalf("Invalid JWT date claims")
+		}
+		if claims.Custom["http://example.com/is_root"] != true {
+			t.Fatalf("Invalid JWT custom claims")
+		}
+
+		encoded_claims, _ := jwt_claims.MarshalJSON()
+		examples, _ := json.MarshalIndent(JOSEJWTTestVector{
+			Jwk:    key,
+			Claims: encoded_claims,
+			Jwt:    jwt,
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".jwt.jose.json", examples, 0644)
+	}
+}
+
+// TestJWTValidation calls jose.ValidateClaims and confirms time, issuer,
+// audience and required claim checks honor the clock and leeway
+func TestJWTValidation(t *testing.T) {
+	at := func(seconds int64) func() time.Time {
+		return func() time.Time { return time.Unix(seconds, 0) }
+	}
+	with := func(change func(*JWTValidation)) JWTValidation {
+		validation := jwt_validation
+		change(&validation)
+		return validation
+	}
+	tests := []struct {
+		name       string
+		validation JWTValidation
+		valid      bool
+	}{
+		{"valid", jwt_validation, true},
+		{"expired", with(func(v *JWTValidation) { v.Now = at(1300819380) }), false},
+		{"expired within leeway", with(func(v *JWTValidation) { v.Now = at(1300819380); v.Leeway = time.Minute }), true},
+		{"not yet valid", with(func(v *JWTValidation) { v.Now = at(1300815779) }), false},
+		{"not yet valid within leeway", with(func(v *JWTValidation) { v.Now = at(1300815779); v.Leeway = time.Second }), true},
+		{"wrong issuer", with(func(v *JWTValidation) { v.Issuer = "jane" }), false},
+		{"wrong audience", with(func(v *JWTValidation) { v.Audience = "https://other.example.com" }), false},
+		{"unexpected audience", with(func(v *JWTValidation) { v.Audience = "" }), false},
+		{"missing required claim", with(func(v *JWTValidation) { v.Required = []string{"cnf"} }), false},
+		{"required custom claim", with(func(v *JWTValidation) { v.Required = []string{"http://example.com/is_root"} }), true},
+	}
+	for _, test := range tests {
+		err := ValidateClaims(jwt_claims, test.validation)
+		if (err == nil) != test.valid {
+			t.Fatalf("%s: unexpected validation result %v", test.name, err)
+		}
+	}
+	multiple := jwt_claims
+	multiple.Audience = []string{"https://other.example.com", jwt_validation.Audience}
+	err := ValidateClaims(multiple, jwt_validation)
+	if err != nil {
+		t.Fatalf("Rejected an audience in a list of audiences: %v", err)
+	}
+	err = ValidateClaims(Claims{}, JWTValidation{Audience: jwt_validation.Audience})
+	if err == nil {
+		t.Fatalf("Accepted a JWT without an audience")
+	}
+}
+
+// TestJWTClaimsJSON confirms NumericDates and audiences decode in each of
+// their forms, and malformed claims are rejected
+func TestJWTClaimsJSON(t *testing.T) {
+	var claims Claims
+	err := json.Unmarshal([]byte(`{"aud":["a","b"],"exp":1300819380.5,"iat":1300815780}`), &claims)
+	if err != nil {
+		t.Fatalf("Failed to decode claims: %v", err)
+	}
+	if len(claims.Audience) != 2 || !claims.Expiration.Equal(time.Unix(1300819380, 5e8)) || !claims.IssuedAt.Equal(time.Unix(1300815780, 0)) {
+		t.Fatalf("Invalid claims: %+v", claims)
+	}
+	encoded, _ := json.Marshal(Claims{Audience: claims.Audience, IssuedAt: claims.IssuedAt})
+	if string(encoded) != `{"aud":["a","b"],"iat":1300815780}` {
+		t.Fatalf("Invalid encoded claims: %s", encoded)
+	}
+	for _, name := range []string{JWT_CLAIM_ISS, JWT_CLAIM_EXP, JWT_CLAIM_JTI} {
+		_, err = json.Marshal(Claims{Custom: map[string]any{name: "tomorrow"}})
+		if err == nil {
+			t.Fatalf("Encoded the registered claim %s in custom claims", name)
+		}
+	}
+	for _, malformed := range []string{`[]`, `null`, `{"exp":"1300819380"}`, `{"aud":1}`, `{"iss":true}`, `{"nbf":1e300}`, `{"exp":1e300}`, `{"exp":-1e300}`} {
+		err = json.Unmarshal([]byte(malformed), &claims)
+		if err == nil {
+			t.Fatalf("Decoded malformed claims %s", malformed)
+		}
+	}
+}
+
+// TestJWTRejected confirms JWTs are not accepted without a kid, with an
+// unexpected type, with an unknown key or with malformed claims
+func TestJWTRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	resolver := NewStaticKeyResolver(public_key)
+	validation := JWTValidation{Now: jwt_validation.Now, Audience: jwt_validation.Audience}
+
+	jwt, _ := IssueJWT(private_key, jwt_claims, WithHeader(JWSHeader{Typ: "example+jwt"}))
+	_, err := VerifyJWT(resolver, jwt, validation)
+	if err == nil {
+		t.Fatalf("Verified a JWT with an unexpected type")
+	}
+	_, err = VerifyJWT(resolver, jwt, JWTValidation{Now: validation.Now, Audience: validation.Audience, Type: "application/example+JWT"})
+	if err != nil {
+		t.Fatalf("Rejected a JWT with the expected type: %v", err)
+	}
+	_, err = IssueJWT(private_key, jwt_claims, Detached())
+	if err == nil {
+		t.Fatalf("Issued a detached JWT")
+	}
+
+	other_private_key, _ := GenerateKey(ML_DSA_44, large_payload[:32])
+	other_jwt, _ := IssueJWT(other_private_key, jwt_claims)
+	_, err = VerifyJWT(resolver, other_jwt, validation)
+	if err == nil {
+		t.Fatalf("Verified a JWT with an unknown key")
+	}
+	encoded_claims, _ := jwt_claims.MarshalJSON()
+	mismatched_kid, _ := CompactSign(private_key, encoded_claims, WithHeader(JWSHeader{Kid: " "}))
+	_, err = VerifyJWT(NewStaticKeyResolver(public_key), mismatched_kid, validation)
+	if err == nil {
+		t.Fatalf("Verified a JWT with a kid that does not match")
+	}
+	no_kid := signWithHeader(private_key, `{"alg":"ML-DSA-44","typ":"JWT"}`, base64.RawURLEncoding.EncodeToString(encoded_claims))
+	_, err = VerifyJWT(resolver, no_kid, validation)
+	if err == nil {
+		t.Fatalf("Verified a JWT without a kid")
+	}
+	not_claims, _ := CompactSign(private_key, payload)
+	_, err = VerifyJWT(resolver, not_claims, JWTValidation{})
+	if err == nil {
+		t.Fatalf("Verified a JWT with malformed claims")
+	}
+	nested, _ := IssueJWT(private_key, jwt_claims, WithHeader(JWSHeader{Cty: "JWT"}))
+	_, err = VerifyJWT(resolver, nested, validation)
+	if err == nil {
+		t.Fatalf("Verified a nested JWT")
+	}
+	for _, claims := range []string{`{"nbf":1e300}`, `{"exp":1e300}`} {
+		huge_date, _ := CompactSign(private_key, []byte(claims))
+		_, err = VerifyJWT(resolver, huge_date, JWTValidation{Now: validation.Now})
+		if err == nil || err.Error() != "Failed to decode JWT claims" {
+			t.Fatalf("Verified a JWT with %s: %v", claims, err)
+		}
+	}
+}
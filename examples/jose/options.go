@@ -0,0 +1,130 @@
+package jose
+
+import "io"
+
+type signOptions struct {
+	hedged    bool
+	rand      io.Reader
+	ctx       []byte
+	unencoded bool
+	detached  bool
+	header    JWSHeader
+}
+
+type SignOption func(*signOptions)
+
+// Hedged signs with the hedged variant of ML-DSA, reading the per-signature
+// randomness from rand, or from crypto/rand when rand is nil.
+func Hedged(rand io.Reader) SignOption {
+	return func(o *signOptions) {
+		o.hedged = true
+		o.rand = rand
+	}
+}
+
+// WithExperimentalContext signs with a non-empty ML-DSA context string,
+// carried in the HEADER_EXPERIMENTAL_CONTEXT header parameter.
+func WithExperimentalContext(ctx []byte) SignOption {
+	return func(o *signOptions) {
+		o.ctx = ctx
+	}
+}
+
+// Unencoded signs the payload without base64url encoding it, and marks the
+// protected header with "b64": false, listed in "crit".
+// see: https://datatracker.ietf.org/doc/html/rfc7797
+func Unencoded() SignOption {
+	return func(o *signOptions) {
+		o.unencoded = true
+	}
+}
+
+// Detached omits the payload from the compact serialization, producing
+// header..signature (RFC 7515, Appendix F).
+func Detached() SignOption {
+	return func(o *signOptions) {
+		o.detached = true
+	}
+}
+
+// WithHeader adds the members of header to the protected header. The
+// algorithm is taken from the private key, and the key identifier too
+// unless header has one.
+func WithHeader(header JWSHeader) SignOption {
+	return func(o *signOptions) {
+		o.header = header
+	}
+}
+
+func newSignOptions(opts []SignOption) signOptions {
+	var o signOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type keyOptions struct {
+	thumbprint_uri bool
+}
+
+type KeyOption func(*keyOptions)
+
+// WithThumbprintURIKid uses the JWK thumbprint URI of the key as its kid,
+// instead of the JWK thumbprint.
+// see: https://datatracker.ietf.org/doc/html/rfc9278
+func WithThumbprintURIKid() KeyOption {
+	return func(o *keyOptions) {
+		o.thumbprint_uri = true
+	}
+}
+
+func newKeyOptions(opts []KeyOption) keyOptions {
+	var o keyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type verifyOptions struct {
+	ctx        []byte
+	critical   []string
+	algorithms []string
+}
+
+type VerifyOption func(*verifyOptions)
+
+// ExpectExperimentalContext verifies with a non-empty ML-DSA context
+// string, which the experimental context header parameter must match.
+// Without it, JWS with the experimental context header are rejected.
+func ExpectExperimentalContext(ctx []byte) VerifyOption {
+	return func(o *verifyOptions) {
+		o.ctx = ctx
+	}
+}
+
+// AcceptCritical accepts JWS that list these extension header parameters
+// in crit, the caller is responsible for processing them.
+// see: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1.11
+func AcceptCritical(names ...string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.critical = append(o.critical, names...)
+	}
+}
+
+// AllowedAlgorithms rejects JWS with an algorithm that is not one of algs,
+// even when the key has that algorithm.
+func AllowedAlgorithms(algs ...string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.algorithms = append(o.algorithms, algs...)
+	}
+}
+
+func newVerifyOptions(opts []VerifyOption) verifyOptions {
+	var o verifyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
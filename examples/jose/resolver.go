@@ -0,0 +1,120 @@
+package jose
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// KeyResolver maps the kid and alg of a JWS header to candidate AKP public
+// keys. The kid is either the key identifier of the key, its JWK
+// thumbprint, or its JWK thumbprint URI.
+type KeyResolver interface {
+	Resolve(kid string, alg string) ([]string, error)
+}
+
+func keyMatches(jwk string, kid string, alg string) bool {
+	key, err := DecodeKey(jwk)
+	if err != nil || key.Alg != alg {
+		return false
+	}
+	if kid == "" || (key.Kid != "" && kid == key.Kid) {
+		return true
+	}
+	thumbprint, err := CalculateJwkThumbprint(jwk)
+	if err != nil {
+		return false
+	}
+	if kid == thumbprint {
+		return true
+	}
+	uri_thumbprint, err := ParseJwkThumbprintURI(kid)
+	return err == nil && uri_thumbprint == thumbprint
+}
+
+func resolveFromKeys(jwks []string, kid string, alg string) ([]string, error) {
+	var candidates []string
+	for _, jwk := range jwks {
+		if !keyMatches(jwk, kid, alg) {
+			continue
+		}
+		public_key, err := PublicKeyFromPrivateKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, public_key)
+	}
+	return candidates, nil
+}
+
+type staticKeyResolver struct {
+	jwks []string
+}
+
+func (r *staticKeyResolver) Resolve(kid string, alg string) ([]string, error) {
+	return resolveFromKeys(r.jwks, kid, alg)
+}
+
+func NewStaticKeyResolver(jwks ...string) KeyResolver {
+	return &staticKeyResolver{jwks: jwks}
+}
+
+// see: https://datatracker.ietf.org/doc/html/rfc7517#section-5
+func parseKeySet(jwk_set []byte) ([]string, error) {
+	var key_set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	err := json.Unmarshal(jwk_set, &key_set)
+	if err != nil || key_set.Keys == nil {
+		return nil, errors.New("Failed to parse JWK Set")
+	}
+	jwks := make([]string, len(key_set.Keys))
+	for i, jwk := range key_set.Keys {
+		jwks[i] = string(jwk)
+	}
+	return jwks, nil
+}
+
+func NewKeySetResolver(jwk_set string) (KeyResolver, error) {
+	jwks, err := parseKeySet([]byte(jwk_set))
+	if err != nil {
+		return nil, err
+	}
+	return &staticKeyResolver{jwks: jwks}, nil
+}
+
+func CompactVerifyWithResolver(resolver KeyResolver, jws string, opts ...VerifyOption) (JWSVerification, error) {
+	return compactVerifyWithResolver(resolver, nil, jws, newVerifyOptions(opts))
+}
+
+func compactVerifyWithResolver(resolver KeyResolver, cache *publicKeyCache, jws string, o verifyOptions) (JWSVerification, error) {
+	var verified = JWSVerification{}
+	components := strings.Split(jws, ".")
+	if len(components) != 3 {
+		return verified, errors.New("JWS must have three components")
+	}
+	header, err := decodeHeader(components[0])
+	if err != nil {
+		return verified, err
+	}
+	candidates, err := resolver.Resolve(header.Kid, header.Alg)
+	if err != nil {
+		return verified, err
+	}
+	if len(candidates) == 0 {
+		return verified, errors.New("No public key found for JWS")
+	}
+	var verify_error error
+	for _, public_key := range candidates {
+		key, err := cache.publicKey(public_key)
+		if err != nil {
+			verify_error = err
+			continue
+		}
+		verified, verify_error = compactVerify(key, jws, o)
+		if verify_error == nil {
+			return verified, nil
+		}
+	}
+	return JWSVerification{}, verify_error
+}
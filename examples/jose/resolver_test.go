@@ -0,0 +1,45 @@
+package jose
+
+import (
+	"testing"
+)
+
+// TestCompactVerifyWithResolver calls jose.CompactVerifyWithResolver with
+// static and JWK Set resolvers, and confirms keys are found by kid or
+// thumbprint
+func TestCompactVerifyWithResolver(t *testing.T) {
+	private_key_44, _ := GenerateKey(ML_DSA_44, seed[:])
+	private_key_65, _ := GenerateKey(ML_DSA_65, seed[:])
+	public_key_44, _ := PublicKeyFromPrivateKey(private_key_44)
+	public_key_65, _ := PublicKeyFromPrivateKey(private_key_65)
+	jws_44, _ := CompactSign(private_key_44, payload)
+	jws_65, _ := CompactSign(private_key_65, payload)
+
+	key_set, err := NewKeySetResolver(`{"keys":[` + public_key_44 + `,` + public_key_65 + `]}`)
+	if err != nil {
+		t.Fatalf("Parsing JWK Set failed: %v", err)
+	}
+	for _, resolver := range []KeyResolver{NewStaticKeyResolver(public_key_44, public_key_65), key_set} {
+		for _, jws := range []string{jws_44, jws_65} {
+			verified, err := CompactVerifyWithResolver(resolver, jws)
+			if err != nil {
+				t.Fatalf("Verification with resolver failed: %v", err)
+			}
+			if string(verified.Payload) != string(payload) {
+				t.Fatalf("Invalid payload")
+			}
+		}
+	}
+	_, err = CompactVerifyWithResolver(NewStaticKeyResolver(public_key_65), jws_44)
+	if err == nil {
+		t.Fatalf("Verified a JWS without its key")
+	}
+	_, err = CompactVerifyWithResolver(NewStaticKeyResolver(public_key_44), "e30.e30")
+	if err == nil {
+		t.Fatalf("Verified a JWS with two components")
+	}
+	_, err = NewKeySetResolver(`[]`)
+	if err == nil {
+		t.Fatalf("Parsed a malformed JWK Set")
+	}
+}
@@ -0,0 +1,586 @@
+package jose
+
+import (
+	"bytes"
+	crypto_rand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc9901
+const (
+	SD_JWT_CLAIM_SD      = "_sd"
+	SD_JWT_CLAIM_SD_ALG  = "_sd_alg"
+	SD_JWT_CLAIM_CNF     = "cnf"
+	SD_JWT_CLAIM_SD_HASH = "sd_hash"
+	SD_JWT_CLAIM_NONCE   = "nonce"
+	// SD_JWT_ARRAY_ELEMENT is the key of an array element digest
+	SD_JWT_ARRAY_ELEMENT = "..."
+	SD_JWT_HASH_SHA_256  = "sha-256"
+	SD_JWT_SEPARATOR     = "~"
+	KB_JWT_TYPE          = "kb+jwt"
+	// SD_JWT_TYPE is the typ of the Issuer-signed JWT, unless WithHeader
+	// sets another type
+	SD_JWT_TYPE = "dc+sd-jwt"
+	// KB_JWT_DEFAULT_MAX_AGE is how old a KB-JWT may be when
+	// SDJWTValidation has no KeyBindingMaxAge
+	KB_JWT_DEFAULT_MAX_AGE = 5 * time.Minute
+)
+
+// Disclosure reveals one selectively disclosable claim, or array element
+// when it has no Name.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-4.2
+type Disclosure struct {
+	Salt  string
+	Name  string
+	Value any
+	// Encoded is the base64url encoded JSON array the digest is computed
+	// over
+	Encoded string
+}
+
+// Digest is the base64url encoded SHA-256 hash of the encoded disclosure.
+func (d Disclosure) Digest() string {
+	digest := sha256.Sum256([]byte(d.Encoded))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+func newDisclosure(rand io.Reader, name string, value any) (Disclosure, error) {
+	salt := make([]byte, 16)
+	_, err := io.ReadFull(rand, salt)
+	if err != nil {
+		return Disclosure{}, errors.New("Failed to read salt for disclosure")
+	}
+	disclosure := Disclosure{
+		Salt:  base64.RawURLEncoding.EncodeToString(salt),
+		Name:  name,
+		Value: value,
+	}
+	encoded, err := json.Marshal([]any{disclosure.Salt, name, value})
+	if err != nil {
+		return Disclosure{}, err
+	}
+	disclosure.Encoded = base64.RawURLEncoding.EncodeToString(encoded)
+	return disclosure, nil
+}
+
+// ParseDisclosure decodes a disclosure of a claim, [salt, name, value], or
+// of an array element, [salt, value].
+func ParseDisclosure(encoded string) (Disclosure, error) {
+	disclosure := Disclosure{Encoded: encoded}
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return disclosure, errors.New("Disclosure is not encoded as base64url")
+	}
+	var members []json.RawMessage
+	err = json.Unmarshal(decoded, &members)
+	if err != nil || (len(members) != 2 && len(members) != 3) {
+		return disclosure, errors.New("Disclosure must be a JSON array of two or three elements")
+	}
+	err = json.Unmarshal(members[0], &disclosure.Salt)
+	if err != nil {
+		return disclosure, errors.New("Disclosure salt must be a string")
+	}
+	if len(members) == 3 {
+		err = json.Unmarshal(members[1], &disclosure.Name)
+		if err != nil {
+			return disclosure, errors.New("Disclosure claim name must be a string")
+		}
+		if disclosure.Name == SD_JWT_CLAIM_SD || disclosure.Name == SD_JWT_ARRAY_ELEMENT {
+			return disclosure, errors.New("Disclosure claim name is not allowed: " + disclosure.Name)
+		}
+	}
+	disclosure.Value, err = decodeJSON(members[len(members)-1])
+	if err != nil {
+		return disclosure, errors.New("Failed to parse disclosure value")
+	}
+	return disclosure, nil
+}
+
+// decodeJSON decodes any JSON value, keeping numbers as they were encoded.
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil || decoder.More() {
+		return nil, errors.New("Failed to parse JSON")
+	}
+	return value, nil
+}
+
+// SDJWTIssuance describes an SD-JWT, the claims of its Issuer-signed JWT
+// and which of them are selectively disclosable.
+type SDJWTIssuance struct {
+	Claims Claims
+	// Disclosable are the names of claims replaced by a digest in the
+	// Issuer-signed JWT and revealed by a disclosure
+	Disclosable []string
+	// HolderKey is the AKP public key of the holder, added as cnf.jwk so
+	// presentations can be bound to it
+	HolderKey string
+	// Rand is the source of salts, crypto/rand when nil
+	Rand io.Reader
+}
+
+// IssueSDJWT signs an SD-JWT with an AKP private key. It is serialized as
+// the Issuer-signed JWT, with "typ": "dc+sd-jwt" unless WithHeader sets
+// another type, followed by every disclosure, each ending with ~.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-4
+func IssueSDJWT(private_key string, issuance SDJWTIssuance, opts ...SignOption) (string, error) {
+	rand := issuance.Rand
+	if rand == nil {
+		rand = crypto_rand.Reader
+	}
+	encoded_claims, err := issuance.Claims.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	decoded_claims, err := decodeJSON(encoded_claims)
+	if err != nil {
+		return "", err
+	}
+	claims := decoded_claims.(map[string]any)
+	for _, name := range []string{SD_JWT_CLAIM_SD, SD_JWT_CLAIM_SD_ALG, SD_JWT_CLAIM_CNF} {
+		if _, exists := claims[name]; exists {
+			return "", errors.New("SD-JWT claims must not contain " + name)
+		}
+	}
+	var disclosures []Disclosure
+	var digests []string
+	for _, name := range issuance.Disclosable {
+		value, exists := claims[name]
+		if !exists {
+			return "", errors.New("Disclosable claim is missing: " + name)
+		}
+		disclosure, err := newDisclosure(rand, name, value)
+		if err != nil {
+			return "", err
+		}
+		delete(claims, name)
+		disclosures = append(disclosures, disclosure)
+		digests = append(digests, disclosure.Digest())
+	}
+	// digests are sorted, so their order does not reveal the claims
+	slices.Sort(digests)
+	if len(digests) != 0 {
+		claims[SD_JWT_CLAIM_SD] = digests
+	}
+	claims[SD_JWT_CLAIM_SD_ALG] = SD_JWT_HASH_SHA_256
+	if issuance.HolderKey != "" {
+		_, err = parsePublicKey(issuance.HolderKey)
+		if err != nil {
+			return "", err
+		}
+		claims[SD_JWT_CLAIM_CNF] = map[string]any{"jwk": json.RawMessage(issuance.HolderKey)}
+	}
+	o := newSignOptions(opts)
+	if o.unencoded || o.detached {
+		return "", errors.New("JWT payload must be encoded and attached")
+	}
+	encoded_claims, err = json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	jwt, err := signJWT(private_key, encoded_claims, slices.Concat(opts, []SignOption{func(o *signOptions) {
+		if o.header.Typ == "" {
+			o.header.Typ = SD_JWT_TYPE
+		}
+	}}))
+	if err != nil {
+		return "", err
+	}
+	sd_jwt := jwt + SD_JWT_SEPARATOR
+	for _, disclosure := range disclosures {
+		sd_jwt += disclosure.Encoded + SD_JWT_SEPARATOR
+	}
+	return sd_jwt, nil
+}
+
+// splitSDJWT separates the Issuer-signed JWT, the disclosures and the
+// KB-JWT, which is empty when there is none.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-4
+func splitSDJWT(sd_jwt string) (string, []Disclosure, string, error) {
+	components := strings.Split(sd_jwt, SD_JWT_SEPARATOR)
+	if len(components) < 2 || components[0] == "" {
+		return "", nil, "", errors.New("SD-JWT must start with an Issuer-signed JWT followed by ~")
+	}
+	var disclosures []Disclosure
+	for _, encoded := range components[1 : len(components)-1] {
+		disclosure, err := ParseDisclosure(encoded)
+		if err != nil {
+			return "", nil, "", err
+		}
+		disclosures = append(disclosures, disclosure)
+	}
+	return components[0], disclosures, components[len(components)-1], nil
+}
+
+// Disclosures returns the disclosures of an SD-JWT or presentation.
+func Disclosures(sd_jwt string) ([]Disclosure, error) {
+	_, disclosures, _, err := splitSDJWT(sd_jwt)
+	return disclosures, err
+}
+
+// sdProcessor replaces digests with the claims and array elements of the
+// disclosures they reference.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-7.1
+type sdProcessor struct {
+	disclosures map[string]Disclosure
+	referenced  map[string]bool
+}
+
+func newSDProcessor(disclosures []Disclosure) (*sdProcessor, error) {
+	p := &sdProcessor{
+		disclosures: map[string]Disclosure{},
+		referenced:  map[string]bool{},
+	}
+	for _, disclosure := range disclosures {
+		digest := disclosure.Digest()
+		if _, exists := p.disclosures[digest]; exists {
+			return nil, errors.New("SD-JWT has a repeated disclosure")
+		}
+		p.disclosures[digest] = disclosure
+	}
+	return p, nil
+}
+
+// reference returns the disclosure of a digest, when it was presented.
+func (p *sdProcessor) reference(digest any) (Disclosure, bool, error) {
+	encoded_digest, valid := digest.(string)
+	if !valid {
+		return Disclosure{}, false, errors.New("SD-JWT digest must be a string")
+	}
+	if p.referenced[encoded_digest] {
+		return Disclosure{}, false, errors.New("SD-JWT digest is referenced more than once")
+	}
+	p.referenced[encoded_digest] = true
+	disclosure, exists := p.disclosures[encoded_digest]
+	return disclosure, exists, nil
+}
+
+func (p *sdProcessor) process(value any) (any, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		processed := map[string]any{}
+		for name, member := range value {
+			if name == SD_JWT_CLAIM_SD {
+				continue
+			}
+			member, err := p.process(member)
+			if err != nil {
+				return nil, err
+			}
+			processed[name] = member
+		}
+		digests, _ := value[SD_JWT_CLAIM_SD].([]any)
+		if _, exists := value[SD_JWT_CLAIM_SD]; exists && digests == nil {
+			return nil, errors.New("SD-JWT _sd claim must be an array")
+		}
+		for _, digest := range digests {
+			disclosure, exists, err := p.reference(digest)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				// a decoy digest, or a claim that is not disclosed
+				continue
+			}
+			if disclosure.Name == "" {
+				return nil, errors.New("SD-JWT _sd references an array element disclosure")
+			}
+			if _, exists := processed[disclosure.Name]; exists {
+				return nil, errors.New("SD-JWT disclosure overwrites a claim: " + disclosure.Name)
+			}
+			disclosed, err := p.process(disclosure.Value)
+			if err != nil {
+				return nil, err
+			}
+			processed[disclosure.Name] = disclosed
+		}
+		return processed, nil
+	case []any:
+		processed := []any{}
+		for _, element := range value {
+			object, is_object := element.(map[string]any)
+			digest, is_digest := object[SD_JWT_ARRAY_ELEMENT]
+			if !is_object || !is_digest || len(object) != 1 {
+				element, err := p.process(element)
+				if err != nil {
+					return nil, err
+				}
+				processed = append(processed, element)
+				continue
+			}
+			disclosure, exists, err := p.reference(digest)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				continue
+			}
+			if disclosure.Name != "" {
+				return nil, errors.New("SD-JWT array element references a claim disclosure")
+			}
+			disclosed, err := p.process(disclosure.Value)
+			if err != nil {
+				return nil, err
+			}
+			processed = append(processed, disclosed)
+		}
+		return processed, nil
+	}
+	return value, nil
+}
+
+// processSDJWT returns the claims of the Issuer-signed JWT payload with the
+// disclosures applied, rejecting disclosures that are not referenced.
+func processSDJWT(payload []byte, disclosures []Disclosure) (map[string]any, error) {
+	decoded, err := decodeJSON(payload)
+	claims, is_object := decoded.(map[string]any)
+	if err != nil || !is_object {
+		return nil, errors.New("Failed to decode JWT claims")
+	}
+	if sd_alg, exists := claims[SD_JWT_CLAIM_SD_ALG]; exists && sd_alg != SD_JWT_HASH_SHA_256 {
+		return nil, errors.New("SD-JWT hash algorithm is not supported")
+	}
+	delete(claims, SD_JWT_CLAIM_SD_ALG)
+	p, err := newSDProcessor(disclosures)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := p.process(claims)
+	if err != nil {
+		return nil, err
+	}
+	for digest := range p.disclosures {
+		if !p.referenced[digest] {
+			return nil, errors.New("SD-JWT disclosure is not referenced by the Issuer-signed JWT")
+		}
+	}
+	return processed.(map[string]any), nil
+}
+
+// confirmationKey returns the cnf.jwk of processed claims.
+// see: https://datatracker.ietf.org/doc/html/rfc7800#section-3.2
+func confirmationKey(claims map[string]any) (string, error) {
+	cnf, _ := claims[SD_JWT_CLAIM_CNF].(map[string]any)
+	jwk, is_object := cnf["jwk"].(map[string]any)
+	if !is_object {
+		return "", errors.New("SD-JWT is missing the confirmation key (cnf.jwk)")
+	}
+	return string(canonicalJSON(jwk)), nil
+}
+
+// KeyBinding are the claims of a KB-JWT, which the verifier checks.
+type KeyBinding struct {
+	Audience string
+	Nonce    string
+	// IssuedAt defaults to time.Now
+	IssuedAt time.Time
+}
+
+// sdHash is the digest of the presentation a KB-JWT is bound to.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-4.3.1
+func sdHash(presentation string) string {
+	digest := sha256.Sum256([]byte(presentation))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// PresentSDJWT selects the disclosures of the claims named in disclosed, and
+// when a holder private key is given appends a KB-JWT signed with it. The
+// holder key must be the key in cnf.jwk.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-6
+func PresentSDJWT(sd_jwt string, disclosed []string, holder_private_key string, binding KeyBinding, opts ...SignOption) (string, error) {
+	jwt, disclosures, kb_jwt, err := splitSDJWT(sd_jwt)
+	if err != nil {
+		return "", err
+	}
+	if kb_jwt != "" {
+		return "", errors.New("SD-JWT already has a KB-JWT")
+	}
+	presentation := jwt + SD_JWT_SEPARATOR
+	for _, disclosure := range disclosures {
+		if disclosure.Name != "" && slices.Contains(disclosed, disclosure.Name) {
+			presentation += disclosure.Encoded + SD_JWT_SEPARATOR
+		}
+	}
+	if holder_private_key == "" {
+		return presentation, nil
+	}
+	components := strings.Split(jwt, ".")
+	if len(components) != 3 {
+		return "", errors.New("JWS must have three components")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(components[1])
+	if err != nil {
+		return "", errors.New("JWS Payload is not encoded as base64url")
+	}
+	claims, err := processSDJWT(payload, disclosures)
+	if err != nil {
+		return "", err
+	}
+	confirmation_key, err := confirmationKey(claims)
+	if err != nil {
+		return "", err
+	}
+	confirmation_thumbprint, err := CalculateJwkThumbprint(confirmation_key)
+	if err != nil {
+		return "", err
+	}
+	holder_thumbprint, err := CalculateJwkThumbprint(holder_private_key)
+	if err != nil {
+		return "", err
+	}
+	if holder_thumbprint != confirmation_thumbprint {
+		return "", errors.New("Holder key is not the confirmation key of the SD-JWT")
+	}
+	if binding.Audience == "" || binding.Nonce == "" {
+		return "", errors.New("KB-JWT requires an audience and a nonce")
+	}
+	issued_at := binding.IssuedAt
+	if issued_at.IsZero() {
+		issued_at = time.Now()
+	}
+	kb_jwt, err = IssueJWT(holder_private_key, Claims{
+		Audience: []string{binding.Audience},
+		IssuedAt: issued_at,
+		Custom: map[string]any{
+			SD_JWT_CLAIM_NONCE:   binding.Nonce,
+			SD_JWT_CLAIM_SD_HASH: sdHash(presentation),
+		},
+	}, slices.Concat(opts, []SignOption{WithHeader(JWSHeader{Typ: KB_JWT_TYPE})})...)
+	if err != nil {
+		return "", err
+	}
+	return presentation + kb_jwt, nil
+}
+
+type SDJWTValidation struct {
+	// JWTValidation validates the claims of the Issuer-signed JWT, after
+	// the disclosures are applied. Its typ must be SD_JWT_TYPE unless Type
+	// is set
+	JWTValidation
+	// KeyBindingAudience and Nonce must match the KB-JWT. A KB-JWT is
+	// required when KeyBindingAudience is set, and rejected when it is not
+	KeyBindingAudience string
+	Nonce              string
+	// KeyBindingMaxAge defaults to KB_JWT_DEFAULT_MAX_AGE
+	KeyBindingMaxAge time.Duration
+}
+
+// VerifySDJWT verifies the Issuer-signed JWT of an SD-JWT presentation with
+// the key the resolver returns for its kid, applies the disclosures, and
+// verifies the KB-JWT with the confirmation key. The returned claims are
+// the disclosed claims, without _sd and _sd_alg.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-7
+func VerifySDJWT(resolver KeyResolver, presentation string, validation SDJWTValidation, opts ...VerifyOption) (Claims, error) {
+	var claims Claims
+	jwt, disclosures, kb_jwt, err := splitSDJWT(presentation)
+	if err != nil {
+		return claims, err
+	}
+	header, err := decodeHeader(strings.SplitN(jwt, ".", 2)[0])
+	if err != nil {
+		return claims, err
+	}
+	if header.Kid == "" {
+		return claims, errors.New("JWT is missing the key identifier")
+	}
+	verified, err := CompactVerifyWithResolver(resolver, jwt, opts...)
+	if err != nil {
+		return claims, err
+	}
+	expected := validation.Type
+	if expected == "" {
+		expected = SD_JWT_TYPE
+	}
+	if !typeMatches(verified.Header.Typ, expected) {
+		return claims, errors.New("SD-JWT type is not the expected type")
+	}
+	if verified.Header.unencoded() {
+		return claims, errors.New("Unencoded JWTs are not supported")
+	}
+	processed, err := processSDJWT(verified.Payload, disclosures)
+	if err != nil {
+		return claims, err
+	}
+	if kb_jwt != "" || validation.KeyBindingAudience != "" {
+		err = verifyKeyBinding(processed, strings.TrimSuffix(presentation, kb_jwt), kb_jwt, validation, opts)
+		if err != nil {
+			return claims, err
+		}
+	}
+	encoded_claims, _ := json.Marshal(processed)
+	err = json.Unmarshal(encoded_claims, &claims)
+	if err != nil {
+		return claims, err
+	}
+	err = ValidateClaims(claims, validation.JWTValidation)
+	if err != nil {
+		return claims, err
+	}
+	return claims, nil
+}
+
+// verifyKeyBinding verifies a KB-JWT with the confirmation key, and that it
+// is bound to the presentation before it.
+// see: https://datatracker.ietf.org/doc/html/rfc9901#section-7.3
+func verifyKeyBinding(claims map[string]any, presentation string, kb_jwt string, validation SDJWTValidation, opts []VerifyOption) error {
+	if kb_jwt == "" {
+		return errors.New("SD-JWT presentation is missing the KB-JWT")
+	}
+	if validation.KeyBindingAudience == "" {
+		return errors.New("KB-JWT requires an expected KeyBindingAudience")
+	}
+	confirmation_key, err := confirmationKey(claims)
+	if err != nil {
+		return err
+	}
+	verified, err := CompactVerify(confirmation_key, kb_jwt, opts...)
+	if err != nil {
+		return err
+	}
+	if !typeMatches(verified.Header.Typ, KB_JWT_TYPE) {
+		return errors.New("KB-JWT type must be kb+jwt")
+	}
+	var binding Claims
+	err = json.Unmarshal(verified.Payload, &binding)
+	if err != nil {
+		return errors.New("Failed to decode KB-JWT claims")
+	}
+	now := time.Now()
+	if validation.Now != nil {
+		now = validation.Now()
+	}
+	max_age := validation.KeyBindingMaxAge
+	if max_age == 0 {
+		max_age = KB_JWT_DEFAULT_MAX_AGE
+	}
+	if binding.IssuedAt.IsZero() {
+		return errors.New("KB-JWT is missing the issued at time")
+	}
+	if now.Add(validation.Leeway).Before(binding.IssuedAt) || now.Sub(binding.IssuedAt) > max_age+validation.Leeway {
+		return errors.New("KB-JWT issued at time is not acceptable")
+	}
+	if len(binding.Audience) != 1 || binding.Audience[0] != validation.KeyBindingAudience {
+		return errors.New("KB-JWT audience is not the expected audience")
+	}
+	if nonce, _ := binding.Custom[SD_JWT_CLAIM_NONCE].(string); nonce == "" || nonce != validation.Nonce {
+		return errors.New("KB-JWT nonce is not the expected nonce")
+	}
+	if sd_hash, _ := binding.Custom[SD_JWT_CLAIM_SD_HASH].(string); sd_hash != sdHash(presentation) {
+		return errors.New("KB-JWT is not bound to the presentation (sd_hash)")
+	}
+	return ValidateClaims(binding, JWTValidation{
+		Now:      validation.Now,
+		Leeway:   validation.Leeway,
+		Audience: validation.KeyBindingAudience,
+	})
+}
@@ -0,0 +1,256 @@
+package jose
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type JOSESDJWTTestVector struct {
+	Jwk          AKPKey   `json:"jwk"`
+	HolderJwk    AKPKey   `json:"holder_jwk"`
+	SdJwt        string   `json:"sd_jwt"`
+	Disclosed    []string `json:"disclosed"`
+	Presentation string   `json:"presentation"`
+}
+
+var sd_jwt_issuance = SDJWTIssuance{
+	Claims: Claims{
+		Issuer:     "https://issuer.example.com",
+		Subject:    "user_42",
+		Expiration: time.Unix(1883000000, 0),
+		IssuedAt:   time.Unix(1683000000, 0),
+		Custom: map[string]any{
+			"given_name":    "John",
+			"family_name":   "Doe",
+			"email":         "johndoe@example.com",
+			"birthdate":     "1940-01-01",
+			"address":       map[string]any{"country": "US", "locality": "Anytown"},
+			"nationalities": []any{"US", "DE"},
+		},
+	},
+	Disclosable: []string{"given_name", "family_name", "email", "birthdate", "address", "nationalities"},
+}
+
+var sd_jwt_binding = KeyBinding{
+	Audience: "https://verifier.example.org",
+	Nonce:    "1234567890",
+	IssuedAt: time.Unix(1683000060, 0),
+}
+
+var sd_jwt_validation = SDJWTValidation{
+	JWTValidation: JWTValidation{
+		Now:    func() time.Time { return time.Unix(1683000120, 0) },
+		Issuer: "https://issuer.example.com",
+	},
+	KeyBindingAudience: "https://verifier.example.org",
+	Nonce:              "1234567890",
+}
+
+// TestSDJWT calls jose.IssueSDJWT, jose.PresentSDJWT and jose.VerifySDJWT
+// for each ML-DSA level and confirms only the selected claims are disclosed
+func TestSDJWT(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		key, _ := DecodeKey(private_key)
+		holder_private_key, _ := GenerateKey(alg, large_payload[:32])
+		holder_public_key, _ := PublicKeyFromPrivateKey(holder_private_key)
+		holder_key, _ := DecodeKey(holder_private_key)
+		resolver := NewStaticKeyResolver(private_key)
+		issuance := sd_jwt_issuance
+		issuance.HolderKey = holder_public_key
+		issuance.Rand = bytes.NewReader(bytes.Repeat([]byte{0x42}, 16*len(issuance.Disclosable)))
+		sd_jwt, err := IssueSDJWT(private_key, issuance)
+		if err != nil {
+			t.Fatalf("Issuing %s SD-JWT failed: %v", alg, err)
+		}
+		header, _ := decodeHeader(strings.SplitN(sd_jwt, ".", 2)[0])
+		if header.Typ != SD_JWT_TYPE {
+			t.Fatalf("Invalid SD-JWT typ %s", header.Typ)
+		}
+		disclosures, _ := Disclosures(sd_jwt)
+		if len(disclosures) != len(issuance.Disclosable) || !strings.HasSuffix(sd_jwt, SD_JWT_SEPARATOR) {
+			t.Fatalf("Invalid SD-JWT %s", sd_jwt)
+		}
+		disclosed := []string{"given_name", "address"}
+		presentation, err := PresentSDJWT(sd_jwt, disclosed, holder_private_key, sd_jwt_binding)
+		if err != nil {
+			t.Fatalf("Presenting %s SD-JWT failed: %v", alg, err)
+		}
+		claims, err := VerifySDJWT(resolver, presentation, sd_jwt_validation)
+		if err != nil {
+			t.Fatalf("Verifying %s SD-JWT failed: %v", alg, err)
+		}
+		if claims.Issuer != sd_jwt_issuance.Claims.Issuer || claims.Subject != sd_jwt_issuance.Claims.Subject || claims.Custom["given_name"] != "John" {
+			t.Fatalf("Invalid disclosed claims %+v", claims)
+		}
+		address, _ := claims.Custom["address"].(map[string]any)
+		if address["locality"] != "Anytown" {
+			t.Fatalf("Invalid disclosed address %+v", claims.Custom["address"])
+		}
+		for _, name := range []string{"family_name", "email", "birthdate", "nationalities", SD_JWT_CLAIM_SD, SD_JWT_CLAIM_SD_ALG} {
+			if claims.Has(name) {
+				t.Fatalf("Claim %s was not selectively disclosed", name)
+			}
+		}
+		cnf, _ := claims.Custom[SD_JWT_CLAIM_CNF].(map[string]any)
+		if jwk, _ := cnf["jwk"].(map[string]any); jwk["pub"] != holder_key.Pub {
+			t.Fatalf("Invalid confirmation key")
+		}
+		without_binding, _ := PresentSDJWT(sd_jwt, disclosed, "", KeyBinding{})
+		validation := sd_jwt_validation
+		validation.KeyBindingAudience = ""
+		_, err = VerifySDJWT(resolver, without_binding, validation)
+		if err != nil {
+			t.Fatalf("Verifying %s SD-JWT without key binding failed: %v", alg, err)
+		}
+		examples, _ := json.MarshalIndent(JOSESDJWTTestVector{
+			Jwk:          key,
+			HolderJwk:    holder_key,
+			SdJwt:        sd_jwt,
+			Disclosed:    disclosed,
+			Presentation: presentation,
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".sd-jwt.jose.json", examples, 0644)
+	}
+}
+
+// TestSDJWTRejected calls jose.VerifySDJWT with presentations that are
+// altered, unbound or bound to another audience, nonce or presentation, and
+// confirms each is rejected
+func TestSDJWTRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	holder_private_key, _ := GenerateKey(ML_DSA_44, large_payload[:32])
+	holder_public_key, _ := PublicKeyFromPrivateKey(holder_private_key)
+	other_private_key, _ := GenerateKey(ML_DSA_44, large_payload[32:64])
+	resolver := NewStaticKeyResolver(private_key)
+	issuance := sd_jwt_issuance
+	issuance.HolderKey = holder_public_key
+	sd_jwt, _ := IssueSDJWT(private_key, issuance)
+	presentation, _ := PresentSDJWT(sd_jwt, []string{"email"}, holder_private_key, sd_jwt_binding)
+	components := strings.Split(presentation, SD_JWT_SEPARATOR)
+	disclosures, _ := Disclosures(sd_jwt)
+	forged, _ := newDisclosure(bytes.NewReader(make([]byte, 16)), "email", "attacker@example.com")
+	unbound, _ := PresentSDJWT(sd_jwt, []string{"email"}, "", KeyBinding{})
+	other_audience := sd_jwt_binding
+	other_audience.Audience = "https://attacker.example.org"
+	other_nonce := sd_jwt_binding
+	other_nonce.Nonce = "0987654321"
+	expired := sd_jwt_binding
+	expired.IssuedAt = time.Unix(1682990000, 0)
+	bound_to := func(binding KeyBinding) string {
+		presentation, _ := PresentSDJWT(sd_jwt, []string{"email"}, holder_private_key, binding)
+		return presentation
+	}
+	for name, invalid := range map[string]string{
+		"no issuer-signed jwt":     "~" + components[1] + "~",
+		"no separator":             components[0],
+		"forged disclosure":        components[0] + "~" + forged.Encoded + "~",
+		"repeated disclosure":      components[0] + "~" + components[1] + "~" + components[1] + "~",
+		"malformed disclosure":     components[0] + "~AA~",
+		"unbound":                  unbound,
+		"removed disclosure":       components[0] + "~" + components[2],
+		"added disclosure":         components[0] + "~" + components[1] + "~" + disclosures[0].Encoded + "~" + components[2],
+		"other audience":           bound_to(other_audience),
+		"other nonce":              bound_to(other_nonce),
+		"expired key binding":      bound_to(expired),
+		"key binding is not a jwt": unbound + "AA",
+		"key binding not signed by holder": strings.TrimSuffix(unbound, "~") + "~" + func() string {
+			jwt, _ := IssueJWT(other_private_key, Claims{Audience: []string{sd_jwt_binding.Audience}, IssuedAt: sd_jwt_binding.IssuedAt}, WithHeader(JWSHeader{Typ: KB_JWT_TYPE}))
+			return jwt
+		}(),
+	} {
+		_, err := VerifySDJWT(resolver, invalid, sd_jwt_validation)
+		if err == nil {
+			t.Fatalf("Verified presentation with %s", name)
+		}
+	}
+	_, err := PresentSDJWT(sd_jwt, []string{"email"}, other_private_key, sd_jwt_binding)
+	if err == nil {
+		t.Fatalf("Presented with a key that is not the confirmation key")
+	}
+	_, err = PresentSDJWT(presentation, nil, holder_private_key, sd_jwt_binding)
+	if err == nil {
+		t.Fatalf("Presented an SD-JWT that already has a KB-JWT")
+	}
+	validation := sd_jwt_validation
+	validation.KeyBindingAudience = ""
+	_, err = VerifySDJWT(resolver, presentation, validation)
+	if err == nil {
+		t.Fatalf("Verified a KB-JWT without an expected audience")
+	}
+	jwt_typed, _ := IssueSDJWT(private_key, issuance, WithHeader(JWSHeader{Typ: JWT_TYPE}))
+	_, err = VerifySDJWT(resolver, jwt_typed, validation)
+	if err == nil {
+		t.Fatalf("Verified an SD-JWT with typ JWT")
+	}
+	validation.Type = JWT_TYPE
+	_, err = VerifySDJWT(resolver, jwt_typed, validation)
+	if err != nil {
+		t.Fatalf("Verifying an SD-JWT with the expected typ JWT failed: %v", err)
+	}
+	issuance.Disclosable = []string{"unknown"}
+	_, err = IssueSDJWT(private_key, issuance)
+	if err == nil {
+		t.Fatalf("Issued an SD-JWT with a missing disclosable claim")
+	}
+	issuance.Disclosable = nil
+	issuance.HolderKey = holder_private_key
+	_, err = IssueSDJWT(private_key, issuance)
+	if err == nil {
+		t.Fatalf("Issued an SD-JWT with a private holder key")
+	}
+}
+
+// TestSDJWTProcessing applies disclosures of nested claims and array
+// elements to an Issuer-signed JWT payload, and confirms decoys are removed
+// and unreferenced or misplaced disclosures are rejected
+func TestSDJWTProcessing(t *testing.T) {
+	salt := bytes.NewReader(make([]byte, 16*4))
+	street, _ := newDisclosure(salt, "street_address", "123 Main St")
+	country, _ := newDisclosure(salt, "", "US")
+	address, _ := newDisclosure(salt, "address", map[string]any{"_sd": []any{street.Digest()}, "locality": "Anytown"})
+	unreferenced, _ := newDisclosure(salt, "email", "johndoe@example.com")
+	payload := []byte(`{"_sd_alg":"sha-256","_sd":["` + address.Digest() + `","decoy_1"],"nationalities":[{"...":"` + country.Digest() + `"},{"...":"decoy_2"},"DE"],"n":12345678901234567890}`)
+	claims, err := processSDJWT(payload, []Disclosure{street, country, address})
+	if err != nil {
+		t.Fatalf("Processing disclosures failed: %v", err)
+	}
+	processed, _ := json.Marshal(claims)
+	if string(processed) != `{"address":{"locality":"Anytown","street_address":"123 Main St"},"n":12345678901234567890,"nationalities":["US","DE"]}` {
+		t.Fatalf("Invalid processed claims %s", processed)
+	}
+	for name, disclosures := range map[string][]Disclosure{
+		"unreferenced disclosure": {unreferenced},
+		"nested without parent":   {street},
+	} {
+		_, err = processSDJWT(payload, disclosures)
+		if err == nil {
+			t.Fatalf("Processed %s", name)
+		}
+	}
+	for _, invalid := range []string{
+		`{"_sd_alg":"sha-512"}`,
+		`{"_sd":"` + address.Digest() + `"}`,
+		`{"_sd":[1]}`,
+		`{"_sd":["` + country.Digest() + `"]}`,
+		`{"a":[{"...":"` + address.Digest() + `"}]}`,
+		`{"_sd":["` + address.Digest() + `"],"address":{}}`,
+		`{"_sd":["` + address.Digest() + `","` + address.Digest() + `"]}`,
+		`[]`,
+	} {
+		_, err = processSDJWT([]byte(invalid), []Disclosure{country, address, street})
+		if err == nil {
+			t.Fatalf("Processed invalid payload %s", invalid)
+		}
+	}
+	for _, invalid := range []string{"AA", "WyJzYWx0Il0", "WyJzYWx0IiwiX3NkIiwxXQ", "WzEsMl0"} {
+		_, err = ParseDisclosure(invalid)
+		if err == nil {
+			t.Fatalf("Parsed invalid disclosure %s", invalid)
+		}
+	}
+}
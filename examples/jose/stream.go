@@ -0,0 +1,155 @@
+package jose
+
+import (
+	crypto_rand "crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/cose-wg/draft-ietf-cose-dilithium/example/internal/mldsa"
+)
+
+// streamedMessage writes the ML-DSA formatted message M' for the JWS
+// Signing Input of a streamed payload. The payload is base64url encoded as
+// it is read, unless it is unencoded, and fed into the computation of mu
+// without being held in memory.
+type streamedMessage struct {
+	ctx       []byte
+	prehash   bool
+	header    string
+	payload   io.Reader
+	unencoded bool
+	err       error
+}
+
+func (m *streamedMessage) write(w io.Writer) {
+	if m.prehash {
+		h := sha512.New()
+		m.writeSigningInput(h)
+		mldsa.PreHashSHA512(m.ctx, h.Sum(nil))(w)
+		return
+	}
+	_, _ = w.Write([]byte{0, byte(len(m.ctx))})
+	_, _ = w.Write(m.ctx)
+	m.writeSigningInput(w)
+}
+
+func (m *streamedMessage) writeSigningInput(w io.Writer) {
+	_, _ = io.WriteString(w, m.header+".")
+	if m.unencoded {
+		_, m.err = io.Copy(w, m.payload)
+		return
+	}
+	encoder := base64.NewEncoder(base64.RawURLEncoding, w)
+	_, m.err = io.Copy(encoder, m.payload)
+	encoder.Close()
+}
+
+// CompactSignStream signs a payload read from payload, and returns a JWS in
+// compact serialization with a detached payload (RFC 7515, Appendix F).
+// The signature is the same as the one produced by CompactSign over the
+// same payload.
+func CompactSignStream(private_key string, payload io.Reader, opts ...SignOption) (string, error) {
+	o := newSignOptions(opts)
+	key, err := parsePrivateKey(private_key)
+	if err != nil {
+		return "", err
+	}
+	err = checkContext(o.ctx)
+	if err != nil {
+		return "", err
+	}
+	jws_header, err := headerForSigning(key, o)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(jws_header)
+	if err != nil {
+		return "", err
+	}
+	var rnd [32]byte
+	if o.hedged {
+		var rand = o.rand
+		if rand == nil {
+			rand = crypto_rand.Reader
+		}
+		rnd, err = mldsa.Randomness(rand)
+		if err != nil {
+			return "", err
+		}
+	}
+	message := streamedMessage{
+		ctx:       o.ctx,
+		prehash:   IsHashMLDSA(key.alg),
+		header:    base64.RawURLEncoding.EncodeToString(header),
+		payload:   payload,
+		unencoded: o.unencoded,
+	}
+	signature, err := mldsa.Sign(key.key, message.write, rnd)
+	if err != nil {
+		return "", err
+	}
+	if message.err != nil {
+		return "", message.err
+	}
+	return message.header + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// CompactVerifyStream verifies a JWS in compact serialization with a
+// detached payload read from payload.
+func CompactVerifyStream(public_key string, jws string, payload io.Reader, opts ...VerifyOption) (JWSVerification, error) {
+	var verified = JWSVerification{}
+	o := newVerifyOptions(opts)
+	key, err := parsePublicKey(public_key)
+	if err != nil {
+		return verified, err
+	}
+	components := strings.Split(jws, ".")
+	if len(components) != 3 {
+		return verified, errors.New("JWS must have three components")
+	}
+	if components[1] != "" {
+		return verified, errors.New("JWS payload is not detached")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(components[2])
+	if err != nil {
+		return verified, errors.New("Failed to decode signature from JWS")
+	}
+	header, err := decodeProtectedHeader(components[0], o)
+	if err != nil {
+		return verified, err
+	}
+	if header.Alg != key.alg {
+		return verified, errors.New("JWS algorithm does not match the key algorithm")
+	}
+	err = checkAlgorithm(o, header.Alg)
+	if err != nil {
+		return verified, err
+	}
+	ctx, err := contextForVerification(o, header)
+	if err != nil {
+		return verified, err
+	}
+	message := streamedMessage{
+		ctx:       ctx,
+		prehash:   IsHashMLDSA(key.alg),
+		header:    components[0],
+		payload:   payload,
+		unencoded: header.unencoded(),
+	}
+	valid, err := mldsa.Verify(key.key, message.write, signature)
+	if err != nil {
+		return verified, err
+	}
+	if message.err != nil {
+		return verified, message.err
+	}
+	if !valid {
+		return verified, errors.New("Signature not from public key")
+	}
+	verified.Header = header
+	return verified, nil
+}
@@ -0,0 +1,92 @@
+package jose
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var large_payload = bytes.Repeat(payload, 1<<16)
+
+// TestCompactSignStream calls jose.CompactSignStream with a payload reader
+// and confirms the signature is byte identical to jose.CompactSign and
+// verifies with jose.CompactVerifyStream
+func TestCompactSignStream(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		var private_key, _ = GenerateKey(alg, seed[:])
+		var public_key, _ = PublicKeyFromPrivateKey(private_key)
+		for _, message := range [][]byte{{}, payload, large_payload} {
+			in_memory, _ := CompactSign(private_key, message)
+			streamed, err := CompactSignStream(private_key, bytes.NewReader(message))
+			if err != nil {
+				t.Fatalf("Streaming signature failed: %v", err)
+			}
+			in_memory_components := strings.Split(in_memory, ".")
+			streamed_components := strings.Split(streamed, ".")
+			if streamed_components[1] != "" {
+				t.Fatalf("Streamed JWS payload is not detached")
+			}
+			if in_memory_components[0] != streamed_components[0] || in_memory_components[2] != streamed_components[2] {
+				t.Fatalf("Streamed signature differs from in memory signature")
+			}
+			verified, err := CompactVerifyStream(public_key, streamed, bytes.NewReader(message))
+			if err != nil {
+				t.Fatalf("Streaming verification failed: %v", err)
+			}
+			if verified.Header.Alg != alg {
+				t.Fatalf("Invalid Header Algorithm")
+			}
+		}
+	}
+}
+
+// TestCompactSignStreamOptions confirms streaming signatures match in
+// memory signatures with hedged signing and an experimental context
+func TestCompactSignStreamOptions(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	fixed_rand := bytes.Repeat([]byte{0x42}, 32)
+	in_memory, _ := CompactSign(private_key, large_payload, Hedged(bytes.NewReader(fixed_rand)), WithExperimentalContext(experimental_ctx))
+	streamed, err := CompactSignStream(private_key, bytes.NewReader(large_payload), Hedged(bytes.NewReader(fixed_rand)), WithExperimentalContext(experimental_ctx))
+	if err != nil {
+		t.Fatalf("Streaming signature failed: %v", err)
+	}
+	if strings.Split(in_memory, ".")[2] != strings.Split(streamed, ".")[2] {
+		t.Fatalf("Streamed signature differs from in memory signature")
+	}
+	_, err = CompactVerifyStream(public_key, streamed, bytes.NewReader(large_payload), ExpectExperimentalContext(experimental_ctx))
+	if err != nil {
+		t.Fatalf("Streaming verification failed: %v", err)
+	}
+	_, err = CompactVerifyStream(public_key, streamed, bytes.NewReader(large_payload))
+	if err == nil {
+		t.Fatalf("Verified with context without opting in")
+	}
+}
+
+// TestCompactVerifyStreamRejects confirms jose.CompactVerifyStream rejects
+// modified payloads, attached payloads and private keys
+func TestCompactVerifyStreamRejects(t *testing.T) {
+	var private_key, _ = GenerateKey(ML_DSA_44, seed[:])
+	var public_key, _ = PublicKeyFromPrivateKey(private_key)
+	streamed, _ := CompactSignStream(private_key, bytes.NewReader(large_payload))
+	modified := bytes.Clone(large_payload)
+	modified[len(modified)/2] ^= 1
+	_, err := CompactVerifyStream(public_key, streamed, bytes.NewReader(modified))
+	if err == nil {
+		t.Fatalf("Verified a modified payload")
+	}
+	_, err = CompactVerifyStream(public_key, streamed, bytes.NewReader(large_payload[1:]))
+	if err == nil {
+		t.Fatalf("Verified a truncated payload")
+	}
+	attached, _ := CompactSign(private_key, payload)
+	_, err = CompactVerifyStream(public_key, attached, bytes.NewReader(payload))
+	if err == nil {
+		t.Fatalf("Verified a JWS with an attached payload")
+	}
+	_, err = CompactVerifyStream(private_key, streamed, bytes.NewReader(large_payload))
+	if err == nil {
+		t.Fatalf("Verified with a private key")
+	}
+}
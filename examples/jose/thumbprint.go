@@ -0,0 +1,48 @@
+package jose
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// see: https://datatracker.ietf.org/doc/html/rfc9278#section-3
+const JWK_THUMBPRINT_URI_PREFIX = "urn:ietf:params:oauth:jwk-thumbprint:"
+
+// see: https://www.iana.org/assignments/named-information/named-information.xhtml
+const JWK_THUMBPRINT_SHA_256 = "sha-256"
+
+// JwkThumbprintURI returns the URI of a SHA-256 JWK thumbprint, as
+// returned by CalculateJwkThumbprint.
+func JwkThumbprintURI(thumbprint string) string {
+	return JWK_THUMBPRINT_URI_PREFIX + JWK_THUMBPRINT_SHA_256 + ":" + thumbprint
+}
+
+func CalculateJwkThumbprintURI(jwk string) (string, error) {
+	thumbprint, err := CalculateJwkThumbprint(jwk)
+	if err != nil {
+		return "", err
+	}
+	return JwkThumbprintURI(thumbprint), nil
+}
+
+// ParseJwkThumbprintURI returns the SHA-256 JWK thumbprint of a JWK
+// thumbprint URI. The URN prefix is case insensitive, other hash
+// algorithms are not supported.
+func ParseJwkThumbprintURI(uri string) (string, error) {
+	if len(uri) < len(JWK_THUMBPRINT_URI_PREFIX) || !strings.EqualFold(uri[:len(JWK_THUMBPRINT_URI_PREFIX)], JWK_THUMBPRINT_URI_PREFIX) {
+		return "", errors.New("Not a JWK thumbprint URI")
+	}
+	hash, thumbprint, found := strings.Cut(uri[len(JWK_THUMBPRINT_URI_PREFIX):], ":")
+	if !found {
+		return "", errors.New("JWK thumbprint URI is missing the hash algorithm")
+	}
+	if hash != JWK_THUMBPRINT_SHA_256 {
+		return "", errors.New("Unsupported JWK thumbprint hash algorithm: " + hash)
+	}
+	digest, err := base64.RawURLEncoding.DecodeString(thumbprint)
+	if err != nil || len(digest) != 32 {
+		return "", errors.New("Malformed JWK thumbprint")
+	}
+	return thumbprint, nil
+}
@@ -0,0 +1,61 @@
+package jose
+
+import (
+	"testing"
+)
+
+// TestJwkThumbprintURI calls jose.CalculateJwkThumbprintURI and
+// jose.ParseJwkThumbprintURI and confirms the URI round trips
+func TestJwkThumbprintURI(t *testing.T) {
+	var k1 = `{"kty":"EC","crv":"P-256","x":"zQwCN0Q1A2OF-vzRFYMDTThEjkSl3o6vSonhDQwHHz4","y":"ahiGLX7rLYv4DIlKk017zC-zqgzexrxoVuQvaJuObzA"}`
+	uri, err := CalculateJwkThumbprintURI(k1)
+	if err != nil || uri != "urn:ietf:params:oauth:jwk-thumbprint:sha-256:sF8ijcZ3yIRTT6M9vtM_jMouZZKTtlkCM5BwbK75mck" {
+		t.Fatalf("Incorrect JWK thumbprint URI (%s)", uri)
+	}
+	// see: https://datatracker.ietf.org/doc/html/rfc9278#section-4
+	thumbprint, err := ParseJwkThumbprintURI("URN:IETF:params:oauth:jwk-thumbprint:sha-256:NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+	if err != nil || thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Fatalf("Failed to parse JWK thumbprint URI: %v", err)
+	}
+	for _, malformed := range []string{
+		"",
+		"sF8ijcZ3yIRTT6M9vtM_jMouZZKTtlkCM5BwbK75mck",
+		"urn:ietf:params:oauth:jwk-thumbprint:sF8ijcZ3yIRTT6M9vtM_jMouZZKTtlkCM5BwbK75mck",
+		"urn:ietf:params:oauth:jwk-thumbprint:sha-384:sF8ijcZ3yIRTT6M9vtM_jMouZZKTtlkCM5BwbK75mck",
+		"urn:ietf:params:oauth:jwk-thumbprint:sha-256:sF8ijcZ3yIRTT6M9vtM_jMouZZKTtlkCM5Bw",
+		"urn:ietf:params:oauth:jwk-thumbprint:sha-256:sF8ijcZ3yIRTT6M9vtM/jMouZZKTtlkCM5BwbK75mck",
+	} {
+		_, err = ParseJwkThumbprintURI(malformed)
+		if err == nil {
+			t.Fatalf("Parsed malformed JWK thumbprint URI %q", malformed)
+		}
+	}
+}
+
+// TestGenerateKeyThumbprintURI calls jose.GenerateKey with the thumbprint
+// URI kid option and confirms keys resolve by either form of the kid
+func TestGenerateKeyThumbprintURI(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:], WithThumbprintURIKid())
+	key, _ := DecodeKey(private_key)
+	if key.Kid != "urn:ietf:params:oauth:jwk-thumbprint:sha-256:T4xl70S7MT6Zeq6r9V9fPJGVn76wfnXJ21-gyo0Gu6o" {
+		t.Fatalf("JWK did not have expected thumbprint URI (%s)", key.Kid)
+	}
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	thumbprint_kid, _ := GenerateKey(ML_DSA_44, seed[:])
+	uri_jws, _ := CompactSign(private_key, payload)
+	thumbprint_jws, _ := CompactSign(thumbprint_kid, payload)
+	for _, resolver := range []KeyResolver{NewStaticKeyResolver(public_key), NewStaticKeyResolver(private_key), NewStaticKeyResolver(thumbprint_kid)} {
+		for _, jws := range []string{uri_jws, thumbprint_jws} {
+			_, err := CompactVerifyWithResolver(resolver, jws)
+			if err != nil {
+				t.Fatalf("Verification with either form of the kid failed: %v", err)
+			}
+		}
+	}
+	other_uri, _ := GenerateKey(ML_DSA_44, large_payload[:32], WithThumbprintURIKid())
+	other_jws, _ := CompactSign(other_uri, payload)
+	_, err := CompactVerifyWithResolver(NewStaticKeyResolver(public_key), other_jws)
+	if err == nil {
+		t.Fatalf("Resolved a key by the thumbprint URI of another key")
+	}
+}
@@ -0,0 +1,35 @@
+package jose
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// CompactVerifyDetached verifies a JWS in compact serialization with a
+// detached payload. The payload is given as it was signed, it is encoded
+// here unless the JWS has "b64": false.
+func CompactVerifyDetached(public_key string, jws string, payload []byte, opts ...VerifyOption) (JWSVerification, error) {
+	var verified = JWSVerification{}
+	key, err := parsePublicKey(public_key)
+	if err != nil {
+		return verified, err
+	}
+	components := strings.Split(jws, ".")
+	if len(components) != 3 {
+		return verified, errors.New("JWS must have three components")
+	}
+	if components[1] != "" {
+		return verified, errors.New("JWS payload is not detached")
+	}
+	o := newVerifyOptions(opts)
+	header, err := decodeProtectedHeader(components[0], o)
+	if err != nil {
+		return verified, err
+	}
+	var encoded_payload = base64.RawURLEncoding.EncodeToString(payload)
+	if header.unencoded() {
+		encoded_payload = string(payload)
+	}
+	return compactVerifyParts(key, components[0], encoded_payload, components[2], o)
+}
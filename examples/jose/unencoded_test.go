@@ -0,0 +1,163 @@
+package jose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// compact payloads cannot contain '.', so the vectors sign the payload
+// without its final period
+var unencoded_payload = bytes.TrimSuffix(payload, []byte("."))
+
+// signWithHeader signs a compact JWS with an arbitrary protected header, so
+// that headers CompactSign does not produce can be tested
+func signWithHeader(private_key string, header string, encoded_payload string) string {
+	suite, _, priv, _ := SuiteFromJWK(private_key)
+	encoded_header := base64.RawURLEncoding.EncodeToString([]byte(header))
+	signature := suite.Sign(priv, []byte(encoded_header+"."+encoded_payload), nil)
+	return encoded_header + "." + encoded_payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// TestUnencoded calls jose.CompactSign with an unencoded payload for each
+// ML-DSA level and confirms jose.CompactVerify returns the payload as it
+// appears in the JWS
+func TestUnencoded(t *testing.T) {
+	for _, alg := range []string{ML_DSA_44, ML_DSA_65, ML_DSA_87} {
+		private_key, _ := GenerateKey(alg, seed[:])
+		public_key, _ := PublicKeyFromPrivateKey(private_key)
+		key, _ := DecodeKey(private_key)
+		jws, err := CompactSign(private_key, unencoded_payload, Unencoded())
+		if err != nil {
+			t.Fatalf("Unencoded signing %s failed: %v", alg, err)
+		}
+		if strings.Split(jws, ".")[1] != string(unencoded_payload) {
+			t.Fatalf("JWS payload is encoded")
+		}
+		verified, err := CompactVerify(public_key, jws)
+		if err != nil {
+			t.Fatalf("Unencoded verification %s failed: %v", alg, err)
+		}
+		if !bytes.Equal(verified.Payload, unencoded_payload) {
+			t.Fatalf("Invalid payload")
+		}
+		if verified.Header.B64 == nil || *verified.Header.B64 || len(verified.Header.Crit) != 1 || verified.Header.Crit[0] != HEADER_B64 {
+			t.Fatalf("Invalid header, b64: %v crit: %v", verified.Header.B64, verified.Header.Crit)
+		}
+		encoded, _ := CompactSign(private_key, unencoded_payload)
+		if strings.Split(encoded, ".")[2] == strings.Split(jws, ".")[2] {
+			t.Fatalf("Unencoded signature is the same as the encoded signature")
+		}
+		_, err = CompactSign(private_key, payload, Unencoded())
+		if err == nil {
+			t.Fatalf("Signed an attached unencoded payload containing '.'")
+		}
+		tbs := ToBeSignedFromJWS(jws)
+		sig, _ := SignatureFromJWS(jws)
+		pub, _ := base64.RawURLEncoding.DecodeString(key.Pub)
+		examples, _ := json.MarshalIndent(JOSETestVector{
+			Priv:   hex.EncodeToString(seed[:]),
+			Jwk:    key,
+			Jws:    jws,
+			RawTbs: hex.EncodeToString(tbs),
+			RawSig: hex.EncodeToString(sig),
+			RawPub: hex.EncodeToString(pub),
+		}, "", "  ")
+		_ = os.WriteFile("examples/"+strings.ReplaceAll(alg, "-", "_")+".b64.jose.json", examples, 0644)
+	}
+}
+
+// TestDetached calls jose.CompactSign with a detached payload, encoded and
+// unencoded, and confirms it verifies with jose.CompactVerifyDetached and
+// jose.CompactVerifyStream, and the streamed signature is identical
+func TestDetached(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_65, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	for _, opts := range [][]SignOption{{Detached()}, {Unencoded(), Detached()}} {
+		jws, err := CompactSign(private_key, large_payload, opts...)
+		if err != nil {
+			t.Fatalf("Detached signing failed: %v", err)
+		}
+		if strings.Split(jws, ".")[1] != "" {
+			t.Fatalf("JWS payload is not detached")
+		}
+		verified, err := CompactVerifyDetached(public_key, jws, large_payload)
+		if err != nil {
+			t.Fatalf("Detached verification failed: %v", err)
+		}
+		if !bytes.Equal(verified.Payload, large_payload) {
+			t.Fatalf("Invalid payload")
+		}
+		_, err = CompactVerifyDetached(public_key, jws, payload)
+		if err == nil {
+			t.Fatalf("Verified a detached JWS with the wrong payload")
+		}
+		_, err = CompactVerify(public_key, jws)
+		if err == nil {
+			t.Fatalf("Verified a detached JWS without its payload")
+		}
+		_, err = CompactVerifyStream(public_key, jws, bytes.NewReader(large_payload))
+		if err != nil {
+			t.Fatalf("Streaming verification failed: %v", err)
+		}
+		streamed, err := CompactSignStream(private_key, bytes.NewReader(large_payload), opts...)
+		if err != nil || streamed != jws {
+			t.Fatalf("Streamed signature differs from in memory signature: %v", err)
+		}
+	}
+	attached, _ := CompactSign(private_key, unencoded_payload, Unencoded())
+	_, err := CompactVerifyDetached(public_key, attached, unencoded_payload)
+	if err == nil {
+		t.Fatalf("CompactVerifyDetached accepted an attached payload")
+	}
+	detached, _ := CompactSign(private_key, payload, Detached())
+	_, err = CompactVerifyDetached(private_key, detached, payload)
+	if err == nil {
+		t.Fatalf("CompactVerifyDetached accepted a private key")
+	}
+}
+
+// TestCriticalRejected confirms JWS with malformed or unknown critical
+// header parameters are rejected, even when the signature is valid
+func TestCriticalRejected(t *testing.T) {
+	private_key, _ := GenerateKey(ML_DSA_44, seed[:])
+	public_key, _ := PublicKeyFromPrivateKey(private_key)
+	encoded_payload := base64.RawURLEncoding.EncodeToString(payload)
+	accepted := signWithHeader(private_key, `{"alg":"ML-DSA-44","b64":true,"crit":["b64"]}`, encoded_payload)
+	verified, err := CompactVerify(public_key, accepted)
+	if err != nil || !bytes.Equal(verified.Payload, payload) {
+		t.Fatalf("b64 true was not verified as an encoded payload: %v", err)
+	}
+	for name, header := range map[string]string{
+		"b64 not critical":      `{"alg":"ML-DSA-44","b64":false}`,
+		"b64 not boolean":       `{"alg":"ML-DSA-44","b64":"false","crit":["b64"]}`,
+		"unknown critical":      `{"alg":"ML-DSA-44","exp":1,"crit":["exp"]}`,
+		"missing critical":      `{"alg":"ML-DSA-44","crit":["b64"]}`,
+		"empty critical":        `{"alg":"ML-DSA-44","crit":[]}`,
+		"critical not an array": `{"alg":"ML-DSA-44","b64":false,"crit":"b64"}`,
+	} {
+		jws := signWithHeader(private_key, header, encoded_payload)
+		_, err := CompactVerify(public_key, jws)
+		if err == nil {
+			t.Fatalf("Verified a JWS with %s", name)
+		}
+	}
+	unencoded, _ := CompactSign(private_key, unencoded_payload, Unencoded())
+	flattened, _ := CompactToFlattened(unencoded)
+	_, err = FlattenedVerify(public_key, flattened)
+	if err == nil {
+		t.Fatalf("Verified an unencoded payload in JSON serialization")
+	}
+	_, err = FlattenedSign(private_key, payload, nil, Unencoded())
+	if err == nil {
+		t.Fatalf("Signed an unencoded payload in JSON serialization")
+	}
+	_, err = FlattenedSign(private_key, payload, &JWSHeader{Crit: []string{"x-route"}, Extra: map[string]json.RawMessage{"x-route": json.RawMessage(`"ingest"`)}})
+	if err == nil {
+		t.Fatalf("Signed with an unprotected critical header parameter")
+	}
+}